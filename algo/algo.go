@@ -0,0 +1,100 @@
+// Package algo implements execution algorithms on top of the DVOTC client
+package algo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+)
+
+var (
+	ErrAlgoCancelled = errors.New("algo cancelled")
+	ErrAlgoRunning   = errors.New("algo already started")
+	ErrInvalidConfig = errors.New("invalid algo config")
+	ErrIncomplete    = errors.New("algo finished with quantity remaining")
+	ErrNoQuote       = errors.New("no quote available")
+	ErrNotFilled     = errors.New("order not filled")
+)
+
+// quantities below this are considered filled
+const qtyEpsilon = 1e-9
+
+// MarketOrderPlacer is satisfied by *dvotcWS.DVOTCClient, ctx interrupts an order in flight
+type MarketOrderPlacer interface {
+	PlaceMarketOrderContext(ctx context.Context, order dvotcWS.MarketOrderParams) (*dvotcWS.OrderStatus, error)
+}
+
+type State string
+
+const (
+	StatePending   State = "pending"
+	StateRunning   State = "running"
+	StatePaused    State = "paused"
+	StateCompleted State = "completed"
+	// StateIncomplete is an algo that ran its course with quantity remaining
+	StateIncomplete State = "incomplete"
+	StateCancelled  State = "cancelled"
+	StateFailed     State = "failed"
+)
+
+// quoteBook keeps the latest level data of a level subscription
+type quoteBook struct {
+	mu        sync.RWMutex
+	latest    *dvotcWS.LevelData
+	readyOnce sync.Once
+	ready     chan struct{}
+}
+
+func newQuoteBook() *quoteBook {
+	return &quoteBook{
+		ready: make(chan struct{}),
+	}
+}
+
+// consume keeps the latest level data until levels is closed or ctx is done
+func (q *quoteBook) consume(ctx context.Context, levels <-chan *dvotcWS.LevelData) {
+	for {
+		select {
+		case data, ok := <-levels:
+			if !ok {
+				return
+			}
+			q.set(data)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (q *quoteBook) set(data *dvotcWS.LevelData) {
+	q.mu.Lock()
+	q.latest = data
+	q.mu.Unlock()
+	q.readyOnce.Do(func() {
+		close(q.ready)
+	})
+}
+
+func (q *quoteBook) get() *dvotcWS.LevelData {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.latest
+}
+
+// withinLimit reports if price is not worse than limit for side, a zero limit is always satisfied
+func withinLimit(side string, price, limit float64) bool {
+	if limit <= 0 {
+		return true
+	}
+	if isSell(side) {
+		return price >= limit
+	}
+	return price <= limit
+}
+
+func isSell(side string) bool {
+	return strings.EqualFold(side, "sell")
+}
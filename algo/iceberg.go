@@ -0,0 +1,249 @@
+package algo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+)
+
+var ErrChildCancelled = errors.New("visible order was cancelled outside of the iceberg")
+
+// LimitOrderManager is satisfied by *dvotcWS.DVOTCClient
+type LimitOrderManager interface {
+	PlaceLimitOrder(order dvotcWS.LimitOrderParams) (*dvotcWS.OrderStatus, error)
+	CancelOrder(orderID string) error
+	FetchOrder(ctx context.Context, orderID string) (*dvotcWS.OrderStatus, error)
+	TrackOrderUpdates() error
+	WatchOrder(orderID string) (updates <-chan dvotcWS.OrderStatus, stop func())
+}
+
+type IcebergConfig struct {
+	Asset        string
+	CounterAsset string
+	Side         string
+	LimitPrice   float64
+	// Quantity is the total quantity of the parent order
+	Quantity float64
+	// DisplayQuantity is the size of the single child order resting at any time
+	DisplayQuantity float64
+	// ClientTag is used as prefix of the child orders client tags
+	ClientTag string
+}
+
+type IcebergStatus struct {
+	State         State
+	Quantity      float64
+	Filled        float64
+	Remaining     float64
+	ActiveOrderID string
+	ChildOrderIDs []string
+	LastError     error
+}
+
+// Iceberg keeps one child limit order of DisplayQuantity resting and replaces
+// it every time it fills until the parent quantity is done. Partial fills of the
+// child count as they are reported.
+type Iceberg struct {
+	cfg     IcebergConfig
+	manager LimitOrderManager
+
+	mu     sync.Mutex
+	status IcebergStatus
+
+	cancelOnce sync.Once
+	cancelled  chan struct{}
+}
+
+// NewIceberg creates an iceberg order, the updates of its child orders are watched
+// through manager, which tracks the order updates of the account for the run
+func NewIceberg(manager LimitOrderManager, cfg IcebergConfig) (*Iceberg, error) {
+	switch {
+	case cfg.Quantity <= 0:
+		return nil, fmt.Errorf("%w: quantity must be positive", ErrInvalidConfig)
+	case cfg.DisplayQuantity <= 0 || cfg.DisplayQuantity > cfg.Quantity:
+		return nil, fmt.Errorf("%w: display quantity must be positive and not above quantity", ErrInvalidConfig)
+	case cfg.LimitPrice <= 0:
+		return nil, fmt.Errorf("%w: limit price must be positive", ErrInvalidConfig)
+	}
+
+	return &Iceberg{
+		cfg:     cfg,
+		manager: manager,
+		status: IcebergStatus{
+			State:         StatePending,
+			Quantity:      cfg.Quantity,
+			Remaining:     cfg.Quantity,
+			ChildOrderIDs: make([]string, 0),
+		},
+		cancelled: make(chan struct{}),
+	}, nil
+}
+
+// Run blocks until the whole quantity filled, the iceberg is cancelled or ctx is done,
+// the resting child order is cancelled in the last two cases
+func (ice *Iceberg) Run(ctx context.Context) error {
+	ice.mu.Lock()
+	if ice.status.State != StatePending {
+		ice.mu.Unlock()
+		return ErrAlgoRunning
+	}
+	ice.status.State = StateRunning
+	ice.mu.Unlock()
+
+	// a child can fill ahead of the response placing it
+	if err := ice.manager.TrackOrderUpdates(); err != nil {
+		return ice.finish(err)
+	}
+
+	for i := 0; ; i++ {
+		remaining := ice.Status().Remaining
+		if remaining <= qtyEpsilon {
+			return ice.finish(nil)
+		}
+		select {
+		case <-ice.cancelled:
+			return ice.finish(ErrAlgoCancelled)
+		case <-ctx.Done():
+			return ice.finish(ctx.Err())
+		default:
+		}
+
+		qty := ice.cfg.DisplayQuantity
+		if qty > remaining {
+			qty = remaining
+		}
+		order, err := ice.manager.PlaceLimitOrder(dvotcWS.LimitOrderParams{
+			Asset:        ice.cfg.Asset,
+			CounterAsset: ice.cfg.CounterAsset,
+			LimitPrice:   ice.cfg.LimitPrice,
+			Qty:          qty,
+			Side:         ice.cfg.Side,
+			ClientTag:    fmt.Sprintf("%s-%d", ice.cfg.ClientTag, i),
+		})
+		if err != nil {
+			return ice.finish(err)
+		}
+
+		ice.mu.Lock()
+		ice.status.ActiveOrderID = order.ID
+		ice.status.ChildOrderIDs = append(ice.status.ChildOrderIDs, order.ID)
+		ice.mu.Unlock()
+
+		child := &icebergChild{id: order.ID, qty: qty}
+		if ice.childUpdate(child, *order) {
+			continue
+		}
+		if err := ice.work(ctx, child); err != nil {
+			return ice.finish(err)
+		}
+	}
+}
+
+// icebergChild is the child order resting, filled is the quantity counted so far
+type icebergChild struct {
+	id     string
+	qty    float64
+	filled float64
+}
+
+// work waits until child fills, it is cancelled when the iceberg stops
+func (ice *Iceberg) work(ctx context.Context, child *icebergChild) error {
+	updates, stopWatching := ice.manager.WatchOrder(child.id)
+	defer stopWatching()
+
+	for {
+		select {
+		case update := <-updates:
+			if !ice.childUpdate(child, update) {
+				continue
+			}
+			if update.IsCancelled() {
+				return ErrChildCancelled
+			}
+			return nil
+		case <-ice.cancelled:
+			return ice.stop(child, ErrAlgoCancelled)
+		case <-ctx.Done():
+			return ice.stop(child, ctx.Err())
+		}
+	}
+}
+
+// stop cancels child, if that fails its status is looked up as it may have filled meanwhile
+func (ice *Iceberg) stop(child *icebergChild, reason error) error {
+	err := ice.manager.CancelOrder(child.id)
+	if err == nil {
+		return reason
+	}
+	order, lookupErr := ice.manager.FetchOrder(context.Background(), child.id)
+	if lookupErr != nil {
+		return fmt.Errorf("%w: cancelling %s: %s, looking it up: %s", reason, child.id, err, lookupErr)
+	}
+	if ice.childUpdate(child, *order) {
+		// filled or cancelled, nothing is left resting
+		return reason
+	}
+	return fmt.Errorf("%w: cancelling %s: %s", reason, child.id, err)
+}
+
+// childUpdate counts the quantity child filled since its last update and reports
+// whether the child is done, either filled or cancelled
+func (ice *Iceberg) childUpdate(child *icebergChild, order dvotcWS.OrderStatus) bool {
+	filled := order.FilledQuantity
+	done := order.IsFilled() || order.IsCancelled()
+	if order.IsFilled() && filled == 0 {
+		filled = child.qty
+		if order.Quantity > 0 {
+			filled = order.Quantity
+		}
+	}
+
+	ice.mu.Lock()
+	defer ice.mu.Unlock()
+	if delta := filled - child.filled; delta > 0 {
+		child.filled = filled
+		ice.status.Filled += delta
+		ice.status.Remaining -= delta
+		if ice.status.Remaining < 0 {
+			ice.status.Remaining = 0
+		}
+	}
+	if done {
+		ice.status.ActiveOrderID = ""
+	}
+	return done
+}
+
+func (ice *Iceberg) finish(err error) error {
+	ice.mu.Lock()
+	defer ice.mu.Unlock()
+	switch {
+	case err == nil:
+		ice.status.State = StateCompleted
+	case errors.Is(err, ErrAlgoCancelled) || errors.Is(err, context.Canceled):
+		ice.status.State = StateCancelled
+		ice.status.LastError = err
+	default:
+		ice.status.State = StateFailed
+		ice.status.LastError = err
+	}
+	return err
+}
+
+// Cancel cancels the resting child order and stops replenishing
+func (ice *Iceberg) Cancel() {
+	ice.cancelOnce.Do(func() {
+		close(ice.cancelled)
+	})
+}
+
+func (ice *Iceberg) Status() IcebergStatus {
+	ice.mu.Lock()
+	defer ice.mu.Unlock()
+	status := ice.status
+	status.ChildOrderIDs = append([]string{}, ice.status.ChildOrderIDs...)
+	return status
+}
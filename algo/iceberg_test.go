@@ -0,0 +1,268 @@
+package algo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/algo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ algo.LimitOrderManager = (*dvotcWS.DVOTCClient)(nil)
+
+type fakeLimitManager struct {
+	mu        sync.Mutex
+	orders    []dvotcWS.LimitOrderParams
+	cancelled []string
+	updates   map[string]chan dvotcWS.OrderStatus
+	// cancelErr fails cancellations, the order is then looked up as current
+	cancelErr error
+	current   map[string]dvotcWS.OrderStatus
+}
+
+func (f *fakeLimitManager) PlaceLimitOrder(order dvotcWS.LimitOrderParams) (*dvotcWS.OrderStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.orders = append(f.orders, order)
+	return &dvotcWS.OrderStatus{
+		ID:        fmt.Sprintf("child-%d", len(f.orders)-1),
+		ClientTag: order.ClientTag,
+		Quantity:  order.Qty,
+		Side:      order.Side,
+		Status:    dvotcWS.OrderStatusOpen,
+	}, nil
+}
+
+func (f *fakeLimitManager) CancelOrder(orderID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cancelErr != nil {
+		return f.cancelErr
+	}
+	f.cancelled = append(f.cancelled, orderID)
+	return nil
+}
+
+func (f *fakeLimitManager) FetchOrder(ctx context.Context, orderID string) (*dvotcWS.OrderStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	order, ok := f.current[orderID]
+	if !ok {
+		return nil, dvotcWS.ErrOrderNotFound
+	}
+	return &order, nil
+}
+
+func (f *fakeLimitManager) TrackOrderUpdates() error {
+	return nil
+}
+
+func (f *fakeLimitManager) WatchOrder(orderID string) (<-chan dvotcWS.OrderStatus, func()) {
+	return f.watched(orderID), func() {}
+}
+
+// watched is the channel updates of orderID are sent to, whether watched or not
+func (f *fakeLimitManager) watched(orderID string) chan dvotcWS.OrderStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.updates == nil {
+		f.updates = make(map[string]chan dvotcWS.OrderStatus)
+	}
+	if _, ok := f.updates[orderID]; !ok {
+		f.updates[orderID] = make(chan dvotcWS.OrderStatus, 10)
+	}
+	return f.updates[orderID]
+}
+
+func (f *fakeLimitManager) send(update dvotcWS.OrderStatus) {
+	f.watched(update.ID) <- update
+}
+
+func (f *fakeLimitManager) placed() []dvotcWS.LimitOrderParams {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]dvotcWS.LimitOrderParams{}, f.orders...)
+}
+
+func fillUpdate(orderID string, qty float64) dvotcWS.OrderStatus {
+	now := time.Now()
+	return dvotcWS.OrderStatus{ID: orderID, Quantity: qty, Status: dvotcWS.OrderStatusComplete, FilledAt: &now}
+}
+
+func TestIceberg(t *testing.T) {
+	t.Run("replenishes_until_done", func(t *testing.T) {
+		manager := &fakeLimitManager{}
+		ice, err := algo.NewIceberg(manager, algo.IcebergConfig{
+			Asset:           "BTC",
+			CounterAsset:    "USD",
+			Side:            "Buy",
+			LimitPrice:      20000,
+			Quantity:        2.5,
+			DisplayQuantity: 1,
+			ClientTag:       "ice",
+		})
+		require.NoError(t, err)
+
+		done := make(chan error)
+		go func() {
+			done <- ice.Run(context.Background())
+		}()
+
+		for i, qty := range []float64{1, 1, 0.5} {
+			orderID := fmt.Sprintf("child-%d", i)
+			require.Eventually(t, func() bool {
+				return ice.Status().ActiveOrderID == orderID
+			}, time.Second, 5*time.Millisecond)
+			manager.send(fillUpdate("other", 5))
+			manager.send(fillUpdate(orderID, qty))
+		}
+		require.NoError(t, <-done)
+		// updates of other orders are left to whoever watches them
+		assert.Len(t, manager.watched("other"), 3)
+
+		orders := manager.placed()
+		require.Len(t, orders, 3)
+		assert.Equal(t, 1.0, orders[0].Qty)
+		assert.Equal(t, 0.5, orders[2].Qty)
+		assert.Equal(t, "ice-2", orders[2].ClientTag)
+		assert.Equal(t, 20000.0, orders[1].LimitPrice)
+
+		status := ice.Status()
+		assert.Equal(t, algo.StateCompleted, status.State)
+		assert.Equal(t, 2.5, status.Filled)
+		assert.Equal(t, 0.0, status.Remaining)
+		assert.Equal(t, []string{"child-0", "child-1", "child-2"}, status.ChildOrderIDs)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		manager := &fakeLimitManager{}
+		ice, err := algo.NewIceberg(manager, algo.IcebergConfig{
+			Asset:           "BTC",
+			CounterAsset:    "USD",
+			Side:            "Sell",
+			LimitPrice:      20000,
+			Quantity:        3,
+			DisplayQuantity: 1,
+		})
+		require.NoError(t, err)
+
+		done := make(chan error)
+		go func() {
+			done <- ice.Run(context.Background())
+		}()
+		require.Eventually(t, func() bool {
+			return ice.Status().ActiveOrderID == "child-0"
+		}, time.Second, 5*time.Millisecond)
+
+		ice.Cancel()
+		require.ErrorIs(t, <-done, algo.ErrAlgoCancelled)
+		assert.Equal(t, []string{"child-0"}, manager.cancelled)
+		assert.Equal(t, algo.StateCancelled, ice.Status().State)
+		assert.Equal(t, 3.0, ice.Status().Remaining)
+	})
+
+	t.Run("child_cancelled_externally", func(t *testing.T) {
+		manager := &fakeLimitManager{}
+		ice, err := algo.NewIceberg(manager, algo.IcebergConfig{
+			Side:            "Buy",
+			LimitPrice:      1,
+			Quantity:        2,
+			DisplayQuantity: 1,
+		})
+		require.NoError(t, err)
+
+		manager.send(dvotcWS.OrderStatus{ID: "child-0", Status: dvotcWS.OrderStatusCancelled})
+		require.ErrorIs(t, ice.Run(context.Background()), algo.ErrChildCancelled)
+		assert.Equal(t, algo.StateFailed, ice.Status().State)
+	})
+
+	t.Run("partial_fills", func(t *testing.T) {
+		manager := &fakeLimitManager{}
+		ice, err := algo.NewIceberg(manager, algo.IcebergConfig{
+			Side:            "Buy",
+			LimitPrice:      1,
+			Quantity:        2,
+			DisplayQuantity: 1,
+		})
+		require.NoError(t, err)
+
+		done := make(chan error)
+		go func() {
+			done <- ice.Run(context.Background())
+		}()
+
+		partial := dvotcWS.OrderStatus{ID: "child-0", Quantity: 1, Status: dvotcWS.OrderStatusOpen}
+		for _, filled := range []float64{0.25, 0.6, 0.6} {
+			partial.FilledQuantity = filled
+			manager.send(partial)
+		}
+		require.Eventually(t, func() bool {
+			return ice.Status().Filled == 0.6
+		}, time.Second, 5*time.Millisecond)
+		assert.Equal(t, "child-0", ice.Status().ActiveOrderID)
+		assert.InDelta(t, 1.4, ice.Status().Remaining, 1e-9)
+
+		// the fill completes the child, only the rest of it is counted
+		manager.send(fillUpdate("child-0", 1))
+		require.Eventually(t, func() bool {
+			return ice.Status().ActiveOrderID == "child-1"
+		}, time.Second, 5*time.Millisecond)
+		assert.InDelta(t, 1.0, ice.Status().Filled, 1e-9)
+
+		ice.Cancel()
+		require.ErrorIs(t, <-done, algo.ErrAlgoCancelled)
+		assert.InDelta(t, 1.0, ice.Status().Remaining, 1e-9)
+	})
+
+	t.Run("failed_cancel_reconciled", func(t *testing.T) {
+		manager := &fakeLimitManager{
+			cancelErr: errors.New("order not open"),
+			current:   map[string]dvotcWS.OrderStatus{"child-0": fillUpdate("child-0", 1)},
+		}
+		ice, err := algo.NewIceberg(manager, algo.IcebergConfig{
+			Side:            "Buy",
+			LimitPrice:      1,
+			Quantity:        2,
+			DisplayQuantity: 1,
+		})
+		require.NoError(t, err)
+
+		done := make(chan error)
+		go func() {
+			done <- ice.Run(context.Background())
+		}()
+		require.Eventually(t, func() bool {
+			return ice.Status().ActiveOrderID == "child-0"
+		}, time.Second, 5*time.Millisecond)
+
+		// the child filled before the cancel got there
+		ice.Cancel()
+		err = <-done
+		require.ErrorIs(t, err, algo.ErrAlgoCancelled)
+		assert.Equal(t, algo.ErrAlgoCancelled, err)
+		status := ice.Status()
+		assert.Equal(t, 1.0, status.Filled)
+		assert.Empty(t, status.ActiveOrderID)
+
+		// a child still open is reported
+		manager.current["child-0"] = dvotcWS.OrderStatus{ID: "child-0", Quantity: 1, Status: dvotcWS.OrderStatusOpen}
+		ice, err = algo.NewIceberg(manager, algo.IcebergConfig{Side: "Buy", LimitPrice: 1, Quantity: 1, DisplayQuantity: 1})
+		require.NoError(t, err)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err = ice.Run(ctx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorContains(t, err, "cancelling child-1: order not open")
+	})
+
+	t.Run("invalid_config", func(t *testing.T) {
+		_, err := algo.NewIceberg(&fakeLimitManager{}, algo.IcebergConfig{Quantity: 1, DisplayQuantity: 2, LimitPrice: 1})
+		require.ErrorIs(t, err, algo.ErrInvalidConfig)
+	})
+}
@@ -0,0 +1,334 @@
+package algo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+)
+
+var ErrTriggerNotFound = errors.New("trigger not found")
+
+type TriggerType string
+
+const (
+	TriggerStopLoss     TriggerType = "stop-loss"
+	TriggerTakeProfit   TriggerType = "take-profit"
+	TriggerTrailingStop TriggerType = "trailing-stop"
+)
+
+type TriggerState string
+
+const (
+	TriggerArmed     TriggerState = "armed"
+	TriggerFired     TriggerState = "fired"
+	TriggerFailed    TriggerState = "failed"
+	TriggerCancelled TriggerState = "cancelled"
+)
+
+// Trigger fires a market order of Qty on Side once the price we would get on
+// that side crosses TriggerPrice, for trailing stops the trigger price follows
+// the best price seen at a distance of TrailingOffset
+type Trigger struct {
+	ID           string      `json:"id"`
+	Type         TriggerType `json:"type"`
+	Asset        string      `json:"asset"`
+	CounterAsset string      `json:"counterAsset"`
+	Side         string      `json:"side"`
+	Qty          float64     `json:"qty"`
+	ClientTag    string      `json:"clientTag,omitempty"`
+
+	TriggerPrice   float64 `json:"triggerPrice,omitempty"`
+	TrailingOffset float64 `json:"trailingOffset,omitempty"`
+	// ReferencePrice is the best price seen by a trailing stop
+	ReferencePrice float64 `json:"referencePrice,omitempty"`
+
+	State     TriggerState `json:"state"`
+	CreatedAt time.Time    `json:"createdAt"`
+	FiredAt   *time.Time   `json:"firedAt,omitempty"`
+	FirePrice float64      `json:"firePrice,omitempty"`
+	OrderID   string       `json:"orderId,omitempty"`
+	Error     string       `json:"error,omitempty"`
+}
+
+func (t Trigger) Symbol() string {
+	return fmt.Sprintf("%s/%s", t.Asset, t.CounterAsset)
+}
+
+// StopPrice returns the price at which the trigger fires next
+func (t Trigger) StopPrice() float64 {
+	if t.Type != TriggerTrailingStop {
+		return t.TriggerPrice
+	}
+	if isSell(t.Side) {
+		return t.ReferencePrice - t.TrailingOffset
+	}
+	return t.ReferencePrice + t.TrailingOffset
+}
+
+func (t Trigger) validate() error {
+	switch {
+	case t.Asset == "" || t.CounterAsset == "":
+		return fmt.Errorf("%w: asset and counter asset are required", ErrInvalidConfig)
+	case t.Qty <= 0:
+		return fmt.Errorf("%w: quantity must be positive", ErrInvalidConfig)
+	}
+	switch t.Type {
+	case TriggerStopLoss, TriggerTakeProfit:
+		if t.TriggerPrice <= 0 {
+			return fmt.Errorf("%w: trigger price must be positive", ErrInvalidConfig)
+		}
+	case TriggerTrailingStop:
+		if t.TrailingOffset <= 0 {
+			return fmt.Errorf("%w: trailing offset must be positive", ErrInvalidConfig)
+		}
+	default:
+		return fmt.Errorf("%w: unknown trigger type %q", ErrInvalidConfig, t.Type)
+	}
+	return nil
+}
+
+// update moves a trailing stop along with price and reports if the trigger fires
+func (t *Trigger) update(price float64) bool {
+	sell := isSell(t.Side)
+	switch t.Type {
+	case TriggerStopLoss:
+		if sell {
+			return price <= t.TriggerPrice
+		}
+		return price >= t.TriggerPrice
+	case TriggerTakeProfit:
+		if sell {
+			return price >= t.TriggerPrice
+		}
+		return price <= t.TriggerPrice
+	case TriggerTrailingStop:
+		if t.ReferencePrice == 0 || (sell && price > t.ReferencePrice) || (!sell && price < t.ReferencePrice) {
+			t.ReferencePrice = price
+		}
+		if sell {
+			return price <= t.StopPrice()
+		}
+		return price >= t.StopPrice()
+	}
+	return false
+}
+
+// TriggerManager keeps armed triggers, evaluates them against level updates
+// and persists them to a JSON file so they survive restarts
+type TriggerManager struct {
+	placer MarketOrderPlacer
+	path   string
+
+	mu       sync.Mutex
+	triggers map[string]*Trigger
+}
+
+// NewTriggerManager loads triggers persisted at path, an empty path disables persistence
+func NewTriggerManager(placer MarketOrderPlacer, path string) (*TriggerManager, error) {
+	m := &TriggerManager{
+		placer:   placer,
+		path:     path,
+		triggers: make(map[string]*Trigger),
+	}
+	if path == "" {
+		return m, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	triggers := make([]*Trigger, 0)
+	if err := json.Unmarshal(data, &triggers); err != nil {
+		return nil, fmt.Errorf("loading triggers from %s: %w", path, err)
+	}
+	for _, t := range triggers {
+		m.triggers[t.ID] = t
+	}
+	return m, nil
+}
+
+// Arm validates and stores a new trigger, ID, state and creation time are set by the manager
+func (m *TriggerManager) Arm(t Trigger) (Trigger, error) {
+	if err := t.validate(); err != nil {
+		return Trigger{}, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Trigger{}, err
+	}
+	t.ID = hex.EncodeToString(id)
+	t.State = TriggerArmed
+	t.CreatedAt = time.Now().UTC()
+	t.FiredAt = nil
+	t.OrderID = ""
+	t.Error = ""
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.triggers[t.ID] = &t
+	if err := m.persist(); err != nil {
+		delete(m.triggers, t.ID)
+		return Trigger{}, err
+	}
+	return t, nil
+}
+
+// Disarm cancels an armed trigger
+func (m *TriggerManager) Disarm(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.triggers[id]
+	if !ok || t.State != TriggerArmed {
+		return fmt.Errorf("%w: %s", ErrTriggerNotFound, id)
+	}
+	t.State = TriggerCancelled
+	return m.persist()
+}
+
+// Triggers returns every trigger known, armed or not, oldest first
+func (m *TriggerManager) Triggers() []Trigger {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	triggers := make([]Trigger, 0, len(m.triggers))
+	for _, t := range m.triggers {
+		triggers = append(triggers, *t)
+	}
+	sort.Slice(triggers, func(i, j int) bool {
+		return triggers[i].CreatedAt.Before(triggers[j].CreatedAt)
+	})
+	return triggers
+}
+
+// Watch evaluates the triggers of symbol on every level update until levels
+// is closed or ctx is done, levels is usually the Data channel of SubscribeLevels
+func (m *TriggerManager) Watch(ctx context.Context, symbol string, levels <-chan *dvotcWS.LevelData) error {
+	for {
+		select {
+		case data, ok := <-levels:
+			if !ok {
+				return nil
+			}
+			m.Evaluate(symbol, data)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Evaluate checks the armed triggers of symbol against data and fires the
+// ones crossed with a market order on the quote of data
+func (m *TriggerManager) Evaluate(symbol string, data *dvotcWS.LevelData) {
+	if data == nil {
+		return
+	}
+
+	m.mu.Lock()
+	fired := make([]*Trigger, 0)
+	changed := false
+	for _, t := range m.triggers {
+		if t.State != TriggerArmed || t.Symbol() != symbol {
+			continue
+		}
+		price, ok := data.PriceFor(t.Side, t.Qty)
+		if !ok {
+			continue
+		}
+		reference := t.ReferencePrice
+		if t.update(price) {
+			now := time.Now().UTC()
+			t.State = TriggerFired
+			t.FiredAt = &now
+			t.FirePrice = price
+			fired = append(fired, t)
+		}
+		changed = changed || t.ReferencePrice != reference || t.State != TriggerArmed
+	}
+	if changed {
+		m.persistOrLog()
+	}
+	m.mu.Unlock()
+
+	for _, t := range fired {
+		m.fire(t, data.QuoteID)
+	}
+}
+
+func (m *TriggerManager) fire(t *Trigger, quoteID string) {
+	m.mu.Lock()
+	order := dvotcWS.MarketOrderParams{
+		QuoteID:      quoteID,
+		Asset:        t.Asset,
+		CounterAsset: t.CounterAsset,
+		Price:        t.FirePrice,
+		Qty:          t.Qty,
+		Side:         t.Side,
+		ClientTag:    t.ClientTag,
+	}
+	m.mu.Unlock()
+
+	status, err := m.placer.PlaceMarketOrderContext(context.Background(), order)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		t.State = TriggerFailed
+		t.Error = err.Error()
+	} else if status != nil {
+		t.OrderID = status.ID
+	}
+	m.persistOrLog()
+}
+
+func (m *TriggerManager) persistOrLog() {
+	if err := m.persist(); err != nil {
+		log.Printf("persisting triggers: %s", err)
+	}
+}
+
+// persist writes all triggers to a temporary file renamed over path, callers hold m.mu
+func (m *TriggerManager) persist() error {
+	if m.path == "" {
+		return nil
+	}
+	triggers := make([]*Trigger, 0, len(m.triggers))
+	for _, t := range m.triggers {
+		triggers = append(triggers, t)
+	}
+	sort.Slice(triggers, func(i, j int) bool {
+		return triggers[i].CreatedAt.Before(triggers[j].CreatedAt)
+	})
+	data, err := json.MarshalIndent(triggers, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), filepath.Base(m.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), m.path)
+}
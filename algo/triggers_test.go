@@ -0,0 +1,133 @@
+package algo_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/algo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func quoteAt(quoteID string, buy, sell float64) *dvotcWS.LevelData {
+	return &dvotcWS.LevelData{
+		QuoteID: quoteID,
+		Levels:  []dvotcWS.Level{{BuyPrice: buy, SellPrice: sell, MaxQuantity: 100}},
+	}
+}
+
+func TestTriggers(t *testing.T) {
+	t.Run("stop_loss_and_take_profit", func(t *testing.T) {
+		placer := &fakePlacer{}
+		m, err := algo.NewTriggerManager(placer, "")
+		require.NoError(t, err)
+
+		stop, err := m.Arm(algo.Trigger{Type: algo.TriggerStopLoss, Asset: "BTC", CounterAsset: "USD", Side: "Sell", Qty: 1, TriggerPrice: 19000})
+		require.NoError(t, err)
+		profit, err := m.Arm(algo.Trigger{Type: algo.TriggerTakeProfit, Asset: "BTC", CounterAsset: "USD", Side: "Sell", Qty: 2, TriggerPrice: 21000})
+		require.NoError(t, err)
+		assert.Equal(t, algo.TriggerArmed, stop.State)
+		assert.NotEmpty(t, stop.ID)
+
+		// other symbols are ignored
+		m.Evaluate("ETH/USD", quoteAt("q0", 10, 5))
+		m.Evaluate("BTC/USD", quoteAt("q1", 20010, 19990))
+		assert.Empty(t, placer.placed())
+
+		m.Evaluate("BTC/USD", quoteAt("q2", 19010, 18990))
+		orders := placer.placed()
+		require.Len(t, orders, 1)
+		assert.Equal(t, "q2", orders[0].QuoteID)
+		assert.Equal(t, 18990.0, orders[0].Price)
+		assert.Equal(t, 1.0, orders[0].Qty)
+
+		m.Evaluate("BTC/USD", quoteAt("q3", 21020, 21000))
+		require.Len(t, placer.placed(), 2)
+
+		// fired triggers don't fire twice
+		m.Evaluate("BTC/USD", quoteAt("q4", 18000, 17990))
+		require.Len(t, placer.placed(), 2)
+
+		for _, trigger := range m.Triggers() {
+			assert.Equal(t, algo.TriggerFired, trigger.State)
+			assert.NotNil(t, trigger.FiredAt)
+			assert.Contains(t, []string{stop.ID, profit.ID}, trigger.ID)
+		}
+	})
+
+	t.Run("trailing_stop", func(t *testing.T) {
+		placer := &fakePlacer{}
+		m, err := algo.NewTriggerManager(placer, "")
+		require.NoError(t, err)
+
+		trailing, err := m.Arm(algo.Trigger{Type: algo.TriggerTrailingStop, Asset: "BTC", CounterAsset: "USD", Side: "Sell", Qty: 1, TrailingOffset: 500})
+		require.NoError(t, err)
+
+		levels := make(chan *dvotcWS.LevelData, 5)
+		levels <- quoteAt("q1", 20010, 20000)
+		levels <- quoteAt("q2", 21010, 21000)
+		levels <- quoteAt("q3", 20610, 20600)
+		levels <- quoteAt("q4", 20410, 20400)
+		close(levels)
+		require.NoError(t, m.Watch(context.Background(), "BTC/USD", levels))
+
+		orders := placer.placed()
+		require.Len(t, orders, 1)
+		assert.Equal(t, "q4", orders[0].QuoteID)
+
+		fired := m.Triggers()[0]
+		assert.Equal(t, trailing.ID, fired.ID)
+		assert.Equal(t, 21000.0, fired.ReferencePrice)
+		assert.Equal(t, 20500.0, fired.StopPrice())
+		assert.Equal(t, 20400.0, fired.FirePrice)
+	})
+
+	t.Run("persisted_across_restarts", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "triggers.json")
+		m, err := algo.NewTriggerManager(&fakePlacer{}, path)
+		require.NoError(t, err)
+
+		armed, err := m.Arm(algo.Trigger{Type: algo.TriggerStopLoss, Asset: "ETH", CounterAsset: "USD", Side: "Sell", Qty: 3, TriggerPrice: 1500})
+		require.NoError(t, err)
+		disarmed, err := m.Arm(algo.Trigger{Type: algo.TriggerTakeProfit, Asset: "ETH", CounterAsset: "USD", Side: "Sell", Qty: 3, TriggerPrice: 2500})
+		require.NoError(t, err)
+		require.NoError(t, m.Disarm(disarmed.ID))
+		require.ErrorIs(t, m.Disarm(disarmed.ID), algo.ErrTriggerNotFound)
+
+		placer := &fakePlacer{}
+		restored, err := algo.NewTriggerManager(placer, path)
+		require.NoError(t, err)
+		triggers := restored.Triggers()
+		require.Len(t, triggers, 2)
+		assert.Equal(t, armed.ID, triggers[0].ID)
+		assert.Equal(t, algo.TriggerArmed, triggers[0].State)
+		assert.Equal(t, algo.TriggerCancelled, triggers[1].State)
+
+		restored.Evaluate("ETH/USD", quoteAt("q1", 1410, 1400))
+		require.Len(t, placer.placed(), 1)
+	})
+
+	t.Run("failed_order", func(t *testing.T) {
+		m, err := algo.NewTriggerManager(&fakePlacer{err: errors.New("quote expired")}, "")
+		require.NoError(t, err)
+		_, err = m.Arm(algo.Trigger{Type: algo.TriggerStopLoss, Asset: "BTC", CounterAsset: "USD", Side: "Buy", Qty: 1, TriggerPrice: 21000})
+		require.NoError(t, err)
+
+		m.Evaluate("BTC/USD", quoteAt("q1", 21100, 21000))
+		trigger := m.Triggers()[0]
+		assert.Equal(t, algo.TriggerFailed, trigger.State)
+		assert.Equal(t, "quote expired", trigger.Error)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		m, err := algo.NewTriggerManager(&fakePlacer{}, "")
+		require.NoError(t, err)
+		_, err = m.Arm(algo.Trigger{Type: algo.TriggerTrailingStop, Asset: "BTC", CounterAsset: "USD", Side: "Sell", Qty: 1})
+		require.ErrorIs(t, err, algo.ErrInvalidConfig)
+		_, err = m.Arm(algo.Trigger{Type: "stop-limit", Asset: "BTC", CounterAsset: "USD", Side: "Sell", Qty: 1})
+		require.ErrorIs(t, err, algo.ErrInvalidConfig)
+	})
+}
@@ -0,0 +1,336 @@
+package algo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+)
+
+type TWAPConfig struct {
+	Asset        string
+	CounterAsset string
+	Side         string
+	// Quantity is the parent quantity worked over Duration in Slices child orders
+	Quantity float64
+	Duration time.Duration
+	Slices   int
+	// SizeJitter randomizes every slice size by up to this fraction, 0.2 means ±20%
+	SizeJitter float64
+	// TimeJitter randomizes the wait between slices by up to this fraction
+	TimeJitter float64
+	// LimitPrice skips slices while the quote is worse, zero means no limit.
+	// Skipped quantity is carried over to the following slices
+	LimitPrice float64
+	// ClientTag is used as prefix of the child orders client tags
+	ClientTag string
+	// Seed makes slice randomization reproducible, zero seeds from the clock
+	Seed int64
+}
+
+type TWAPProgress struct {
+	State         State
+	Quantity      float64
+	Filled        float64
+	Remaining     float64
+	AvgPrice      float64
+	SlicesPlaced  int
+	SlicesSkipped int
+	LastError     error
+}
+
+// TWAP works a parent quantity through PlaceMarketOrder child orders spread
+// evenly over time, priced from the latest quote of a level subscription
+type TWAP struct {
+	cfg    TWAPConfig
+	placer MarketOrderPlacer
+	levels <-chan *dvotcWS.LevelData
+	quotes *quoteBook
+	rnd    *rand.Rand
+
+	mu       sync.Mutex
+	progress TWAPProgress
+	notional float64
+	wake     chan struct{}
+
+	cancelOnce sync.Once
+	cancelled  chan struct{}
+}
+
+// NewTWAP creates a TWAP executor, levels is usually the Data channel of SubscribeLevels for the symbol
+func NewTWAP(placer MarketOrderPlacer, levels <-chan *dvotcWS.LevelData, cfg TWAPConfig) (*TWAP, error) {
+	switch {
+	case cfg.Quantity <= 0:
+		return nil, fmt.Errorf("%w: quantity must be positive", ErrInvalidConfig)
+	case cfg.Slices <= 0:
+		return nil, fmt.Errorf("%w: slices must be positive", ErrInvalidConfig)
+	case cfg.Duration < 0:
+		return nil, fmt.Errorf("%w: duration can't be negative", ErrInvalidConfig)
+	case cfg.SizeJitter < 0 || cfg.SizeJitter >= 1 || cfg.TimeJitter < 0 || cfg.TimeJitter >= 1:
+		return nil, fmt.Errorf("%w: jitter must be in [0, 1)", ErrInvalidConfig)
+	}
+
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return &TWAP{
+		cfg:    cfg,
+		placer: placer,
+		levels: levels,
+		quotes: newQuoteBook(),
+		rnd:    rand.New(rand.NewSource(seed)),
+		progress: TWAPProgress{
+			State:     StatePending,
+			Quantity:  cfg.Quantity,
+			Remaining: cfg.Quantity,
+		},
+		wake:      make(chan struct{}, 1),
+		cancelled: make(chan struct{}),
+	}, nil
+}
+
+// Run blocks until every slice was sent, the TWAP is cancelled or ctx is done, a
+// slice in flight is given up in the last two cases. ErrIncomplete is returned and
+// the state is StateIncomplete when slices were skipped and quantity remains
+func (t *TWAP) Run(ctx context.Context) error {
+	t.mu.Lock()
+	if t.progress.State != StatePending {
+		t.mu.Unlock()
+		return ErrAlgoRunning
+	}
+	t.progress.State = StateRunning
+	t.mu.Unlock()
+
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	go func() {
+		select {
+		case <-t.cancelled:
+			stop()
+		case <-ctx.Done():
+		}
+	}()
+	go t.quotes.consume(ctx, t.levels)
+	// first slice goes out right away, it needs a quote
+	select {
+	case <-t.quotes.ready:
+	case <-t.cancelled:
+		return t.finish(ErrAlgoCancelled)
+	case <-ctx.Done():
+		return t.finish(t.stopped(ctx))
+	}
+
+	interval := time.Duration(0)
+	if t.cfg.Slices > 1 {
+		interval = t.cfg.Duration / time.Duration(t.cfg.Slices-1)
+	}
+
+	for i := 0; i < t.cfg.Slices; i++ {
+		if i > 0 {
+			if err := t.sleep(ctx, t.jitter(interval, t.cfg.TimeJitter)); err != nil {
+				return t.finish(err)
+			}
+		}
+		if err := t.waitWhilePaused(ctx); err != nil {
+			return t.finish(err)
+		}
+
+		remaining := t.Progress().Remaining
+		if remaining <= qtyEpsilon {
+			break
+		}
+		qty := remaining
+		if slicesLeft := t.cfg.Slices - i; slicesLeft > 1 {
+			qty = remaining / float64(slicesLeft)
+			qty = qty * (1 + t.cfg.SizeJitter*(2*t.rnd.Float64()-1))
+			if qty > remaining {
+				qty = remaining
+			}
+		}
+
+		err := t.placeSlice(ctx, i, qty)
+		if ctx.Err() != nil {
+			return t.finish(t.stopped(ctx))
+		}
+		if errors.Is(err, dvotcWS.ErrTradingHalted) {
+			return t.finish(err)
+		}
+	}
+
+	if t.Progress().Remaining > qtyEpsilon {
+		return t.finish(ErrIncomplete)
+	}
+	return t.finish(nil)
+}
+
+func (t *TWAP) placeSlice(ctx context.Context, i int, qty float64) error {
+	skip := func(err error) error {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.progress.SlicesSkipped++
+		t.progress.LastError = err
+		return err
+	}
+
+	quote := t.quotes.get()
+	if quote == nil {
+		return skip(ErrNoQuote)
+	}
+	price, ok := quote.PriceFor(t.cfg.Side, qty)
+	if !ok {
+		return skip(fmt.Errorf("%w: not enough depth for %g", ErrNoQuote, qty))
+	}
+	if !withinLimit(t.cfg.Side, price, t.cfg.LimitPrice) {
+		return skip(fmt.Errorf("quote %g worse than limit %g", price, t.cfg.LimitPrice))
+	}
+
+	status, err := t.placer.PlaceMarketOrderContext(ctx, dvotcWS.MarketOrderParams{
+		QuoteID:      quote.QuoteID,
+		Asset:        t.cfg.Asset,
+		CounterAsset: t.cfg.CounterAsset,
+		Price:        price,
+		Qty:          qty,
+		Side:         t.cfg.Side,
+		ClientTag:    fmt.Sprintf("%s-%d", t.cfg.ClientTag, i),
+	})
+	if err != nil && ctx.Err() != nil {
+		// interrupted rather than skipped, Run stops right after
+		return err
+	}
+	if err != nil {
+		return skip(err)
+	}
+	// only a filled order counts, anything else carries its quantity over
+	if status == nil {
+		return skip(fmt.Errorf("%w: no order status returned", ErrNotFilled))
+	}
+	if !status.IsFilled() {
+		return skip(fmt.Errorf("%w: order %s is %s", ErrNotFilled, status.ID, status.Status))
+	}
+
+	filledQty, fillPrice := qty, price
+	if status.Quantity > 0 {
+		filledQty = status.Quantity
+	}
+	if status.Price > 0 {
+		fillPrice = status.Price
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.SlicesPlaced++
+	t.progress.Filled += filledQty
+	t.progress.Remaining -= filledQty
+	if t.progress.Remaining < 0 {
+		t.progress.Remaining = 0
+	}
+	t.notional += filledQty * fillPrice
+	t.progress.AvgPrice = t.notional / t.progress.Filled
+	return nil
+}
+
+func (t *TWAP) jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction == 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + fraction*(2*t.rnd.Float64()-1)))
+}
+
+func (t *TWAP) sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-t.cancelled:
+		return ErrAlgoCancelled
+	case <-ctx.Done():
+		return t.stopped(ctx)
+	}
+}
+
+func (t *TWAP) waitWhilePaused(ctx context.Context) error {
+	for {
+		t.mu.Lock()
+		paused := t.progress.State == StatePaused
+		t.mu.Unlock()
+		if !paused {
+			return nil
+		}
+		select {
+		case <-t.wake:
+		case <-t.cancelled:
+			return ErrAlgoCancelled
+		case <-ctx.Done():
+			return t.stopped(ctx)
+		}
+	}
+}
+
+// stopped is the reason ctx, derived from the one passed to Run, is done
+func (t *TWAP) stopped(ctx context.Context) error {
+	select {
+	case <-t.cancelled:
+		return ErrAlgoCancelled
+	default:
+		return ctx.Err()
+	}
+}
+
+func (t *TWAP) finish(err error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case err == nil:
+		t.progress.State = StateCompleted
+	case errors.Is(err, ErrIncomplete):
+		t.progress.State = StateIncomplete
+	case errors.Is(err, ErrAlgoCancelled) || errors.Is(err, context.Canceled):
+		t.progress.State = StateCancelled
+	default:
+		t.progress.State = StateFailed
+		t.progress.LastError = err
+	}
+	return err
+}
+
+// Pause stops sending slices until Resume, the schedule is delayed accordingly
+func (t *TWAP) Pause() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.progress.State == StateRunning {
+		t.progress.State = StatePaused
+	}
+}
+
+func (t *TWAP) Resume() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.progress.State != StatePaused {
+		return
+	}
+	t.progress.State = StateRunning
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Cancel stops the TWAP and gives up waiting for a slice in flight, which may still fill
+func (t *TWAP) Cancel() {
+	t.cancelOnce.Do(func() {
+		close(t.cancelled)
+	})
+}
+
+func (t *TWAP) Progress() TWAPProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.progress
+}
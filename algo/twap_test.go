@@ -0,0 +1,256 @@
+package algo_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/algo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePlacer struct {
+	mu     sync.Mutex
+	orders []dvotcWS.MarketOrderParams
+	err    error
+	// status is returned for the first orders instead of a filled one
+	status []string
+	// hang keeps every order in flight until its ctx is done
+	hang bool
+}
+
+func (f *fakePlacer) PlaceMarketOrderContext(ctx context.Context, order dvotcWS.MarketOrderParams) (*dvotcWS.OrderStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.orders = append(f.orders, order)
+	if f.hang {
+		f.mu.Unlock()
+		<-ctx.Done()
+		f.mu.Lock()
+		return nil, ctx.Err()
+	}
+	if len(f.status) > 0 {
+		status := f.status[0]
+		f.status = f.status[1:]
+		return &dvotcWS.OrderStatus{ID: order.ClientTag, ClientTag: order.ClientTag, Quantity: order.Qty, Side: order.Side, Status: status}, nil
+	}
+	now := time.Now()
+	return &dvotcWS.OrderStatus{
+		ID:        order.ClientTag,
+		ClientTag: order.ClientTag,
+		Price:     order.Price,
+		Quantity:  order.Qty,
+		Side:      order.Side,
+		Status:    dvotcWS.OrderStatusComplete,
+		FilledAt:  &now,
+	}, nil
+}
+
+func (f *fakePlacer) placed() []dvotcWS.MarketOrderParams {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]dvotcWS.MarketOrderParams{}, f.orders...)
+}
+
+func levelsFeed(data ...*dvotcWS.LevelData) chan *dvotcWS.LevelData {
+	levels := make(chan *dvotcWS.LevelData, len(data))
+	for _, d := range data {
+		levels <- d
+	}
+	return levels
+}
+
+var btcQuote = &dvotcWS.LevelData{
+	QuoteID: "quote-1",
+	Market:  "BTC/USD",
+	Levels: []dvotcWS.Level{
+		{BuyPrice: 20010, SellPrice: 19990, MaxQuantity: 1},
+		{BuyPrice: 20020, SellPrice: 19980, MaxQuantity: 10},
+	},
+}
+
+func TestTWAP(t *testing.T) {
+	t.Run("works_full_quantity", func(t *testing.T) {
+		placer := &fakePlacer{}
+		twap, err := algo.NewTWAP(placer, levelsFeed(btcQuote), algo.TWAPConfig{
+			Asset:        "BTC",
+			CounterAsset: "USD",
+			Side:         "Buy",
+			Quantity:     5,
+			Duration:     100 * time.Millisecond,
+			Slices:       5,
+			SizeJitter:   0.3,
+			TimeJitter:   0.3,
+			ClientTag:    "twap",
+			Seed:         42,
+		})
+		require.NoError(t, err)
+		require.NoError(t, twap.Run(context.Background()))
+
+		orders := placer.placed()
+		require.Len(t, orders, 5)
+		total := 0.0
+		for _, o := range orders {
+			assert.Equal(t, "quote-1", o.QuoteID)
+			assert.Equal(t, "Buy", o.Side)
+			total += o.Qty
+		}
+		assert.InDelta(t, 5.0, total, 1e-9)
+		assert.Equal(t, "twap-0", orders[0].ClientTag)
+
+		progress := twap.Progress()
+		assert.Equal(t, algo.StateCompleted, progress.State)
+		assert.InDelta(t, 5.0, progress.Filled, 1e-9)
+		assert.InDelta(t, 0.0, progress.Remaining, 1e-9)
+		assert.Equal(t, 5, progress.SlicesPlaced)
+		assert.True(t, progress.AvgPrice >= 20010 && progress.AvgPrice <= 20020)
+
+		require.ErrorIs(t, twap.Run(context.Background()), algo.ErrAlgoRunning)
+	})
+
+	t.Run("limit_price_respected", func(t *testing.T) {
+		placer := &fakePlacer{}
+		twap, err := algo.NewTWAP(placer, levelsFeed(btcQuote), algo.TWAPConfig{
+			Asset:        "BTC",
+			CounterAsset: "USD",
+			Side:         "Buy",
+			Quantity:     2,
+			Duration:     20 * time.Millisecond,
+			Slices:       3,
+			LimitPrice:   20000,
+		})
+		require.NoError(t, err)
+		require.ErrorIs(t, twap.Run(context.Background()), algo.ErrIncomplete)
+
+		assert.Empty(t, placer.placed())
+		progress := twap.Progress()
+		assert.Equal(t, algo.StateIncomplete, progress.State)
+		assert.Equal(t, 3, progress.SlicesSkipped)
+		assert.Equal(t, 2.0, progress.Remaining)
+		assert.Error(t, progress.LastError)
+	})
+
+	t.Run("pause_resume_cancel", func(t *testing.T) {
+		placer := &fakePlacer{}
+		twap, err := algo.NewTWAP(placer, levelsFeed(btcQuote), algo.TWAPConfig{
+			Asset:        "BTC",
+			CounterAsset: "USD",
+			Side:         "Sell",
+			Quantity:     10,
+			Duration:     time.Second,
+			Slices:       10,
+		})
+		require.NoError(t, err)
+
+		done := make(chan error)
+		go func() {
+			done <- twap.Run(context.Background())
+		}()
+
+		require.Eventually(t, func() bool {
+			return len(placer.placed()) == 1
+		}, time.Second, 5*time.Millisecond)
+		twap.Pause()
+		assert.Equal(t, algo.StatePaused, twap.Progress().State)
+
+		time.Sleep(300 * time.Millisecond)
+		placedWhilePaused := len(placer.placed())
+		assert.LessOrEqual(t, placedWhilePaused, 2)
+
+		twap.Resume()
+		assert.Equal(t, algo.StateRunning, twap.Progress().State)
+		twap.Cancel()
+		require.ErrorIs(t, <-done, algo.ErrAlgoCancelled)
+		assert.Equal(t, algo.StateCancelled, twap.Progress().State)
+		assert.Less(t, twap.Progress().Filled, 10.0)
+	})
+
+	t.Run("cancel_interrupts_slice", func(t *testing.T) {
+		placer := &fakePlacer{hang: true}
+		levels := levelsFeed(btcQuote)
+		twap, err := algo.NewTWAP(placer, levels, algo.TWAPConfig{
+			Asset:    "BTC",
+			Side:     "Buy",
+			Quantity: 1,
+			Slices:   1,
+		})
+		require.NoError(t, err)
+
+		done := make(chan error)
+		go func() {
+			done <- twap.Run(context.Background())
+		}()
+		require.Eventually(t, func() bool {
+			return len(placer.placed()) == 1
+		}, time.Second, 5*time.Millisecond)
+
+		twap.Cancel()
+		select {
+		case err := <-done:
+			require.ErrorIs(t, err, algo.ErrAlgoCancelled)
+		case <-time.After(time.Second):
+			t.Fatal("cancel did not interrupt the slice in flight")
+		}
+		assert.Equal(t, algo.StateCancelled, twap.Progress().State)
+		assert.Zero(t, twap.Progress().SlicesSkipped)
+
+		// the level subscription is no longer read once Run returned
+		levels <- btcQuote
+		time.Sleep(50 * time.Millisecond)
+		assert.Len(t, levels, 1)
+	})
+
+	t.Run("rejected_slice_carries_over", func(t *testing.T) {
+		placer := &fakePlacer{status: []string{"Rejected"}}
+		twap, err := algo.NewTWAP(placer, levelsFeed(btcQuote), algo.TWAPConfig{
+			Asset:        "BTC",
+			CounterAsset: "USD",
+			Side:         "Buy",
+			Quantity:     3,
+			Duration:     20 * time.Millisecond,
+			Slices:       3,
+		})
+		require.NoError(t, err)
+		require.NoError(t, twap.Run(context.Background()))
+
+		orders := placer.placed()
+		require.Len(t, orders, 3)
+		assert.InDelta(t, 1.0, orders[0].Qty, 1e-9)
+		// the rejected slice is spread over the next ones
+		assert.InDelta(t, 1.5, orders[1].Qty, 1e-9)
+		assert.InDelta(t, 1.5, orders[2].Qty, 1e-9)
+
+		progress := twap.Progress()
+		assert.Equal(t, algo.StateCompleted, progress.State)
+		assert.InDelta(t, 3.0, progress.Filled, 1e-9)
+		assert.Equal(t, 2, progress.SlicesPlaced)
+		assert.Equal(t, 1, progress.SlicesSkipped)
+		assert.ErrorIs(t, progress.LastError, algo.ErrNotFilled)
+	})
+
+	t.Run("halted_stops", func(t *testing.T) {
+		placer := &fakePlacer{err: dvotcWS.ErrTradingHalted}
+		twap, err := algo.NewTWAP(placer, levelsFeed(btcQuote), algo.TWAPConfig{
+			Asset:    "BTC",
+			Side:     "Buy",
+			Quantity: 1,
+			Slices:   3,
+		})
+		require.NoError(t, err)
+		require.ErrorIs(t, twap.Run(context.Background()), dvotcWS.ErrTradingHalted)
+		assert.Equal(t, algo.StateFailed, twap.Progress().State)
+	})
+
+	t.Run("invalid_config", func(t *testing.T) {
+		_, err := algo.NewTWAP(&fakePlacer{}, nil, algo.TWAPConfig{Quantity: 1})
+		require.ErrorIs(t, err, algo.ErrInvalidConfig)
+		_, err = algo.NewTWAP(&fakePlacer{}, nil, algo.TWAPConfig{Quantity: 1, Slices: 1, SizeJitter: 1})
+		require.ErrorIs(t, err, algo.ErrInvalidConfig)
+	})
+}
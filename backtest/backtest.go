@@ -0,0 +1,421 @@
+// Package backtest replays recorded levels into a strategy and simulates the
+// fills of its orders against the recorded tiers
+package backtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/portfolio"
+	"github.com/dv-chain/dvotc-websocket-go/recorder"
+	"github.com/dv-chain/dvotc-websocket-go/strategy"
+)
+
+var (
+	ErrUnknownSymbol = errors.New("no levels recorded for symbol")
+	ErrQuoteExpired  = errors.New("quote expired")
+	ErrNoLiquidity   = errors.New("quantity exceeds available liquidity")
+	ErrInvalidOrder  = errors.New("invalid order")
+	ErrOrderNotFound = errors.New("order not found")
+	ErrOrderNotOpen  = errors.New("order is not open")
+)
+
+// Source is satisfied by *recorder.Reader
+type Source interface {
+	Next() bool
+	Record() recorder.Record
+	Err() error
+}
+
+type Config struct {
+	// QuoteAsset is the asset PnL is reported in, defaults to USD
+	QuoteAsset string
+	// TimerInterval calls OnTimer every interval of recorded time from the first
+	// record, zero disables it
+	TimerInterval time.Duration
+}
+
+// Fill is a simulated execution
+type Fill struct {
+	Time      time.Time
+	OrderID   string
+	ClientTag string
+	Symbol    string
+	Side      string
+	OrderType string
+	Qty       float64
+	Price     float64
+	// Reference is the top level price of the side when the order filled
+	Reference float64
+	// Slippage is how much worse than Reference the fill is per unit, in the
+	// counter asset, it comes from quantities too large for the top level
+	Slippage float64
+}
+
+// SlippageBps returns Slippage in basis points of Reference
+func (f Fill) SlippageBps() float64 {
+	if f.Reference == 0 {
+		return 0
+	}
+	return f.Slippage / f.Reference * 1e4
+}
+
+type Stats struct {
+	// Updates is the number of level updates replayed
+	Updates   int
+	Orders    int
+	Filled    int
+	Cancelled int
+	// Rejected counts the orders the gateway returned an error for
+	Rejected int
+	// Volume is the filled notional in the counter assets
+	Volume float64
+	// SlippageCost is the sum of Slippage times quantity of every fill
+	SlippageCost float64
+	// AvgSlippageBps is weighted by notional
+	AvgSlippageBps float64
+	MaxSlippageBps float64
+	RealizedPnL    float64
+	UnrealizedPnL  float64
+	TotalPnL       float64
+}
+
+type Result struct {
+	Fills  []Fill
+	Orders []dvotcWS.OrderStatus
+	// Portfolio is valued at the last recorded levels
+	Portfolio portfolio.Snapshot
+	Stats     Stats
+}
+
+// Backtester is the simulated gateway of a backtest. Market orders must reference
+// the current quote ID of their symbol and fill at the first level deep enough for
+// their quantity, limit orders rest until a level update crosses them. It is not
+// safe for concurrent use, strategies call it from their callbacks
+type Backtester struct {
+	cfg    Config
+	now    time.Time
+	levels map[string]*dvotcWS.LevelData
+	orders []*dvotcWS.OrderStatus
+	byID   map[string]*dvotcWS.OrderStatus
+	nextID int
+	fills  []Fill
+	// events are the order updates and notifications not delivered yet
+	events    []strategy.Event
+	stats     Stats
+	portfolio *portfolio.Portfolio
+}
+
+func New(cfg Config) (*Backtester, error) {
+	if cfg.QuoteAsset == "" {
+		cfg.QuoteAsset = "USD"
+	}
+	p, err := portfolio.New(nil, portfolio.Config{QuoteAsset: cfg.QuoteAsset})
+	if err != nil {
+		return nil, err
+	}
+	return &Backtester{
+		cfg:       cfg,
+		levels:    make(map[string]*dvotcWS.LevelData),
+		byID:      make(map[string]*dvotcWS.OrderStatus),
+		portfolio: p,
+	}, nil
+}
+
+// Run feeds every record of src to s and returns the simulated fills and stats
+func (b *Backtester) Run(src Source, s strategy.Strategy) (*Result, error) {
+	if err := strategy.Run(context.Background(), b.Feed(src), s); err != nil {
+		return nil, err
+	}
+	return b.Result(), nil
+}
+
+// Feed returns the events of a backtest of src for strategy.Run, the order updates
+// and notifications of the simulated orders come before the next levels
+func (b *Backtester) Feed(src Source) strategy.Feed {
+	return &feed{b: b, src: src}
+}
+
+type feed struct {
+	b        *Backtester
+	src      Source
+	pending  *recorder.Record
+	nextTick time.Time
+}
+
+func (f *feed) Gateway() strategy.Gateway {
+	return f.b
+}
+
+func (f *feed) Next(ctx context.Context) (strategy.Event, error) {
+	b, interval := f.b, f.b.cfg.TimerInterval
+	for {
+		if err := ctx.Err(); err != nil {
+			return strategy.Event{}, err
+		}
+		if len(b.events) > 0 {
+			ev := b.events[0]
+			b.events = b.events[1:]
+			return ev, nil
+		}
+		if f.pending == nil {
+			if !f.src.Next() {
+				if err := f.src.Err(); err != nil {
+					return strategy.Event{}, err
+				}
+				return strategy.Event{}, io.EOF
+			}
+			rec := f.src.Record()
+			f.pending = &rec
+			if f.nextTick.IsZero() {
+				f.nextTick = rec.Received.Add(interval)
+			}
+		}
+		if interval > 0 && !f.pending.Received.Before(f.nextTick) {
+			b.now = f.nextTick
+			f.nextTick = f.nextTick.Add(interval)
+			return strategy.Event{Kind: strategy.EventTimer, Time: b.now}, nil
+		}
+
+		rec := *f.pending
+		f.pending = nil
+		b.Update(rec)
+		b.events = append(b.events, strategy.Event{Kind: strategy.EventLevels, Time: rec.Received, Symbol: rec.Symbol, Levels: b.levels[rec.Symbol]})
+	}
+}
+
+func (f *feed) Close() error {
+	return nil
+}
+
+// Update moves the simulation to rec, filling the resting limit orders it crosses
+func (b *Backtester) Update(rec recorder.Record) {
+	b.now = rec.Received
+	data := rec.LevelData()
+	b.levels[rec.Symbol] = data
+	b.portfolio.Mark(rec.Symbol, data)
+	b.stats.Updates++
+
+	for _, order := range b.orders {
+		if order.Status != dvotcWS.OrderStatusOpen || symbolOf(order.Asset, order.CounterAsset) != rec.Symbol {
+			continue
+		}
+		limit, _ := strconv.ParseFloat(order.LimitPrice, 64)
+		if price, ok := data.PriceFor(order.Side, order.Quantity); ok && crosses(order.Side, price, limit) {
+			b.fill(order, data, price)
+		}
+	}
+}
+
+// publish queues the update of order and its notification, if any
+func (b *Backtester) publish(order dvotcWS.OrderStatus, topic string) {
+	b.events = append(b.events, strategy.Event{Kind: strategy.EventOrderUpdate, Time: b.now, Order: order})
+	if topic != "" {
+		b.events = append(b.events, strategy.Event{Kind: strategy.EventNotification, Time: b.now, Topic: topic, Notification: notification(order)})
+	}
+}
+
+func (b *Backtester) PlaceMarketOrder(params dvotcWS.MarketOrderParams) (*dvotcWS.OrderStatus, error) {
+	b.stats.Orders++
+	symbol := symbolOf(params.Asset, params.CounterAsset)
+	data, err := b.validate(symbol, params.Side, params.Qty)
+	if err != nil {
+		return nil, err
+	}
+	if params.QuoteID != data.QuoteID {
+		return nil, b.reject(fmt.Errorf("%w: %s", ErrQuoteExpired, params.QuoteID))
+	}
+	price, ok := data.PriceFor(params.Side, params.Qty)
+	if !ok {
+		return nil, b.reject(fmt.Errorf("%w: %v %s", ErrNoLiquidity, params.Qty, symbol))
+	}
+
+	order := b.add(dvotcWS.OrderStatus{
+		ClientTag:    params.ClientTag,
+		Quantity:     params.Qty,
+		Side:         params.Side,
+		OrderType:    "market",
+		Asset:        params.Asset,
+		CounterAsset: params.CounterAsset,
+	})
+	b.fill(order, data, price)
+	result := *order
+	return &result, nil
+}
+
+func (b *Backtester) PlaceLimitOrder(params dvotcWS.LimitOrderParams) (*dvotcWS.OrderStatus, error) {
+	b.stats.Orders++
+	symbol := symbolOf(params.Asset, params.CounterAsset)
+	data, err := b.validate(symbol, params.Side, params.Qty)
+	if err != nil {
+		return nil, err
+	}
+	if params.LimitPrice <= 0 {
+		return nil, b.reject(fmt.Errorf("%w: limit price must be positive", ErrInvalidOrder))
+	}
+
+	order := b.add(dvotcWS.OrderStatus{
+		ClientTag:    params.ClientTag,
+		LimitPrice:   fmt.Sprintf("%g", params.LimitPrice),
+		Quantity:     params.Qty,
+		Side:         params.Side,
+		OrderType:    "LIMIT",
+		Asset:        params.Asset,
+		CounterAsset: params.CounterAsset,
+	})
+	b.publish(*order, dvotcWS.NOTIFICATION_ORDER_CREATED)
+	if price, ok := data.PriceFor(params.Side, params.Qty); ok && crosses(params.Side, price, params.LimitPrice) {
+		b.fill(order, data, price)
+	}
+	result := *order
+	return &result, nil
+}
+
+func (b *Backtester) CancelOrder(orderID string) error {
+	order, ok := b.byID[orderID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrOrderNotFound, orderID)
+	}
+	if order.Status != dvotcWS.OrderStatusOpen {
+		return fmt.Errorf("%w: %s is %s", ErrOrderNotOpen, orderID, order.Status)
+	}
+	now := b.now
+	order.Status = dvotcWS.OrderStatusCancelled
+	order.CancelledAt = &now
+	b.stats.Cancelled++
+	b.publish(*order, dvotcWS.NOTIFICATION_ORDER_CANCELLED)
+	return nil
+}
+
+func (b *Backtester) validate(symbol, side string, qty float64) (*dvotcWS.LevelData, error) {
+	data, ok := b.levels[symbol]
+	switch {
+	case !ok:
+		return nil, b.reject(fmt.Errorf("%w: %s", ErrUnknownSymbol, symbol))
+	case qty <= 0:
+		return nil, b.reject(fmt.Errorf("%w: quantity must be positive", ErrInvalidOrder))
+	case !strings.EqualFold(side, "buy") && !strings.EqualFold(side, "sell"):
+		return nil, b.reject(fmt.Errorf("%w: unknown side %q", ErrInvalidOrder, side))
+	}
+	return data, nil
+}
+
+func (b *Backtester) reject(err error) error {
+	b.stats.Rejected++
+	return err
+}
+
+func (b *Backtester) add(order dvotcWS.OrderStatus) *dvotcWS.OrderStatus {
+	b.nextID++
+	order.ID = fmt.Sprintf("bt-%d", b.nextID)
+	order.Status = dvotcWS.OrderStatusOpen
+	order.CreatedAt = b.now
+	b.orders = append(b.orders, &order)
+	b.byID[order.ID] = &order
+	return &order
+}
+
+// fill completes order at price against data
+func (b *Backtester) fill(order *dvotcWS.OrderStatus, data *dvotcWS.LevelData, price float64) {
+	now := b.now
+	order.Status = dvotcWS.OrderStatusComplete
+	order.Price = price
+	order.FilledAt = &now
+	b.publish(*order, dvotcWS.NOTIFICATION_ORDER_FILLED)
+	if err := b.portfolio.ApplyOrder(*order); err != nil {
+		log.Println(err)
+	}
+
+	fill := Fill{
+		Time:      now,
+		OrderID:   order.ID,
+		ClientTag: order.ClientTag,
+		Symbol:    symbolOf(order.Asset, order.CounterAsset),
+		Side:      order.Side,
+		OrderType: order.OrderType,
+		Qty:       order.Quantity,
+		Price:     price,
+		Reference: price,
+	}
+	if len(data.Levels) > 0 {
+		fill.Reference = data.Levels[0].BuyPrice
+		fill.Slippage = price - fill.Reference
+		if strings.EqualFold(order.Side, "sell") {
+			fill.Reference = data.Levels[0].SellPrice
+			fill.Slippage = fill.Reference - price
+		}
+	}
+	b.fills = append(b.fills, fill)
+
+	b.stats.Filled++
+	notional := fill.Qty * fill.Price
+	b.stats.Volume += notional
+	b.stats.SlippageCost += fill.Slippage * fill.Qty
+	b.stats.MaxSlippageBps = math.Max(b.stats.MaxSlippageBps, fill.SlippageBps())
+}
+
+// Result returns the fills, orders and stats so far
+func (b *Backtester) Result() *Result {
+	snapshot := b.portfolio.Snapshot()
+	stats := b.stats
+	stats.RealizedPnL = snapshot.RealizedPnL
+	stats.UnrealizedPnL = snapshot.UnrealizedPnL
+	stats.TotalPnL = snapshot.TotalPnL
+	var weighted float64
+	for _, fill := range b.fills {
+		weighted += fill.SlippageBps() * fill.Qty * fill.Price
+	}
+	if stats.Volume > 0 {
+		stats.AvgSlippageBps = weighted / stats.Volume
+	}
+
+	orders := make([]dvotcWS.OrderStatus, 0, len(b.orders))
+	for _, order := range b.orders {
+		orders = append(orders, *order)
+	}
+	return &Result{
+		Fills:     append([]Fill{}, b.fills...),
+		Orders:    orders,
+		Portfolio: snapshot,
+		Stats:     stats,
+	}
+}
+
+func notification(order dvotcWS.OrderStatus) dvotcWS.OrderNotification {
+	return dvotcWS.OrderNotification{
+		Asset:        order.Asset,
+		CounterAsset: order.CounterAsset,
+		ID:           order.ID,
+		Quantity:     order.Quantity,
+		Price:        order.Price,
+		LimitPrice:   json.Number(order.LimitPrice),
+		Side:         order.Side,
+		OrderType:    order.OrderType,
+		Source:       "backtest",
+		Status:       order.Status,
+		FilledAt:     order.FilledAt,
+		CreatedAt:    order.CreatedAt,
+		CancelledAt:  order.CancelledAt,
+		ClientTag:    order.ClientTag,
+	}
+}
+
+func crosses(side string, price, limit float64) bool {
+	if strings.EqualFold(side, "sell") {
+		return price >= limit
+	}
+	return price <= limit
+}
+
+func symbolOf(asset, counterAsset string) string {
+	return asset + "/" + counterAsset
+}
@@ -0,0 +1,251 @@
+package backtest_test
+
+import (
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/backtest"
+	"github.com/dv-chain/dvotc-websocket-go/recorder"
+	"github.com/dv-chain/dvotc-websocket-go/strategy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+type records struct {
+	list []recorder.Record
+	cur  recorder.Record
+}
+
+func (r *records) Next() bool {
+	if len(r.list) == 0 {
+		return false
+	}
+	r.cur, r.list = r.list[0], r.list[1:]
+	return true
+}
+
+func (r *records) Record() recorder.Record { return r.cur }
+func (r *records) Err() error              { return nil }
+
+// session has deep levels first, then levels that cross a resting sell at 20100
+func session() []recorder.Record {
+	return []recorder.Record{
+		{Received: start, Symbol: "BTC/USD", QuoteID: "q-1", Levels: []dvotcWS.Level{
+			{BuyPrice: 20010, SellPrice: 19990, MaxQuantity: 1},
+			{BuyPrice: 20030, SellPrice: 19970, MaxQuantity: 5},
+		}},
+		{Received: start.Add(time.Minute), Symbol: "BTC/USD", QuoteID: "q-2", Levels: []dvotcWS.Level{
+			{BuyPrice: 20130, SellPrice: 20110, MaxQuantity: 5},
+		}},
+	}
+}
+
+type scripted struct {
+	onLevels      func(env strategy.Env, symbol string, levels *dvotcWS.LevelData)
+	updates       []dvotcWS.OrderStatus
+	notifications []string
+	notified      []dvotcWS.OrderNotification
+	timers        []time.Time
+	errs          []error
+}
+
+func (s *scripted) OnLevels(env strategy.Env, symbol string, levels *dvotcWS.LevelData) {
+	if s.onLevels != nil {
+		s.onLevels(env, symbol, levels)
+	}
+}
+
+func (s *scripted) OnOrderUpdate(env strategy.Env, order dvotcWS.OrderStatus) {
+	s.updates = append(s.updates, order)
+}
+
+func (s *scripted) OnNotification(env strategy.Env, topic string, notification any) {
+	n := notification.(dvotcWS.OrderNotification)
+	s.notifications = append(s.notifications, topic+" "+n.ID)
+	s.notified = append(s.notified, n)
+}
+
+func (s *scripted) OnTimer(env strategy.Env) {
+	s.timers = append(s.timers, env.Time)
+}
+
+func TestBacktest(t *testing.T) {
+	s := &scripted{}
+	s.onLevels = func(env strategy.Env, symbol string, levels *dvotcWS.LevelData) {
+		if levels.QuoteID != "q-1" {
+			return
+		}
+		assert.Equal(t, start, env.Time)
+		buy := dvotcWS.MarketOrderParams{QuoteID: levels.QuoteID, Asset: "BTC", CounterAsset: "USD", Qty: 1, Side: "Buy"}
+		_, err := env.Gateway.PlaceMarketOrder(buy)
+		s.errs = append(s.errs, err)
+		// three only fit in the second level
+		buy.Qty = 3
+		_, err = env.Gateway.PlaceMarketOrder(buy)
+		s.errs = append(s.errs, err)
+		buy.QuoteID = "q-0"
+		_, err = env.Gateway.PlaceMarketOrder(buy)
+		s.errs = append(s.errs, err)
+		_, err = env.Gateway.PlaceLimitOrder(dvotcWS.LimitOrderParams{Asset: "BTC", CounterAsset: "USD", LimitPrice: 20100, Qty: 4, Side: "Sell", ClientTag: "exit"})
+		s.errs = append(s.errs, err)
+	}
+
+	bt, err := backtest.New(backtest.Config{})
+	require.NoError(t, err)
+	result, err := bt.Run(&records{list: session()}, s)
+	require.NoError(t, err)
+
+	require.Len(t, s.errs, 4)
+	assert.NoError(t, s.errs[0])
+	assert.NoError(t, s.errs[1])
+	assert.ErrorIs(t, s.errs[2], backtest.ErrQuoteExpired)
+	assert.NoError(t, s.errs[3])
+
+	require.Len(t, result.Fills, 3)
+	assert.Equal(t, 20010.0, result.Fills[0].Price)
+	assert.Zero(t, result.Fills[0].Slippage)
+	assert.Equal(t, 20030.0, result.Fills[1].Price)
+	assert.Equal(t, 20010.0, result.Fills[1].Reference)
+	assert.Equal(t, 20.0, result.Fills[1].Slippage)
+	exit := result.Fills[2]
+	assert.Equal(t, "exit", exit.ClientTag)
+	assert.Equal(t, 20110.0, exit.Price)
+	assert.Equal(t, start.Add(time.Minute), exit.Time)
+
+	// updates of the exit order: resting, then filled by the second levels
+	statuses := make([]string, 0, len(s.updates))
+	for _, update := range s.updates {
+		statuses = append(statuses, update.ID+" "+update.Status)
+	}
+	assert.Equal(t, []string{
+		"bt-1 " + dvotcWS.OrderStatusComplete,
+		"bt-2 " + dvotcWS.OrderStatusComplete,
+		"bt-3 " + dvotcWS.OrderStatusOpen,
+		"bt-3 " + dvotcWS.OrderStatusComplete,
+	}, statuses)
+
+	assert.Equal(t, []string{
+		"ORDER_FILLED bt-1",
+		"ORDER_FILLED bt-2",
+		"ORDER_CREATED bt-3",
+		"ORDER_FILLED bt-3",
+	}, s.notifications)
+	assert.Empty(t, s.timers)
+
+	stats := result.Stats
+	assert.Equal(t, 2, stats.Updates)
+	assert.Equal(t, 4, stats.Orders)
+	assert.Equal(t, 3, stats.Filled)
+	assert.Equal(t, 1, stats.Rejected)
+	assert.Equal(t, 20010.0+3*20030+4*20110, stats.Volume)
+	assert.Equal(t, 60.0, stats.SlippageCost)
+	assert.InDelta(t, 20/20010.0*1e4, stats.MaxSlippageBps, 1e-9)
+	assert.InDelta(t, 20/20010.0*1e4*3*20030/stats.Volume, stats.AvgSlippageBps, 1e-9)
+	// bought 4 at an average of 20025, sold them at 20110
+	assert.InDelta(t, 340, stats.RealizedPnL, 1e-9)
+	assert.InDelta(t, 0, stats.UnrealizedPnL, 1e-9)
+	assert.InDelta(t, 340, stats.TotalPnL, 1e-9)
+	assert.Len(t, result.Orders, 3)
+}
+
+func TestBacktestLiquidityAndCancel(t *testing.T) {
+	var errs []error
+	var resting *dvotcWS.OrderStatus
+	s := &scripted{onLevels: func(env strategy.Env, symbol string, levels *dvotcWS.LevelData) {
+		if levels.QuoteID == "q-1" {
+			_, err := env.Gateway.PlaceMarketOrder(dvotcWS.MarketOrderParams{QuoteID: "q-1", Asset: "BTC", CounterAsset: "USD", Qty: 6, Side: "Sell"})
+			errs = append(errs, err)
+			_, err = env.Gateway.PlaceMarketOrder(dvotcWS.MarketOrderParams{QuoteID: "q-1", Asset: "ETH", CounterAsset: "USD", Qty: 1, Side: "Sell"})
+			errs = append(errs, err)
+			resting, err = env.Gateway.PlaceLimitOrder(dvotcWS.LimitOrderParams{Asset: "BTC", CounterAsset: "USD", LimitPrice: 19000, Qty: 1, Side: "Buy"})
+			errs = append(errs, err)
+			return
+		}
+		errs = append(errs, env.Gateway.CancelOrder(resting.ID), env.Gateway.CancelOrder(resting.ID))
+	}}
+
+	bt, err := backtest.New(backtest.Config{})
+	require.NoError(t, err)
+	result, err := bt.Run(&records{list: session()}, s)
+	require.NoError(t, err)
+
+	require.Len(t, errs, 5)
+	assert.ErrorIs(t, errs[0], backtest.ErrNoLiquidity)
+	assert.ErrorIs(t, errs[1], backtest.ErrUnknownSymbol)
+	assert.NoError(t, errs[2])
+	assert.NoError(t, errs[3])
+	assert.ErrorIs(t, errs[4], backtest.ErrOrderNotOpen)
+	assert.Empty(t, result.Fills)
+	assert.Equal(t, 1, result.Stats.Cancelled)
+	assert.Equal(t, dvotcWS.OrderStatusCancelled, result.Orders[0].Status)
+	assert.Equal(t, dvotcWS.OrderStatusCancelled, s.updates[len(s.updates)-1].Status)
+}
+
+func TestBacktestFromArchive(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := recorder.New(recorder.Config{Dir: dir})
+	require.NoError(t, err)
+	for _, r := range session() {
+		require.NoError(t, rec.WriteRecord(r))
+	}
+	require.NoError(t, rec.Close())
+
+	reader, err := recorder.Open(dir, recorder.Query{})
+	require.NoError(t, err)
+	defer reader.Close()
+
+	s := &scripted{onLevels: func(env strategy.Env, symbol string, levels *dvotcWS.LevelData) {
+		if levels.QuoteID == "q-1" {
+			_, err := env.Gateway.PlaceMarketOrder(dvotcWS.MarketOrderParams{QuoteID: levels.QuoteID, Asset: "BTC", CounterAsset: "USD", Qty: 1, Side: "Buy"})
+			assert.NoError(t, err)
+		}
+	}}
+	bt, err := backtest.New(backtest.Config{})
+	require.NoError(t, err)
+	result, err := bt.Run(reader, s)
+	require.NoError(t, err)
+	require.Len(t, result.Fills, 1)
+	// marked at the mid of the last levels
+	assert.InDelta(t, 20120-20010, result.Stats.UnrealizedPnL, 1e-9)
+}
+
+func TestBacktestTimer(t *testing.T) {
+	var levelsAt []time.Time
+	s := &scripted{onLevels: func(env strategy.Env, symbol string, levels *dvotcWS.LevelData) {
+		levelsAt = append(levelsAt, env.Time)
+	}}
+	bt, err := backtest.New(backtest.Config{TimerInterval: 25 * time.Second})
+	require.NoError(t, err)
+	_, err = bt.Run(&records{list: session()}, s)
+	require.NoError(t, err)
+
+	// recorded time drives the timer, the levels a minute later come after two ticks
+	assert.Equal(t, []time.Time{start.Add(25 * time.Second), start.Add(50 * time.Second)}, s.timers)
+	assert.Equal(t, []time.Time{start, start.Add(time.Minute)}, levelsAt)
+}
+
+func TestBacktestFractionalFill(t *testing.T) {
+	s := &scripted{}
+	s.onLevels = func(env strategy.Env, symbol string, levels *dvotcWS.LevelData) {
+		if levels.QuoteID != "q-1" {
+			return
+		}
+		_, err := env.Gateway.PlaceMarketOrder(dvotcWS.MarketOrderParams{QuoteID: levels.QuoteID, Asset: "BTC", CounterAsset: "USD", Qty: 0.25, Side: "Buy"})
+		s.errs = append(s.errs, err)
+	}
+
+	bt, err := backtest.New(backtest.Config{})
+	require.NoError(t, err)
+	result, err := bt.Run(&records{list: session()}, s)
+	require.NoError(t, err)
+	require.Equal(t, []error{nil}, s.errs)
+
+	require.Len(t, result.Fills, 1)
+	require.Len(t, s.notified, 1)
+	// the notification carries the quantity filled, not a rounded one
+	assert.Equal(t, 0.25, s.notified[0].Quantity)
+	assert.Equal(t, result.Fills[0].Qty, s.notified[0].Quantity)
+}
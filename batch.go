@@ -0,0 +1,132 @@
+package dvotcWS
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrBatchPartialFailure = errors.New("not all requests in the batch succeeded")
+	ErrInvalidOrderRequest = errors.New("order request must set exactly one of Market or Limit")
+)
+
+const defaultBatchConcurrency = 10
+
+// OrderRequest holds either a market or a limit order for PlaceOrders
+type OrderRequest struct {
+	Market *MarketOrderParams
+	Limit  *LimitOrderParams
+}
+
+type OrderResult struct {
+	Order *OrderStatus
+	Err   error
+}
+
+type CancelResult struct {
+	OrderID string
+	Err     error
+}
+
+// SetBatchConcurrency caps the number of in-flight requests used by PlaceOrders and CancelOrders
+func (dvotc *DVOTCClient) SetBatchConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	dvotc.batchConcurrency.Store(int64(n))
+}
+
+// PlaceOrders pipelines all orders over the orders connection, results are
+// returned in input order and ErrBatchPartialFailure is returned if any failed
+func (dvotc *DVOTCClient) PlaceOrders(ctx context.Context, requests []OrderRequest) ([]OrderResult, error) {
+	results := make([]OrderResult, len(requests))
+	errs := runBounded(ctx, len(requests), int(dvotc.batchConcurrency.Load()), func(ctx context.Context, i int) error {
+		req := requests[i]
+		var order *OrderStatus
+		var err error
+		switch {
+		case req.Market != nil && req.Limit == nil:
+			order, err = dvotc.placeMarketOrder(ctx, *req.Market)
+		case req.Limit != nil && req.Market == nil:
+			order, err = dvotc.placeLimitOrder(ctx, *req.Limit)
+		default:
+			err = ErrInvalidOrderRequest
+		}
+		results[i].Order = order
+		return err
+	})
+
+	failed := 0
+	for i, err := range errs {
+		results[i].Err = err
+		if err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%w: %d of %d orders failed", ErrBatchPartialFailure, failed, len(requests))
+	}
+	return results, nil
+}
+
+// CancelOrders pipelines cancellations over the orders connection, results are
+// returned in input order and ErrBatchPartialFailure is returned if any failed
+func (dvotc *DVOTCClient) CancelOrders(ctx context.Context, orderIDs []string) ([]CancelResult, error) {
+	results := make([]CancelResult, len(orderIDs))
+	errs := runBounded(ctx, len(orderIDs), int(dvotc.batchConcurrency.Load()), func(ctx context.Context, i int) error {
+		return dvotc.cancelOrder(ctx, orderIDs[i])
+	})
+
+	failed := 0
+	for i, err := range errs {
+		results[i] = CancelResult{OrderID: orderIDs[i], Err: err}
+		if err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%w: %d of %d cancellations failed", ErrBatchPartialFailure, failed, len(orderIDs))
+	}
+	return results, nil
+}
+
+func (dvotc *DVOTCClient) cancelOrder(ctx context.Context, orderID string) error {
+	if _, err := dvotc.orderRequest(ctx, fmt.Sprintf("cancelorder/%s", orderID), nil); err != nil {
+		return err
+	}
+	dvotc.orders.setStatus(orderID, OrderStatusCancelled)
+	return nil
+}
+
+// runBounded calls fn for every index with at most limit calls in flight,
+// indexes not started before ctx is done get ctx.Err()
+func runBounded(ctx context.Context, n, limit int, fn func(ctx context.Context, i int) error) []error {
+	errs := make([]error, n)
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		if err := ctx.Err(); err != nil {
+			errs[i] = err
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = fn(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
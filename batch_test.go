@@ -0,0 +1,147 @@
+package dvotcWS_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupBatchServer(t *testing.T) *routerWebsocketServer {
+	return &routerWebsocketServer{
+		t: t,
+		handlers: map[string]func(req dvotcWS.Payload) []dvotcWS.Payload{
+			"createorder": func(req dvotcWS.Payload) []dvotcWS.Payload {
+				order := dvotcWS.Order{}
+				require.NoError(t, json.Unmarshal(req.Data, &order))
+				if order.ClientTag == "reject" {
+					req.Type = dvotcWS.MessageTypeError
+					req.Data = []byte(`{"message": "insufficient limits"}`)
+					return []dvotcWS.Payload{req}
+				}
+				status := dvotcWS.OrderStatus{
+					ID:           "id-" + order.ClientTag,
+					ClientTag:    order.ClientTag,
+					Quantity:     order.Qty,
+					Side:         order.Side,
+					OrderType:    order.OrderType,
+					Asset:        order.Asset,
+					CounterAsset: order.CounterAsset,
+					Status:       dvotcWS.OrderStatusOpen,
+					CreatedAt:    time.Now().UTC(),
+				}
+				data, err := json.Marshal(status)
+				require.NoError(t, err)
+				req.Data = data
+				return []dvotcWS.Payload{req}
+			},
+			"cancelorder/": func(req dvotcWS.Payload) []dvotcWS.Payload {
+				req.Data = nil
+				if strings.HasSuffix(req.Topic, "unknown") {
+					req.Type = dvotcWS.MessageTypeError
+					req.Data = []byte(`{"message": "order not found"}`)
+				}
+				return []dvotcWS.Payload{req}
+			},
+		},
+	}
+}
+
+func TestPlaceOrders(t *testing.T) {
+	wsServer := setupBatchServer(t)
+	url := setupRouterWebsocketServer(wsServer)
+	defer wsServer.StopServer()
+
+	client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+	client.SetBatchConcurrency(3)
+
+	requests := make([]dvotcWS.OrderRequest, 0)
+	for i := 0; i < 12; i++ {
+		tag := fmt.Sprintf("tag-%d", i)
+		if i == 5 {
+			tag = "reject"
+		}
+		if i%2 == 0 {
+			requests = append(requests, dvotcWS.OrderRequest{
+				Limit: &dvotcWS.LimitOrderParams{Asset: "BTC", CounterAsset: "USD", LimitPrice: 20000, Qty: 1, Side: "Buy", ClientTag: tag},
+			})
+			continue
+		}
+		requests = append(requests, dvotcWS.OrderRequest{
+			Market: &dvotcWS.MarketOrderParams{Asset: "BTC", CounterAsset: "USD", Price: 20000, Qty: 1, Side: "Sell", ClientTag: tag},
+		})
+	}
+	// neither market nor limit set
+	requests = append(requests, dvotcWS.OrderRequest{})
+
+	results, err := client.PlaceOrders(context.Background(), requests)
+	require.ErrorIs(t, err, dvotcWS.ErrBatchPartialFailure)
+	require.Len(t, results, len(requests))
+
+	for i, res := range results[:12] {
+		if i == 5 {
+			assert.ErrorContains(t, res.Err, "insufficient limits")
+			assert.Nil(t, res.Order)
+			continue
+		}
+		require.NoError(t, res.Err)
+		assert.Equal(t, fmt.Sprintf("id-tag-%d", i), res.Order.ID)
+	}
+	assert.ErrorIs(t, results[12].Err, dvotcWS.ErrInvalidOrderRequest)
+
+	// successful orders are tracked as open
+	assert.Len(t, client.OpenOrders(), 11)
+	assert.Len(t, wsServer.Requests("createorder"), 12)
+}
+
+func TestPlaceOrders_ContextCancelled(t *testing.T) {
+	wsServer := setupBatchServer(t)
+	url := setupRouterWebsocketServer(wsServer)
+	defer wsServer.StopServer()
+
+	client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := client.PlaceOrders(ctx, []dvotcWS.OrderRequest{
+		{Market: &dvotcWS.MarketOrderParams{Asset: "BTC", CounterAsset: "USD", Qty: 1, Side: "Buy"}},
+		{Market: &dvotcWS.MarketOrderParams{Asset: "BTC", CounterAsset: "USD", Qty: 1, Side: "Buy"}},
+	})
+	require.ErrorIs(t, err, dvotcWS.ErrBatchPartialFailure)
+	for _, res := range results {
+		assert.ErrorIs(t, res.Err, context.Canceled)
+	}
+}
+
+func TestCancelOrders(t *testing.T) {
+	wsServer := setupBatchServer(t)
+	url := setupRouterWebsocketServer(wsServer)
+	defer wsServer.StopServer()
+
+	client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+
+	t.Run("all_cancelled", func(t *testing.T) {
+		ids := []string{"a", "b", "c", "d"}
+		results, err := client.CancelOrders(context.Background(), ids)
+		require.NoError(t, err)
+		for i, res := range results {
+			assert.Equal(t, ids[i], res.OrderID)
+			assert.NoError(t, res.Err)
+		}
+	})
+
+	t.Run("partial_failure", func(t *testing.T) {
+		ids := []string{"a", "unknown", "c"}
+		results, err := client.CancelOrders(context.Background(), ids)
+		require.ErrorIs(t, err, dvotcWS.ErrBatchPartialFailure)
+		assert.NoError(t, results[0].Err)
+		assert.ErrorContains(t, results[1].Err, "order not found")
+		assert.NoError(t, results[2].Err)
+	})
+}
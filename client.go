@@ -1,9 +1,7 @@
 package dvotcWS
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,6 +10,7 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/avast/retry-go/v4"
@@ -22,6 +21,19 @@ var (
 	ErrClientConnectionNotFound  = errors.New("client connection not established")
 	ErrInvalidPayload            = errors.New("invalid payload returned")
 	ErrSubscriptionAlreadyClosed = errors.New("subscription is already closed")
+	// ErrMalformedMessage is returned for frames that don't decode to a Payload, the connection is still usable
+	ErrMalformedMessage = errors.New("malformed message")
+	ErrRequestTimeout   = errors.New("request timed out")
+)
+
+const (
+	// defaultRequestTimeout bounds how long order requests wait for their response
+	defaultRequestTimeout = 30 * time.Second
+	// defaultTimeWindow is sent as dv-timewindow, how long after dv-timestamp the server accepts the handshake
+	defaultTimeWindow = 20 * time.Second
+	// defaultConnectAttempts and defaultConnectDelay bound the retries of retryConnWithPayload
+	defaultConnectAttempts = 10
+	defaultConnectDelay    = 1 * time.Second
 )
 
 type MessageType string
@@ -49,9 +61,10 @@ type tradeData struct {
 }
 
 type DVOTCClient struct {
-	wsURL     string
-	apiSecret string
-	apiKey    string
+	wsURL string
+
+	signerMu sync.RWMutex
+	signer   Signer
 
 	requestID int
 
@@ -62,6 +75,26 @@ type DVOTCClient struct {
 
 	chanMutex sync.RWMutex
 	mu        sync.Mutex
+
+	risk   atomic.Pointer[riskManager]
+	halted atomic.Bool
+	// placing orders the kill switch against placements answered meanwhile,
+	// killed holds the orders the last KillSwitch cancels
+	placing  sync.Mutex
+	killed   map[string]bool
+	orders   *orderTracker
+	expiries *expiryScheduler
+
+	orderUpdatesMu sync.Mutex
+	orderUpdates   *Subscription[OrderStatus]
+
+	batchConcurrency atomic.Int64
+	requestTimeout   atomic.Int64
+	timeWindow       atomic.Int64
+	connectAttempts  atomic.Int64
+	connectDelay     atomic.Int64
+	clockSkew        atomic.Int64
+	latency          atomic.Int64
 }
 
 type Payload struct {
@@ -77,15 +110,34 @@ type ErrorResponse struct {
 }
 
 func NewDVOTCClient(wsURL, apiKey, apiSecret string) *DVOTCClient {
-	return &DVOTCClient{
+	return NewDVOTCClientWithSigner(wsURL, NewHMACSigner(StaticCredentials{APIKey: apiKey, APISecret: apiSecret}))
+}
+
+// NewDVOTCClientWithCredentials signs handshakes with the credentials of provider,
+// which is asked again for every new connection
+func NewDVOTCClientWithCredentials(wsURL string, provider CredentialsProvider) *DVOTCClient {
+	return NewDVOTCClientWithSigner(wsURL, NewHMACSigner(provider))
+}
+
+// NewDVOTCClientWithSigner signs handshakes with signer, the client never sees the secret
+func NewDVOTCClientWithSigner(wsURL string, signer Signer) *DVOTCClient {
+	dvotc := &DVOTCClient{
 		wsURL:          wsURL,
-		apiKey:         apiKey,
-		apiSecret:      apiSecret,
+		signer:         signer,
 		wsConnStore:    make(map[connectionTypes]*websocket.Conn),
 		orderChanStore: make(map[string]tradeData),
 		levelChanStore: make(map[string][]chan *LevelData),
+		orders:         newOrderTracker(),
+		expiries:       newExpiryScheduler(),
 		requestID:      10,
 	}
+	dvotc.orders.resolved = dvotc.riskOrderResolved
+	dvotc.batchConcurrency.Store(defaultBatchConcurrency)
+	dvotc.requestTimeout.Store(int64(defaultRequestTimeout))
+	dvotc.timeWindow.Store(int64(defaultTimeWindow))
+	dvotc.connectAttempts.Store(defaultConnectAttempts)
+	dvotc.connectDelay.Store(int64(defaultConnectDelay))
+	return dvotc
 }
 
 func (dvotc *DVOTCClient) retryConnWithPayload(payload Payload) (conn *websocket.Conn, err error) {
@@ -103,40 +155,112 @@ func (dvotc *DVOTCClient) retryConnWithPayload(payload Payload) (conn *websocket
 		}
 		return nil
 	},
-		retry.Delay(1*time.Second))
+		retry.Attempts(uint(dvotc.connectAttempts.Load())),
+		retry.Delay(time.Duration(dvotc.connectDelay.Load())))
 
 	return
 }
 
 func (dvotc *DVOTCClient) getConn() (*websocket.Conn, error) {
-	// need it in milliseconds
-	ts := time.Now().UnixMilli()
-	var timeWindow int64 = 20000
+	conn, skewCorrected, err := dvotc.dial()
+	if skewCorrected && errors.Is(err, ErrAuthenticationFailed) {
+		// the rejection told us how far off our clock is, sign again with the correction
+		conn, _, err = dvotc.dial()
+	}
+	return conn, err
+}
 
-	msg := fmt.Sprintf("%s%d%d", dvotc.apiKey, ts, timeWindow)
+// dial opens a connection signed with the local time corrected by ClockSkew,
+// skewCorrected is set when the handshake response moved the skew estimate
+func (dvotc *DVOTCClient) dial() (conn *websocket.Conn, skewCorrected bool, err error) {
+	skew := dvotc.ClockSkew()
+	sent := time.Now()
+	// need it in milliseconds
+	ts := sent.Add(skew).UnixMilli()
+	timeWindow := time.Duration(dvotc.timeWindow.Load()).Milliseconds()
 
-	h := hmac.New(sha256.New, []byte(dvotc.apiSecret))
-	if _, err := h.Write([]byte(msg)); err != nil {
-		return nil, err
+	dvotc.signerMu.RLock()
+	signer := dvotc.signer
+	dvotc.signerMu.RUnlock()
+	apiKey, signature, err := signer.Sign(context.Background(), ts, timeWindow)
+	if err != nil {
+		return nil, false, fmt.Errorf("sign handshake: %w", err)
 	}
 
-	signature := base64.StdEncoding.EncodeToString(h.Sum(nil))
 	u, err := url.Parse(dvotc.wsURL + "/websocket")
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	header := http.Header{}
 	header.Set("dv-timestamp", fmt.Sprintf("%d", ts))
 	header.Set("dv-timewindow", fmt.Sprintf("%d", timeWindow))
 	header.Set("dv-signature", signature)
-	header.Set("dv-api-key", dvotc.apiKey)
+	header.Set("dv-api-key", apiKey)
 
-	c, _, err := websocket.DefaultDialer.Dial(u.String(), header)
+	c, resp, err := websocket.DefaultDialer.Dial(u.String(), header)
+	if resp != nil {
+		skewCorrected = dvotc.observeServerDate(resp.Header, sent, time.Now(), skew)
+	}
 	if err != nil {
-		return nil, err
+		if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			return nil, skewCorrected, newAuthError(resp, dvotc.ClockSkew())
+		}
+		return nil, skewCorrected, err
 	}
 
-	return c, nil
+	return c, skewCorrected, nil
+}
+
+// SetRequestTimeout bounds how long order requests wait for their response, defaults
+// to 30 seconds, zero waits until the connection fails or the context is done
+func (dvotc *DVOTCClient) SetRequestTimeout(d time.Duration) {
+	dvotc.requestTimeout.Store(int64(d))
+}
+
+// withRequestTimeout bounds ctx by the request timeout, if one is set
+func (dvotc *DVOTCClient) withRequestTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d := time.Duration(dvotc.requestTimeout.Load()); d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return context.WithCancel(ctx)
+}
+
+// SetSigner replaces the signer of the client, connections already open are kept
+func (dvotc *DVOTCClient) SetSigner(signer Signer) {
+	dvotc.signerMu.Lock()
+	defer dvotc.signerMu.Unlock()
+	dvotc.signer = signer
+}
+
+// SetCredentialsProvider signs the next handshakes with the credentials of provider
+func (dvotc *DVOTCClient) SetCredentialsProvider(provider CredentialsProvider) {
+	dvotc.SetSigner(NewHMACSigner(provider))
+}
+
+// SetTimeWindow sets how long after its timestamp the server accepts the handshake,
+// defaults to 20 seconds
+func (dvotc *DVOTCClient) SetTimeWindow(d time.Duration) {
+	dvotc.timeWindow.Store(int64(d))
+}
+
+// SetConnectRetries sets how many times sending a request is attempted on a new
+// connection and the base delay between attempts, defaults to 10 attempts and 1 second, zero attempts retries until it succeeds
+func (dvotc *DVOTCClient) SetConnectRetries(attempts uint, delay time.Duration) {
+	dvotc.connectAttempts.Store(int64(attempts))
+	dvotc.connectDelay.Store(int64(delay))
+}
+
+// readPayload reads the next frame of conn, frames that are not valid JSON
+// return ErrMalformedMessage and can be skipped
+func readPayload(conn *websocket.Conn, p *Payload) error {
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, p); err != nil {
+		return fmt.Errorf("%w: %s", ErrMalformedMessage, err)
+	}
+	return nil
 }
 
 // writeBinaryMessage allows to write only one message to connection
@@ -165,6 +289,8 @@ func (dvotc *DVOTCClient) getConnOrReuse(t connectionTypes) (*websocket.Conn, er
 		go dvotc.readLevelMessageLoop(c)
 	case connectionOrders:
 		go dvotc.readOrderMessageLoop(c, func() {
+			dvotc.mu.Lock()
+			defer dvotc.mu.Unlock()
 			delete(dvotc.wsConnStore, t)
 		})
 	}
@@ -191,6 +317,7 @@ func (dvotc *DVOTCClient) Ping() error {
 		Event: dvotc.getRequestID(),
 		Topic: "ping-pong",
 	}
+	sent := time.Now()
 	err = conn.WriteJSON(payload)
 	if err != nil {
 		return err
@@ -200,6 +327,7 @@ func (dvotc *DVOTCClient) Ping() error {
 	if err := conn.ReadJSON(resp); err != nil {
 		return err
 	}
+	dvotc.latency.Store(int64(time.Since(sent)))
 	if resp.Type == "error" {
 		return fmt.Errorf("returned error with message: %s", string(resp.Data))
 	}
@@ -10,6 +10,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync"
 	"testing"
 
 	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
@@ -55,6 +56,24 @@ func TestSettingUpConnectionAndValidatingSecret(t *testing.T) {
 	assert.True(t, isValid, "websocket signature not valid")
 }
 
+func TestSettingUpConnection_EmptyCredentials(t *testing.T) {
+	e := &echoWebsocketServer{
+		t:        t,
+		request:  []byte(`{"type": "ping-pong", "topic": "ping-pong", "event": "10"}`),
+		response: [][]byte{[]byte(`{"type": "ping-pong", "topic": "ping-pong", "event": "10"}`)},
+	}
+	url := setupTestWebsocketServer(e)
+
+	// the handshake is still sent, leaving it to the server to refuse
+	client := dvotcWS.NewDVOTCClient(url+"/websocket", "", "")
+	require.NoError(t, client.Ping())
+
+	assert.Empty(t, e.apiKey)
+	isValid, err := Verify([]byte(e.timestamp+e.timeWindow), nil, e.signature)
+	assert.NoError(t, err)
+	assert.True(t, isValid, "websocket signature not valid")
+}
+
 func TestSettingUpConnection_Fail(t *testing.T) {
 	apiKey := faker.UUIDHyphenated()
 	apiSecret := "das87d8sa7d98a7s89dhb"
@@ -204,3 +223,104 @@ func (e *echoV2WebsocketServer) StopServer() error {
 	close(e.rrChan)
 	return e.conn.Close()
 }
+
+// routerWebsocketServer answers every request on every connection by topic,
+// handlers are matched on the topic prefix so "cancelorder/" serves all cancellations
+type routerWebsocketServer struct {
+	t           *testing.T
+	srv         *httptest.Server
+	mu          sync.Mutex
+	handlers    map[string]func(req dvotcWS.Payload) []dvotcWS.Payload
+	requests    []dvotcWS.Payload
+	subscribers map[string][]*routerConn
+}
+
+type routerConn struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func (c *routerConn) write(p dvotcWS.Payload) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteJSON(p)
+}
+
+func setupRouterWebsocketServer(r *routerWebsocketServer) string {
+	srv := httptest.NewServer(http.HandlerFunc(r.handler))
+	u, _ := url.Parse(srv.URL)
+	u.Scheme = "ws"
+	r.srv = srv
+	return u.String()
+}
+
+func (r *routerWebsocketServer) handler(w http.ResponseWriter, req *http.Request) {
+	conn, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot upgrade: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+
+	rc := &routerConn{conn: conn}
+	for {
+		p := dvotcWS.Payload{}
+		if err := conn.ReadJSON(&p); err != nil {
+			return
+		}
+
+		r.mu.Lock()
+		r.requests = append(r.requests, p)
+		if p.Type == dvotcWS.MessageTypeSubscribe {
+			if r.subscribers == nil {
+				r.subscribers = make(map[string][]*routerConn)
+			}
+			r.subscribers[p.Topic] = append(r.subscribers[p.Topic], rc)
+		}
+		var handle func(req dvotcWS.Payload) []dvotcWS.Payload
+		for prefix, h := range r.handlers {
+			if strings.HasPrefix(p.Topic, prefix) {
+				handle = h
+				break
+			}
+		}
+		r.mu.Unlock()
+		if handle == nil {
+			continue
+		}
+
+		go func(p dvotcWS.Payload) {
+			for _, resp := range handle(p) {
+				if err := rc.write(resp); err != nil {
+					return
+				}
+			}
+		}(p)
+	}
+}
+
+// Publish writes payload to every connection subscribed to its topic
+func (r *routerWebsocketServer) Publish(p dvotcWS.Payload) {
+	r.mu.Lock()
+	conns := append([]*routerConn{}, r.subscribers[p.Topic]...)
+	r.mu.Unlock()
+	for _, c := range conns {
+		_ = c.write(p)
+	}
+}
+
+func (r *routerWebsocketServer) Requests(topicPrefix string) []dvotcWS.Payload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	requests := make([]dvotcWS.Payload, 0)
+	for _, p := range r.requests {
+		if strings.HasPrefix(p.Topic, topicPrefix) {
+			requests = append(requests, p)
+		}
+	}
+	return requests
+}
+
+func (r *routerWebsocketServer) StopServer() {
+	r.srv.Close()
+}
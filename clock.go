@@ -0,0 +1,82 @@
+package dvotcWS
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var ErrAuthenticationFailed = errors.New("authentication failed")
+
+// dateResolution is the precision of the HTTP Date header, smaller skews are not corrected
+const dateResolution = time.Second
+
+// AuthError is returned when the server rejects the handshake, errors.Is(err,
+// ErrAuthenticationFailed) holds for it
+type AuthError struct {
+	StatusCode int
+	// Message is the reason given by the server, if any
+	Message string
+	// ClockSkew is the estimated offset of the server clock when the handshake was
+	// rejected, a skew close to the time window points at the local clock
+	ClockSkew time.Duration
+}
+
+func newAuthError(resp *http.Response, skew time.Duration) *AuthError {
+	e := &AuthError{StatusCode: resp.StatusCode, ClockSkew: skew}
+	if resp.Body != nil {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		e.Message = strings.TrimSpace(string(body))
+	}
+	return e
+}
+
+func (e *AuthError) Error() string {
+	msg := fmt.Sprintf("%s: %d %s", ErrAuthenticationFailed, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.ClockSkew != 0 {
+		msg += fmt.Sprintf(" (server clock skew %s)", e.ClockSkew)
+	}
+	return msg
+}
+
+func (e *AuthError) Unwrap() error {
+	return ErrAuthenticationFailed
+}
+
+// ClockSkew returns how far the server clock is estimated to be ahead of the local
+// one, negative when it is behind. It is measured from the Date header of handshake
+// responses and added to dv-timestamp, skews under a second are reported as zero.
+func (dvotc *DVOTCClient) ClockSkew() time.Duration {
+	return time.Duration(dvotc.clockSkew.Load())
+}
+
+// Latency returns the round trip time of the last Ping, zero before the first one
+func (dvotc *DVOTCClient) Latency() time.Duration {
+	return time.Duration(dvotc.latency.Load())
+}
+
+// observeServerDate updates the skew from the Date header of a handshake response
+// sent and received at the given local times, and reports whether it moved away
+// from previous, the skew the handshake was signed with
+func (dvotc *DVOTCClient) observeServerDate(header http.Header, sent, received time.Time, previous time.Duration) bool {
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		return false
+	}
+	// the server clock read somewhere in the second after date, halfway through the round trip
+	local := sent.Add(received.Sub(sent) / 2)
+	skew := date.Add(dateResolution / 2).Sub(local)
+	if skew > -dateResolution && skew < dateResolution {
+		skew = 0
+	}
+	dvotc.clockSkew.Store(int64(skew))
+
+	moved := skew - previous
+	return moved <= -dateResolution || moved >= dateResolution
+}
@@ -0,0 +1,58 @@
+package dvotcWS_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/dvotctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClockSkewCorrection(t *testing.T) {
+	for _, offset := range []time.Duration{45 * time.Second, -45 * time.Second} {
+		t.Run(offset.String(), func(t *testing.T) {
+			server := dvotctest.NewServer(dvotctest.Config{ClockOffset: offset})
+			defer server.Close()
+			client := server.Client()
+
+			// the first handshake is outside of the time window and measures the skew
+			require.NoError(t, client.Ping())
+			assert.Equal(t, 1, server.AuthFailures())
+			assert.InDelta(t, offset, client.ClockSkew(), float64(1500*time.Millisecond))
+			assert.Greater(t, client.Latency(), time.Duration(0))
+
+			require.NoError(t, client.Ping())
+			assert.Equal(t, 1, server.AuthFailures())
+		})
+	}
+}
+
+func TestClockSkewUnderResolution(t *testing.T) {
+	server := dvotctest.NewServer(dvotctest.Config{ClockOffset: 200 * time.Millisecond})
+	defer server.Close()
+	client := server.Client()
+
+	require.NoError(t, client.Ping())
+	assert.Equal(t, time.Duration(0), client.ClockSkew())
+	assert.Equal(t, 0, server.AuthFailures())
+}
+
+func TestAuthError(t *testing.T) {
+	server := dvotctest.NewServer(dvotctest.Config{})
+	defer server.Close()
+	client := dvotcWS.NewDVOTCClient(server.URL, dvotctest.DefaultAPIKey, "wrong-secret")
+
+	err := client.Ping()
+	require.ErrorIs(t, err, dvotcWS.ErrAuthenticationFailed)
+	var authErr *dvotcWS.AuthError
+	require.True(t, errors.As(err, &authErr))
+	assert.Equal(t, http.StatusUnauthorized, authErr.StatusCode)
+	assert.Equal(t, "invalid signature", authErr.Message)
+	assert.Equal(t, time.Duration(0), authErr.ClockSkew)
+	// a bad secret is not retried, the skew did not move
+	assert.Equal(t, 1, server.AuthFailures())
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+)
+
+// newClient connects with the -profile of the -config file, see dvotcWS.LoadConfig
+func newClient() (*dvotcWS.DVOTCClient, error) {
+	cfg, err := dvotcWS.LoadConfig(globals.configPath)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.NewClient(globals.profile)
+}
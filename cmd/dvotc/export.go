@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/export"
+)
+
+func runExport(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", "csv", "output format, csv or jsonl")
+	out := flags.String("out", "", "output file, defaults to stdout")
+	tz := flags.String("tz", "UTC", "timezone of the exported times, e.g. America/New_York")
+	from := flags.String("from", "", "only trades filled at or after this RFC3339 time or date (2006-01-02)")
+	to := flags.String("to", "", "only trades filled before this RFC3339 time or date (2006-01-02)")
+	asset := flags.String("asset", "", "only trades of this asset")
+	counterAsset := flags.String("counter-asset", "", "only trades against this counter asset")
+	side := flags.String("side", "", "only Buy or Sell trades")
+	status := flags.String("status", "", "only trades with this status")
+	clientTags := flags.String("client-tags", "", "comma separated client tags")
+	pageSize := flags.Int("page-size", 0, "trades requested at a time")
+	cursorPaging := flags.Bool("cursor-paging", false, "page the trade history by cursor, for servers supporting it")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	location, err := time.LoadLocation(*tz)
+	if err != nil {
+		return err
+	}
+	query := dvotcWS.NewTradeQuery().
+		Asset(*asset).
+		CounterAsset(*counterAsset).
+		Side(*side).
+		Status(*status).
+		Limit(*pageSize)
+	if *cursorPaging {
+		query.CursorPaging()
+	}
+	if *clientTags != "" {
+		query.ClientTags(strings.Split(*clientTags, ",")...)
+	}
+	fromTime, err := parseTime(*from, location)
+	if err != nil {
+		return err
+	}
+	toTime, err := parseTime(*to, location)
+	if err != nil {
+		return err
+	}
+	query.Between(fromTime, toTime)
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	w := stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	writer, err := export.NewWriter(export.Format(*format), w, export.Options{Location: location})
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	n, err := export.WriteIterator(writer, client.Trades(ctx, query))
+	if err != nil {
+		return fmt.Errorf("exported %d trades: %w", n, err)
+	}
+	if *out != "" {
+		fmt.Fprintf(stdout, "exported %d trades to %s\n", n, *out)
+	}
+	return nil
+}
+
+// parseTime accepts RFC3339 times or dates in location, an empty value is the zero time
+func parseTime(value string, location *time.Location) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, location)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// errInterrupted is returned by readLine when the line is abandoned with Ctrl-C
+var errInterrupted = errors.New("interrupted")
+
+// lineReader reads the lines typed at the repl, io.EOF ends the session
+type lineReader interface {
+	readLine(prompt string) (string, error)
+	close()
+}
+
+// newLineReader edits lines in raw mode when stdin is a terminal, with tab
+// completion from complete and history, otherwise it reads plain lines
+func newLineReader(in *os.File, out io.Writer, complete func(line string) []string) lineReader {
+	restore, err := makeRaw(int(in.Fd()))
+	if err != nil {
+		return &plainReader{scanner: bufio.NewScanner(in), out: out}
+	}
+	return &termReader{in: bufio.NewReader(in), out: out, complete: complete, restore: restore}
+}
+
+type plainReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *plainReader) readLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+func (r *plainReader) close() {}
+
+// termReader is a minimal line editor: typing and erasing at the end of the
+// line, Tab to complete, Up and Down to recall history, Ctrl-U to clear,
+// Ctrl-C to abandon the line and Ctrl-D on an empty line to quit
+type termReader struct {
+	in       *bufio.Reader
+	out      io.Writer
+	complete func(line string) []string
+	restore  func()
+	history  []string
+}
+
+const (
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyTab       = 9
+	keyLF        = 10
+	keyCR        = 13
+	keyCtrlU     = 21
+	keyEscape    = 27
+	keyBackspace = 127
+	keyCtrlH     = 8
+)
+
+func (r *termReader) readLine(prompt string) (string, error) {
+	line := []rune{}
+	recalled := len(r.history)
+	redraw := func() { fmt.Fprint(r.out, "\r"+ansiClearLine+prompt+string(line)) }
+	redraw()
+	for {
+		key, _, err := r.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch key {
+		case keyCR, keyLF:
+			fmt.Fprint(r.out, "\r\n")
+			if s := strings.TrimSpace(string(line)); s != "" {
+				r.history = append(r.history, s)
+			}
+			return string(line), nil
+		case keyCtrlC:
+			fmt.Fprint(r.out, "^C\r\n")
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(line) == 0 {
+				fmt.Fprint(r.out, "\r\n")
+				return "", io.EOF
+			}
+		case keyBackspace, keyCtrlH:
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+			}
+		case keyCtrlU:
+			line = line[:0]
+		case keyTab:
+			line = []rune(r.completeLine(string(line)))
+		case keyEscape:
+			// only the arrows are handled, ESC [ A is Up and ESC [ B is Down
+			if next, _, _ := r.in.ReadRune(); next != '[' {
+				continue
+			}
+			arrow, _, _ := r.in.ReadRune()
+			switch {
+			case arrow == 'A' && recalled > 0:
+				recalled--
+				line = []rune(r.history[recalled])
+			case arrow == 'B' && recalled < len(r.history):
+				recalled++
+				line = line[:0]
+				if recalled < len(r.history) {
+					line = []rune(r.history[recalled])
+				}
+			}
+		default:
+			if key >= ' ' {
+				line = append(line, key)
+			}
+		}
+		redraw()
+	}
+}
+
+// completeLine extends line to the longest prefix shared by its completions,
+// listing them when there is more than one and none is longer
+func (r *termReader) completeLine(line string) string {
+	candidates := r.complete(line)
+	if len(candidates) == 0 {
+		return line
+	}
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(candidates) == 1 {
+		return prefix + " "
+	}
+	if len(prefix) <= len(line) {
+		sort.Strings(candidates)
+		words := make([]string, len(candidates))
+		for i, c := range candidates {
+			words[i] = c[strings.LastIndex(c, " ")+1:]
+		}
+		fmt.Fprint(r.out, "\r\n"+strings.Join(words, "  ")+"\r\n")
+	}
+	return prefix
+}
+
+func (r *termReader) close() {
+	r.restore()
+}
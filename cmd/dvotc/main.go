@@ -0,0 +1,95 @@
+// Command dvotc is a command line client for the DVOTC websocket API
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+var errUsage = errors.New("usage")
+
+// globals are the flags given before the command
+var globals struct {
+	configPath string
+	profile    string
+	output     string
+}
+
+type command struct {
+	usage string
+	run   func(args []string, stdout io.Writer) error
+}
+
+var commands = map[string]command{
+	"ping":          {usage: "ping                             check connectivity and credentials", run: runPing},
+	"symbols":       {usage: "symbols                          list the available symbols", run: runSymbols},
+	"limits":        {usage: "limits                           show limits and positions", run: runLimits},
+	"trades":        {usage: "trades [flags]                   list the trades matching the flags", run: runTrades},
+	"order":         {usage: "order market|limit [flags]       place an order", run: runOrder},
+	"cancel":        {usage: "cancel <order-id>...             cancel open orders", run: runCancel},
+	"levels":        {usage: "levels [-n N] <symbol>...        stream levels until interrupted", run: runLevels},
+	"notifications": {usage: "notifications [-n N] <topic>...  stream notifications, e.g. ORDER_FILLED", run: runNotifications},
+	"repl":          {usage: "repl [flags]                     interactive shell to quote, trade and cancel", run: runRepl},
+	"watch":         {usage: "watch [flags] <symbol>...        live ladder of levels with limits and notifications", run: runWatch},
+	"export":        {usage: "export [flags]                   write the trade blotter as csv or jsonl", run: runExport},
+	"reconcile":     {usage: "reconcile [flags]                match a fills ledger against DV Chain trades, exits 3 on breaks", run: runReconcile},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("dvotc", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&globals.configPath, "config", "", "YAML, TOML or JSON config file, defaults to $DVOTC_CONFIG or ~/.config/dvotc/config.yaml")
+	flags.StringVar(&globals.profile, "profile", "", "profile of the config file, defaults to $DVOTC_PROFILE, the default profile or sandbox")
+	flags.StringVar(&globals.output, "o", outputTable, "output format, table or json")
+	flags.Usage = func() { usage(stderr) }
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	args = flags.Args()
+	if globals.output != outputTable && globals.output != outputJSON {
+		fmt.Fprintf(stderr, "unknown output %q\n", globals.output)
+		return 2
+	}
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	if err := cmd.run(args[1:], stdout); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(stderr, "usage: dvotc %s\n", cmd.usage)
+			return 2
+		}
+		if errors.Is(err, errBreaks) {
+			return exitBreaks
+		}
+		fmt.Fprintf(stderr, "%s: %s\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: dvotc [-config file] [-profile name] [-o table|json] <command> [flags]")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n", commands[name].usage)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/dvotctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupServer points the commands at a fake server through the environment, with
+// no config file of the machine running the tests in the way
+func setupServer(t *testing.T) *dvotctest.Server {
+	srv := dvotctest.NewServer(dvotctest.Config{
+		Balances: dvotcWS.AssetBalance{
+			Assets:     []dvotcWS.Asset{{Asset: "BTC", MaxBuy: 10, MaxSell: 5, Position: 2}},
+			UsdBalance: 100000,
+		},
+	})
+	t.Cleanup(srv.Close)
+	srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 20010, SellPrice: 19990, MaxQuantity: 10})
+	srv.SetLevels("ETH/USD", dvotcWS.Level{BuyPrice: 1510, SellPrice: 1490, MaxQuantity: 50})
+
+	home := t.TempDir()
+	for env, value := range map[string]string{
+		"HOME":             home,
+		"XDG_CONFIG_HOME":  home,
+		"DVOTC_CONFIG":     "",
+		"DVOTC_PROFILE":    "",
+		"DVOTC_WS_URL":     srv.URL,
+		"DVOTC_API_KEY":    dvotctest.DefaultAPIKey,
+		"DVOTC_API_SECRET": dvotctest.DefaultAPISecret,
+	} {
+		t.Setenv(env, value)
+	}
+	return srv
+}
+
+func runCLI(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestUsage(t *testing.T) {
+	setupServer(t)
+	for _, tc := range []struct {
+		name   string
+		args   []string
+		stderr string
+	}{
+		{name: "no_command", args: nil, stderr: "usage: dvotc [-config file]"},
+		{name: "unknown_command", args: []string{"balance"}, stderr: `unknown command "balance"`},
+		{name: "unknown_flag", args: []string{"-verbose", "ping"}, stderr: "flag provided but not defined: -verbose"},
+		{name: "unknown_output", args: []string{"-o", "xml", "ping"}, stderr: `unknown output "xml"`},
+		{name: "ping_args", args: []string{"ping", "now"}, stderr: "usage: dvotc ping "},
+		{name: "order_type", args: []string{"order", "stop"}, stderr: "usage: dvotc order market|limit"},
+		{name: "order_symbol", args: []string{"order", "market", "-symbol", "BTC", "-side", "Buy", "-qty", "1"}, stderr: "usage: dvotc order"},
+		{name: "order_qty", args: []string{"order", "market", "-symbol", "BTC/USD", "-side", "Buy"}, stderr: "usage: dvotc order"},
+		{name: "limit_price", args: []string{"order", "limit", "-symbol", "BTC/USD", "-side", "Buy", "-qty", "1"}, stderr: "usage: dvotc order"},
+		{name: "cancel_ids", args: []string{"cancel"}, stderr: "usage: dvotc cancel"},
+		{name: "trades_flag", args: []string{"trades", "-page", "2"}, stderr: "usage: dvotc trades"},
+		{name: "levels_symbol", args: []string{"levels"}, stderr: "usage: dvotc levels"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			code, stdout, stderr := runCLI(tc.args...)
+			assert.Equal(t, 2, code)
+			assert.Empty(t, stdout)
+			assert.Contains(t, stderr, tc.stderr)
+		})
+	}
+}
+
+func TestCommands(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{
+			name:   "symbols_table",
+			args:   []string{"symbols"},
+			stdout: "SYMBOL\nBTC/USD\nETH/USD\n",
+		},
+		{
+			name:   "symbols_json",
+			args:   []string{"-o", "json", "symbols"},
+			stdout: "[\n  \"BTC/USD\",\n  \"ETH/USD\"\n]\n",
+		},
+		{
+			name: "limits_table",
+			args: []string{"limits"},
+			stdout: "ASSET        POSITION  MAX BUY  MAX SELL\n" +
+				"BTC          2         10       5\n" +
+				"USD balance  100000             \n",
+		},
+		{
+			name:   "order_limit_table",
+			args:   []string{"order", "limit", "-symbol", "BTC/USD", "-side", "Buy", "-qty", "1", "-price", "19000", "-client-tag", "bid"},
+			stdout: "ID       STATUS  TYPE   SIDE  QTY  SYMBOL   PRICE  LIMIT  CLIENT TAG\norder-1  Open    LIMIT  Buy   1    BTC/USD  0      19000  bid\n",
+		},
+		{
+			name:   "order_unknown_symbol",
+			args:   []string{"order", "limit", "-symbol", "SOL/USD", "-side", "Buy", "-qty", "1", "-price", "20"},
+			code:   1,
+			stderr: "order: unknown symbol: SOL/USD\n",
+		},
+		{
+			name:   "cancel_missing",
+			args:   []string{"cancel", "order-9"},
+			code:   1,
+			stdout: "ID       RESULT\norder-9  {\"message\":\"order not found: order-9\",\"code\":400}\n",
+			stderr: "cancel: 1 of 1 cancels failed\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			setupServer(t)
+			code, stdout, stderr := runCLI(tc.args...)
+			assert.Equal(t, tc.code, code)
+			assert.Equal(t, tc.stdout, stdout)
+			assert.Equal(t, tc.stderr, stderr)
+		})
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	t.Run("ping", func(t *testing.T) {
+		setupServer(t)
+		code, stdout, _ := runCLI("-o", "json", "ping")
+		require.Equal(t, 0, code)
+		var result map[string]any
+		require.NoError(t, json.Unmarshal([]byte(stdout), &result))
+		assert.Equal(t, true, result["ok"])
+		assert.Contains(t, result, "latencyMs")
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		setupServer(t)
+		code, stdout, _ := runCLI("-o", "json", "limits")
+		require.Equal(t, 0, code)
+		var balances dvotcWS.AssetBalance
+		require.NoError(t, json.Unmarshal([]byte(stdout), &balances))
+		assert.Equal(t, 100000.0, balances.UsdBalance)
+		require.Len(t, balances.Assets, 1)
+		assert.Equal(t, 10.0, balances.Assets[0].MaxBuy)
+	})
+
+	t.Run("market_order_and_trades", func(t *testing.T) {
+		srv := setupServer(t)
+		code, stdout, stderr := runCLI("-o", "json", "order", "market", "-symbol", "BTC/USD", "-side", "Buy", "-qty", "2", "-client-tag", "lift")
+		require.Equal(t, 0, code, stderr)
+		var order dvotcWS.OrderStatus
+		require.NoError(t, json.Unmarshal([]byte(stdout), &order))
+		assert.Equal(t, dvotcWS.OrderStatusComplete, order.Status)
+		assert.Equal(t, 20010.0, order.Price)
+		assert.Equal(t, "lift", order.ClientTag)
+		// the order took the current quote
+		levels, _ := srv.Levels("BTC/USD")
+		require.Len(t, srv.Requests("createorder"), 1)
+		assert.Contains(t, string(srv.Requests("createorder")[0].Data), levels.QuoteID)
+
+		code, stdout, _ = runCLI("-o", "json", "trades", "-side", "buy")
+		require.Equal(t, 0, code)
+		var trades []dvotcWS.Trade
+		require.NoError(t, json.Unmarshal([]byte(stdout), &trades))
+		require.Len(t, trades, 1)
+		assert.Equal(t, order.ID, trades[0].ID)
+
+		code, stdout, _ = runCLI("trades", "-side", "Sell")
+		require.Equal(t, 0, code)
+		assert.Equal(t, "ID  CREATED  SIDE  QTY  SYMBOL  PRICE  STATUS  CLIENT TAG", strings.TrimSpace(stdout))
+	})
+}
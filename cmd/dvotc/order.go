@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+)
+
+// quoteTimeout bounds the wait for levels when a market order has no -quote
+const quoteTimeout = 10 * time.Second
+
+func runOrder(args []string, stdout io.Writer) error {
+	if len(args) == 0 || (args[0] != "market" && args[0] != "limit") {
+		return errUsage
+	}
+	orderType := args[0]
+	flags := flag.NewFlagSet("order "+orderType, flag.ContinueOnError)
+	symbol := flags.String("symbol", "", "symbol to trade, e.g. BTC/USD")
+	side := flags.String("side", "", "Buy or Sell")
+	qty := flags.Float64("qty", 0, "quantity of the asset")
+	price := flags.Float64("price", 0, "limit price, or for market orders the expected price, defaults to the quoted one")
+	quote := flags.String("quote", "", "quote ID of a market order, defaults to the current quote")
+	clientTag := flags.String("client-tag", "", "client tag of the order")
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+	assets := strings.SplitN(*symbol, "/", 2)
+	if len(assets) != 2 || *side == "" || *qty <= 0 || (orderType == "limit" && *price <= 0) {
+		return errUsage
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	var order *dvotcWS.OrderStatus
+	if orderType == "limit" {
+		order, err = client.PlaceLimitOrder(dvotcWS.LimitOrderParams{
+			Asset:        assets[0],
+			CounterAsset: assets[1],
+			LimitPrice:   *price,
+			Qty:          *qty,
+			Side:         *side,
+			ClientTag:    *clientTag,
+		})
+	} else {
+		params := dvotcWS.MarketOrderParams{
+			QuoteID:      *quote,
+			Asset:        assets[0],
+			CounterAsset: assets[1],
+			Price:        *price,
+			Qty:          *qty,
+			Side:         *side,
+			ClientTag:    *clientTag,
+		}
+		if params.QuoteID == "" {
+			if err := currentQuote(client, *symbol, &params); err != nil {
+				return err
+			}
+		}
+		order, err = client.PlaceMarketOrder(params)
+	}
+	if err != nil {
+		return err
+	}
+	return printOrder(stdout, order)
+}
+
+// currentQuote sets the quote ID of params, and its price when not set, from the
+// first levels of symbol
+func currentQuote(client *dvotcWS.DVOTCClient, symbol string, params *dvotcWS.MarketOrderParams) error {
+	levels, err := firstLevels(client, symbol)
+	if err != nil {
+		return err
+	}
+	price, ok := levels.PriceFor(params.Side, params.Qty)
+	if !ok {
+		return fmt.Errorf("no level of %s deep enough for %v", symbol, params.Qty)
+	}
+	params.QuoteID = levels.QuoteID
+	if params.Price == 0 {
+		params.Price = price
+	}
+	return nil
+}
+
+// firstLevels subscribes to symbol for its next levels
+func firstLevels(client *dvotcWS.DVOTCClient, symbol string) (*dvotcWS.LevelData, error) {
+	sub, err := client.SubscribeLevels(symbol)
+	if err != nil {
+		return nil, err
+	}
+	defer sub.StopConsuming()
+
+	select {
+	case levels := <-sub.Data:
+		return levels, nil
+	case <-time.After(quoteTimeout):
+		return nil, fmt.Errorf("no levels received for %s", symbol)
+	}
+}
+
+var orderHeader = []string{"ID", "STATUS", "TYPE", "SIDE", "QTY", "SYMBOL", "PRICE", "LIMIT", "CLIENT TAG"}
+
+func orderRow(order dvotcWS.OrderStatus) []string {
+	return []string{
+		order.ID, order.Status, order.OrderType, order.Side, formatFloat(order.Quantity),
+		order.Asset + "/" + order.CounterAsset, formatFloat(order.Price), order.LimitPrice, order.ClientTag,
+	}
+}
+
+func printOrder(w io.Writer, order *dvotcWS.OrderStatus) error {
+	return printResult(w, order, orderHeader, [][]string{orderRow(*order)})
+}
+
+func runCancel(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	type result struct {
+		ID    string `json:"id"`
+		Error string `json:"error,omitempty"`
+	}
+	results := make([]result, 0, len(args))
+	rows := make([][]string, 0, len(args))
+	failed := 0
+	for _, id := range args {
+		r, status := result{ID: id}, "cancelled"
+		if err := client.CancelOrder(id); err != nil {
+			r.Error, status = err.Error(), err.Error()
+			failed++
+		}
+		results = append(results, r)
+		rows = append(rows, []string{id, status})
+	}
+	if err := printResult(stdout, results, []string{"ID", "RESULT"}, rows); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d cancels failed", failed, len(args))
+	}
+	return nil
+}
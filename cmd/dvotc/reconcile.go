@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/reconcile"
+)
+
+// errBreaks exits with exitBreaks once the report is written
+var errBreaks = errors.New("reconciliation breaks found")
+
+const exitBreaks = 3
+
+func runReconcile(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	ledger := flags.String("ledger", "", "internal fills, .csv or .jsonl with client tag, qty, price and optionally side and status")
+	from := flags.String("from", "", "also report trades filled at or after this time (RFC3339 or 2006-01-02) missing from the ledger")
+	to := flags.String("to", "", "end of the -from window, open when empty")
+	batchSize := flags.Int("batch-size", 0, "client tags per ListTrades request")
+	priceTolerance := flags.Float64("price-tolerance", 0, "largest price difference not reported")
+	qtyTolerance := flags.Float64("qty-tolerance", 0, "largest quantity difference not reported")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if *ledger == "" {
+		flags.Usage()
+		return errUsage
+	}
+
+	fills, err := reconcile.LoadFills(*ledger)
+	if err != nil {
+		return err
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	trades, err := reconcile.FetchTrades(client, fills, *batchSize)
+	if err != nil {
+		return err
+	}
+
+	if *from != "" {
+		fromTime, err := parseTime(*from, time.UTC)
+		if err != nil {
+			return err
+		}
+		toTime, err := parseTime(*to, time.UTC)
+		if err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		window, err := client.Trades(ctx, dvotcWS.NewTradeQuery().Between(fromTime, toTime)).All()
+		if err != nil {
+			return err
+		}
+		trades = append(trades, window...)
+	}
+
+	report := reconcile.Reconcile(fills, trades, reconcile.Options{
+		PriceTolerance: *priceTolerance,
+		QtyTolerance:   *qtyTolerance,
+	})
+	if err := report.WriteText(stdout); err != nil {
+		return err
+	}
+	if report.HasBreaks() {
+		return errBreaks
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+)
+
+func runPing(args []string, stdout io.Writer) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	if err := client.Ping(); err != nil {
+		return err
+	}
+	latency, skew := client.Latency(), client.ClockSkew()
+	if globals.output == outputJSON {
+		return printJSON(stdout, map[string]any{"ok": true, "latencyMs": latency.Milliseconds(), "clockSkewMs": skew.Milliseconds()})
+	}
+	_, err = fmt.Fprintf(stdout, "ok (latency %s, clock skew %s)\n", latency.Round(time.Millisecond), skew)
+	return err
+}
+
+func runSymbols(args []string, stdout io.Writer) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	symbols, err := client.ListAvailableSymbols()
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		rows = append(rows, []string{symbol})
+	}
+	return printResult(stdout, symbols, []string{"SYMBOL"}, rows)
+}
+
+func runLimits(args []string, stdout io.Writer) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	balances, err := client.ListLimitsBalances()
+	if err != nil {
+		return err
+	}
+	return printLimits(stdout, balances)
+}
+
+func printLimits(w io.Writer, balances *dvotcWS.AssetBalance) error {
+	rows := make([][]string, 0, len(balances.Assets)+1)
+	for _, asset := range balances.Assets {
+		rows = append(rows, []string{asset.Asset, formatFloat(asset.Position), formatFloat(asset.MaxBuy), formatFloat(asset.MaxSell)})
+	}
+	rows = append(rows, []string{"USD balance", formatFloat(balances.UsdBalance), "", ""})
+	return printResult(w, balances, []string{"ASSET", "POSITION", "MAX BUY", "MAX SELL"}, rows)
+}
+
+func runTrades(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("trades", flag.ContinueOnError)
+	ids := flags.String("ids", "", "comma separated trade IDs")
+	clientTags := flags.String("client-tags", "", "comma separated client tags")
+	from := flags.String("from", "", "only trades filled at or after this RFC3339 time or date (2006-01-02)")
+	to := flags.String("to", "", "only trades filled before this RFC3339 time or date (2006-01-02)")
+	asset := flags.String("asset", "", "only trades of this asset")
+	counterAsset := flags.String("counter-asset", "", "only trades against this counter asset")
+	side := flags.String("side", "", "only Buy or Sell trades")
+	status := flags.String("status", "", "only trades with this status")
+	limit := flags.Int("limit", 50, "most trades listed, 0 lists every trade")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	query := dvotcWS.NewTradeQuery().
+		Asset(*asset).
+		CounterAsset(*counterAsset).
+		Side(*side).
+		Status(*status)
+	if *ids != "" {
+		query.IDs(strings.Split(*ids, ",")...)
+	}
+	if *clientTags != "" {
+		query.ClientTags(strings.Split(*clientTags, ",")...)
+	}
+	fromTime, err := parseTime(*from, time.UTC)
+	if err != nil {
+		return err
+	}
+	toTime, err := parseTime(*to, time.UTC)
+	if err != nil {
+		return err
+	}
+	query.Between(fromTime, toTime)
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	it := client.Trades(ctx, query)
+	trades := make([]dvotcWS.Trade, 0)
+	for (*limit <= 0 || len(trades) < *limit) && it.Next() {
+		trades = append(trades, it.Trade())
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(trades))
+	for _, t := range trades {
+		rows = append(rows, []string{
+			t.ID, formatTime(t.CreatedAt), t.Side, fmt.Sprint(t.Quantity), t.Asset + "/" + t.CounterAsset,
+			formatFloat(t.Price), t.Status, t.ClientTag,
+		})
+	}
+	return printResult(stdout, trades, []string{"ID", "CREATED", "SIDE", "QTY", "SYMBOL", "PRICE", "STATUS", "CLIENT TAG"}, rows)
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+)
+
+var errQuit = errors.New("quit")
+
+// replCommands are the repl commands and their usage
+var replCommands = map[string]string{
+	"quote":   "quote <symbol> [qty]                   levels of symbol, or its prices for qty",
+	"buy":     "buy <symbol> <qty> market|limit [price] buy at the current quote or at a limit price",
+	"sell":    "sell <symbol> <qty> market|limit [price] sell at the current quote or at a limit price",
+	"cancel":  "cancel <order-id>...                   cancel open orders",
+	"orders":  "orders                                 list the open orders",
+	"limits":  "limits                                 show limits and positions",
+	"symbols": "symbols                                list the available symbols",
+	"help":    "help                                   list the commands",
+	"exit":    "exit                                   end the session, as does Ctrl-D",
+}
+
+func runRepl(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("repl", flag.ContinueOnError)
+	confirmAbove := flags.Float64("confirm-above", 100000, "ask to confirm orders with a notional above this, negative never asks")
+	transcript := flags.String("transcript", defaultTranscriptPath(), "file the session is appended to, empty disables it")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	symbols, err := client.ListAvailableSymbols()
+	if err != nil {
+		return err
+	}
+	sort.Strings(symbols)
+	if err := client.TrackOrderUpdates(); err != nil {
+		return err
+	}
+	defer client.StopTrackingOrderUpdates()
+
+	r := &repl{
+		client:       client,
+		symbols:      symbols,
+		confirmAbove: *confirmAbove,
+		out:          stdout,
+	}
+	if *transcript != "" {
+		if err := os.MkdirAll(filepath.Dir(*transcript), 0o700); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(*transcript, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r.transcript = f
+		r.out = io.MultiWriter(stdout, f)
+		fmt.Fprintf(stdout, "transcript: %s\n", *transcript)
+	}
+	r.lines = newLineReader(os.Stdin, stdout, r.complete)
+	defer r.lines.close()
+	return r.run()
+}
+
+// defaultTranscriptPath is a new file per session next to the config file
+func defaultTranscriptPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "dvotc", "transcripts", time.Now().Format("20060102-150405")+".log")
+}
+
+type repl struct {
+	client       *dvotcWS.DVOTCClient
+	symbols      []string
+	confirmAbove float64
+
+	lines lineReader
+	// out is stdout, and the transcript when there is one
+	out        io.Writer
+	transcript io.Writer
+}
+
+func (r *repl) run() error {
+	r.log("session started")
+	defer r.log("session ended")
+	for {
+		line, err := r.readLine("dvotc> ")
+		if errors.Is(err, errInterrupted) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = r.execute(strings.Fields(line))
+		switch {
+		case errors.Is(err, errQuit):
+			return nil
+		case errors.Is(err, errUsage):
+			fmt.Fprintf(r.out, "usage: %s\n", replCommands[strings.Fields(line)[0]])
+		case err != nil:
+			fmt.Fprintf(r.out, "error: %s\n", err)
+		}
+	}
+}
+
+// readLine prompts for a line and records it in the transcript
+func (r *repl) readLine(prompt string) (string, error) {
+	line, err := r.lines.readLine(prompt)
+	if err == nil {
+		r.log(prompt + line)
+	}
+	return line, err
+}
+
+// log writes a timestamped line to the transcript only
+func (r *repl) log(text string) {
+	if r.transcript != nil {
+		fmt.Fprintf(r.transcript, "# %s %s\n", time.Now().UTC().Format(time.RFC3339), text)
+	}
+}
+
+func (r *repl) execute(fields []string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	cmd, args := fields[0], fields[1:]
+	switch cmd {
+	case "quote":
+		return r.quote(args)
+	case "buy":
+		return r.order("Buy", args)
+	case "sell":
+		return r.order("Sell", args)
+	case "cancel":
+		return r.cancel(args)
+	case "orders":
+		orders := r.client.OpenOrders()
+		sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+		rows := make([][]string, 0, len(orders))
+		for _, order := range orders {
+			rows = append(rows, orderRow(order))
+		}
+		return printResult(r.out, orders, orderHeader, rows)
+	case "limits":
+		balances, err := r.client.ListLimitsBalances()
+		if err != nil {
+			return err
+		}
+		return printLimits(r.out, balances)
+	case "symbols":
+		_, err := fmt.Fprintln(r.out, strings.Join(r.symbols, "  "))
+		return err
+	case "help":
+		names := make([]string, 0, len(replCommands))
+		for name := range replCommands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(r.out, "  %s\n", replCommands[name])
+		}
+		return nil
+	case "exit", "quit":
+		return errQuit
+	}
+	return fmt.Errorf("unknown command %q, try help", cmd)
+}
+
+func (r *repl) quote(args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return errUsage
+	}
+	levels, err := firstLevels(r.client, args[0])
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		rows := make([][]string, 0, len(levels.Levels))
+		for _, level := range levels.Levels {
+			rows = append(rows, []string{formatFloat(level.MaxQuantity), formatFloat(level.BuyPrice), formatFloat(level.SellPrice)})
+		}
+		fmt.Fprintf(r.out, "%s  quote %s\n", args[0], levels.QuoteID)
+		return printTable(r.out, []string{"QTY", "BUY", "SELL"}, rows)
+	}
+
+	qty, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || qty <= 0 {
+		return errUsage
+	}
+	buy, ok := levels.PriceFor("Buy", qty)
+	if !ok {
+		return fmt.Errorf("no level of %s deep enough for %v", args[0], qty)
+	}
+	sell, _ := levels.PriceFor("Sell", qty)
+	_, err = fmt.Fprintf(r.out, "%s %s  buy %s  sell %s  quote %s\n",
+		args[0], formatFloat(qty), formatFloat(buy), formatFloat(sell), levels.QuoteID)
+	return err
+}
+
+func (r *repl) order(side string, args []string) error {
+	if len(args) < 3 {
+		return errUsage
+	}
+	symbol, orderType := args[0], args[2]
+	assets := strings.SplitN(symbol, "/", 2)
+	qty, err := strconv.ParseFloat(args[1], 64)
+	if len(assets) != 2 || err != nil || qty <= 0 {
+		return errUsage
+	}
+
+	// place is called with confirmed set once the order was confirmed at the prompt
+	var place func(confirmed bool) (*dvotcWS.OrderStatus, error)
+	var price float64
+	switch {
+	case orderType == "market" && len(args) == 3:
+		params := dvotcWS.MarketOrderParams{Asset: assets[0], CounterAsset: assets[1], Qty: qty, Side: side}
+		if err := currentQuote(r.client, symbol, &params); err != nil {
+			return err
+		}
+		price = params.Price
+		place = func(confirmed bool) (*dvotcWS.OrderStatus, error) {
+			if confirmed {
+				// the quote may have moved or expired while the prompt was answered
+				requoted := dvotcWS.MarketOrderParams{Asset: assets[0], CounterAsset: assets[1], Qty: qty, Side: side}
+				if err := currentQuote(r.client, symbol, &requoted); err != nil {
+					return nil, err
+				}
+				if requoted.Price != params.Price {
+					return nil, fmt.Errorf("quote moved from %s to %s while confirming, not sent", formatFloat(params.Price), formatFloat(requoted.Price))
+				}
+				params = requoted
+			}
+			return r.client.PlaceMarketOrder(params)
+		}
+	case orderType == "limit" && len(args) == 4:
+		price, err = strconv.ParseFloat(args[3], 64)
+		if err != nil || price <= 0 {
+			return errUsage
+		}
+		params := dvotcWS.LimitOrderParams{Asset: assets[0], CounterAsset: assets[1], LimitPrice: price, Qty: qty, Side: side}
+		place = func(bool) (*dvotcWS.OrderStatus, error) { return r.client.PlaceLimitOrder(params) }
+	default:
+		return errUsage
+	}
+
+	notional := qty * price
+	confirm := r.confirmAbove >= 0 && notional > r.confirmAbove
+	if confirm {
+		answer, err := r.readLine(fmt.Sprintf("%s %s %s %s at %s, notional %s %s. Send? [y/N] ",
+			side, formatFloat(qty), symbol, orderType, formatFloat(price), formatFloat(notional), assets[1]))
+		// Ctrl-C or Ctrl-D decline like any other answer
+		if err != nil && !errors.Is(err, errInterrupted) && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			_, err := fmt.Fprintln(r.out, "not sent")
+			return err
+		}
+	}
+	order, err := place(confirm)
+	if err != nil {
+		return err
+	}
+	return printOrder(r.out, order)
+}
+
+func (r *repl) cancel(ids []string) error {
+	if len(ids) == 0 {
+		return errUsage
+	}
+	for _, id := range ids {
+		if err := r.client.CancelOrder(id); err != nil {
+			fmt.Fprintf(r.out, "%s: %s\n", id, err)
+			continue
+		}
+		fmt.Fprintf(r.out, "%s: cancelled\n", id)
+	}
+	return nil
+}
+
+// complete returns the lines line can be completed to: command names, then
+// symbols, order types or open order IDs depending on the command
+func (r *repl) complete(line string) []string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasSuffix(line, " ") {
+		fields = append(fields, "")
+	}
+	word, done := fields[len(fields)-1], fields[:len(fields)-1]
+
+	var candidates []string
+	switch {
+	case len(done) == 0:
+		for name := range replCommands {
+			candidates = append(candidates, name)
+		}
+	case len(done) == 1 && (done[0] == "quote" || done[0] == "buy" || done[0] == "sell"):
+		candidates = r.symbols
+	case len(done) == 3 && (done[0] == "buy" || done[0] == "sell"):
+		candidates = []string{"market", "limit"}
+	case done[0] == "cancel":
+		for _, order := range r.client.OpenOrders() {
+			candidates = append(candidates, order.ID)
+		}
+	}
+
+	head := strings.Join(done, " ")
+	if head != "" {
+		head += " "
+	}
+	completions := make([]string, 0)
+	for _, c := range candidates {
+		if strings.HasPrefix(strings.ToUpper(c), strings.ToUpper(word)) {
+			completions = append(completions, head+c)
+		}
+	}
+	sort.Strings(completions)
+	return completions
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplComplete(t *testing.T) {
+	srv := setupServer(t)
+	client := srv.Client()
+	order, err := client.PlaceLimitOrder(dvotcWS.LimitOrderParams{Asset: "BTC", CounterAsset: "USD", LimitPrice: 19000, Qty: 1, Side: "Buy"})
+	require.NoError(t, err)
+	r := &repl{client: client, symbols: []string{"BTC/USD", "ETH/USD"}}
+
+	for _, tc := range []struct {
+		line string
+		want []string
+	}{
+		{line: "qu", want: []string{"quote"}},
+		{line: "c", want: []string{"cancel"}},
+		{line: "quote ", want: []string{"quote BTC/USD", "quote ETH/USD"}},
+		{line: "buy e", want: []string{"buy ETH/USD"}},
+		{line: "sell BTC/USD 1 ", want: []string{"sell BTC/USD 1 limit", "sell BTC/USD 1 market"}},
+		{line: "sell BTC/USD 1 m", want: []string{"sell BTC/USD 1 market"}},
+		{line: "cancel ", want: []string{"cancel " + order.ID}},
+		{line: "orders ", want: []string{}},
+		{line: "xyz", want: []string{}},
+	} {
+		assert.Equal(t, tc.want, r.complete(tc.line), tc.line)
+	}
+}
+
+// scriptedLines answers the prompts of the repl with lines, then ends the session
+type scriptedLines struct {
+	lines   []string
+	prompts []string
+	// prompted is called with every prompt before it is answered
+	prompted func(prompt string)
+}
+
+func (s *scriptedLines) readLine(prompt string) (string, error) {
+	s.prompts = append(s.prompts, prompt)
+	if s.prompted != nil {
+		s.prompted(prompt)
+	}
+	if len(s.lines) == 0 {
+		return "", io.EOF
+	}
+	line := s.lines[0]
+	s.lines = s.lines[1:]
+	return line, nil
+}
+
+func (s *scriptedLines) close() {}
+
+// runScript runs a repl session reading lines, it returns its stdout and transcript
+func runScript(t *testing.T, r *repl, lines *scriptedLines) (string, string) {
+	var stdout, transcript bytes.Buffer
+	r.lines = lines
+	r.out = io.MultiWriter(&stdout, &transcript)
+	r.transcript = &transcript
+	require.NoError(t, r.run())
+	return stdout.String(), transcript.String()
+}
+
+func TestReplOrderConfirmation(t *testing.T) {
+	const confirmPrompt = "Buy 1 BTC/USD market at 20010, notional 20010 USD. Send? [y/N] "
+	for _, tc := range []struct {
+		name    string
+		lines   []string
+		moveTo  float64
+		placed  int
+		prompts []string
+		stdout  []string
+	}{
+		{name: "below_threshold", lines: []string{"buy BTC/USD 0.5 market"}, placed: 1, stdout: []string{"Buy", "20010"}},
+		{name: "declined", lines: []string{"buy BTC/USD 1 market", "n"}, prompts: []string{confirmPrompt}, stdout: []string{"not sent"}},
+		{name: "no_answer", lines: []string{"buy BTC/USD 1 market", ""}, prompts: []string{confirmPrompt}, stdout: []string{"not sent"}},
+		{name: "end_of_input", lines: []string{"buy BTC/USD 1 market"}, prompts: []string{confirmPrompt}, stdout: []string{"not sent"}},
+		{name: "accepted", lines: []string{"buy BTC/USD 1 market", "Y"}, prompts: []string{confirmPrompt}, placed: 1, stdout: []string{"Buy"}},
+		{
+			name: "accepted_limit", lines: []string{"sell BTC/USD 1 limit 21000", "yes"}, placed: 1, stdout: []string{"Sell"},
+			prompts: []string{"Sell 1 BTC/USD limit at 21000, notional 21000 USD. Send? [y/N] "},
+		},
+		// the order goes out with the quote taken after the prompt
+		{name: "requoted", lines: []string{"buy BTC/USD 1 market", "y"}, moveTo: 20010, prompts: []string{confirmPrompt}, placed: 1, stdout: []string{"Buy"}},
+		{
+			name: "quote_moved", lines: []string{"buy BTC/USD 1 market", "y"}, moveTo: 20500, prompts: []string{confirmPrompt},
+			stdout: []string{"error: quote moved from 20010 to 20500 while confirming, not sent"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := setupServer(t)
+			r := &repl{client: srv.Client(), confirmAbove: 15000}
+			lines := &scriptedLines{lines: tc.lines}
+			if tc.moveTo != 0 {
+				lines.prompted = func(prompt string) {
+					if strings.HasSuffix(prompt, "Send? [y/N] ") {
+						srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: tc.moveTo, SellPrice: 19990, MaxQuantity: 10})
+					}
+				}
+			}
+
+			stdout, _ := runScript(t, r, lines)
+			for _, want := range tc.stdout {
+				assert.Contains(t, stdout, want)
+			}
+			prompts := make([]string, 0)
+			for _, prompt := range lines.prompts {
+				if prompt != "dvotc> " {
+					prompts = append(prompts, prompt)
+				}
+			}
+			assert.Equal(t, append([]string{}, tc.prompts...), prompts)
+			assert.Len(t, srv.Orders(), tc.placed)
+		})
+	}
+}
+
+func TestReplTranscript(t *testing.T) {
+	srv := setupServer(t)
+	r := &repl{client: srv.Client(), symbols: []string{"BTC/USD"}, confirmAbove: 100000}
+
+	stdout, transcript := runScript(t, r, &scriptedLines{lines: []string{"quote BTC/USD 2", "sell BTC/USD 10 market", "yes", "bogus", "exit"}})
+	require.Len(t, srv.Orders(), 1)
+
+	// the transcript is the output with the typed lines and the session bounds logged
+	logged := regexp.MustCompile(`(?m)^# \d{4}-\d\d-\d\dT\d\d:\d\d:\d\dZ (.*)\n`)
+	entries := make([]string, 0)
+	for _, m := range logged.FindAllStringSubmatch(transcript, -1) {
+		entries = append(entries, m[1])
+	}
+	assert.Equal(t, []string{
+		"session started",
+		"dvotc> quote BTC/USD 2",
+		"dvotc> sell BTC/USD 10 market",
+		"Sell 10 BTC/USD market at 19990, notional 199900 USD. Send? [y/N] yes",
+		"dvotc> bogus",
+		"dvotc> exit",
+		"session ended",
+	}, entries)
+	assert.Equal(t, stdout, logged.ReplaceAllString(transcript, ""), "output missing from the transcript")
+}
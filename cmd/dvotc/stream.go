@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/strategy"
+)
+
+func runLevels(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("levels", flag.ContinueOnError)
+	count := flags.Int("n", 0, "stop after this many updates, 0 streams until interrupted")
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		return errUsage
+	}
+	return stream(strategy.Config{Symbols: flags.Args()}, *count, func(ev strategy.Event) error {
+		if globals.output == outputJSON {
+			return printJSONLine(stdout, ev.Levels)
+		}
+		fmt.Fprintf(stdout, "%s  quote %s  %s\n", ev.Symbol, ev.Levels.QuoteID, formatTime(ev.Time))
+		rows := make([][]string, 0, len(ev.Levels.Levels))
+		for _, level := range ev.Levels.Levels {
+			rows = append(rows, []string{formatFloat(level.MaxQuantity), formatFloat(level.BuyPrice), formatFloat(level.SellPrice)})
+		}
+		if err := printTable(stdout, []string{"QTY", "BUY", "SELL"}, rows); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(stdout)
+		return err
+	})
+}
+
+func runNotifications(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("notifications", flag.ContinueOnError)
+	count := flags.Int("n", 0, "stop after this many notifications, 0 streams until interrupted")
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		return errUsage
+	}
+	// topics may be given space or comma separated
+	topics := strings.FieldsFunc(strings.Join(flags.Args(), ","), func(r rune) bool { return r == ',' })
+	for i := range topics {
+		topics[i] = strings.ToUpper(strings.TrimSpace(topics[i]))
+	}
+
+	return stream(strategy.Config{Notifications: topics}, *count, func(ev strategy.Event) error {
+		if globals.output == outputJSON {
+			return printJSONLine(stdout, map[string]any{"time": ev.Time.UTC(), "topic": ev.Topic, "data": ev.Notification})
+		}
+		_, err := fmt.Fprintf(stdout, "%s  %-16s  %s\n", ev.Time.UTC().Format(time.RFC3339), ev.Topic, notificationText(ev.Notification))
+		return err
+	})
+}
+
+// notificationText is the text of order notifications, or the JSON of the others
+func notificationText(notification any) string {
+	if n, ok := notification.(dvotcWS.OrderNotification); ok && n.Text != "" {
+		return n.Text
+	}
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Sprint(notification)
+	}
+	return string(data)
+}
+
+// stream calls print for the events of cfg until count events were printed or
+// the command is interrupted
+func stream(cfg strategy.Config, count int, print func(strategy.Event) error) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	feed, err := strategy.NewLiveFeed(client, cfg)
+	if err != nil {
+		return err
+	}
+	defer feed.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	for printed := 0; count <= 0 || printed < count; printed++ {
+		ev, err := feed.Next(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err := print(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
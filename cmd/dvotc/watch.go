@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/strategy"
+)
+
+// ANSI escapes used to redraw the screen in place
+const (
+	ansiHome       = "\x1b[H"
+	ansiClearLine  = "\x1b[K"
+	ansiClearBelow = "\x1b[J"
+	ansiHideCursor = "\x1b[?25l"
+	ansiShowCursor = "\x1b[?25h"
+	ansiReset      = "\x1b[0m"
+	ansiBold       = "\x1b[1m"
+	ansiDim        = "\x1b[2m"
+	ansiRed        = "\x1b[31m"
+	ansiGreen      = "\x1b[32m"
+)
+
+func runWatch(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	refresh := flags.Duration("refresh", 500*time.Millisecond, "interval between redraws")
+	limitsInterval := flags.Duration("limits", 10*time.Second, "interval between limits refreshes, LIMIT_CHANGED also refreshes them")
+	topics := flags.String("topics", "ORDER_CREATED,ORDER_FILLED,ORDER_CANCELLED,LIMIT_CHANGED", "comma separated notification topics shown")
+	history := flags.Int("history", 10, "notifications shown")
+	noColor := flags.Bool("no-color", os.Getenv("NO_COLOR") != "", "do not color price changes")
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 || *refresh <= 0 {
+		return errUsage
+	}
+	if globals.output == outputJSON {
+		return errors.New("watch only has a table output, use levels or notifications for json")
+	}
+	var notifications []string
+	for _, topic := range strings.Split(*topics, ",") {
+		if topic = strings.ToUpper(strings.TrimSpace(topic)); topic != "" {
+			notifications = append(notifications, topic)
+		}
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	feed, err := strategy.NewLiveFeed(client, strategy.Config{
+		Symbols:       flags.Args(),
+		Notifications: notifications,
+		TimerInterval: *refresh,
+	})
+	if err != nil {
+		return err
+	}
+	defer feed.Close()
+
+	view := newWatchView(flags.Args(), *history, !*noColor)
+	var limitsAt time.Time
+	refreshLimits := func() {
+		balances, err := client.ListLimitsBalances()
+		limitsAt = time.Now()
+		view.setLimits(balances, err, limitsAt)
+	}
+
+	fmt.Fprint(stdout, ansiHideCursor)
+	defer fmt.Fprint(stdout, ansiShowCursor)
+	refreshLimits()
+	if err := view.render(stdout, time.Now()); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	for {
+		ev, err := feed.Next(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		switch ev.Kind {
+		case strategy.EventLevels:
+			view.setLevels(ev.Symbol, ev.Levels, ev.Time)
+		case strategy.EventNotification:
+			view.addNotification(ev.Time, ev.Topic, notificationText(ev.Notification))
+			if ev.Topic == dvotcWS.NOTIFICATION_LIMIT_CHANGED {
+				limitsAt = time.Time{}
+			}
+		case strategy.EventTimer:
+			if time.Since(limitsAt) >= *limitsInterval {
+				refreshLimits()
+			}
+			if err := view.render(stdout, time.Now()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// watchView is the state drawn by the watch command
+type watchView struct {
+	symbols []string
+	books   map[string]*watchBook
+	color   bool
+
+	notifications []string
+	history       int
+
+	limits    *dvotcWS.AssetBalance
+	limitsErr error
+	limitsAt  time.Time
+}
+
+// watchBook holds the last two levels of a symbol, the previous ones color the changes
+type watchBook struct {
+	levels     *dvotcWS.LevelData
+	prev       *dvotcWS.LevelData
+	receivedAt time.Time
+}
+
+func newWatchView(symbols []string, history int, color bool) *watchView {
+	return &watchView{
+		symbols: symbols,
+		books:   make(map[string]*watchBook, len(symbols)),
+		color:   color,
+		history: history,
+	}
+}
+
+func (v *watchView) setLevels(symbol string, levels *dvotcWS.LevelData, at time.Time) {
+	book, ok := v.books[symbol]
+	if !ok {
+		book = &watchBook{}
+		v.books[symbol] = book
+	}
+	book.prev, book.levels, book.receivedAt = book.levels, levels, at
+}
+
+// addNotification keeps the latest notifications first
+func (v *watchView) addNotification(at time.Time, topic, text string) {
+	line := fmt.Sprintf("%s  %-16s  %s", at.Local().Format("15:04:05"), topic, text)
+	v.notifications = append([]string{line}, v.notifications...)
+	if len(v.notifications) > v.history {
+		v.notifications = v.notifications[:v.history]
+	}
+}
+
+func (v *watchView) setLimits(balances *dvotcWS.AssetBalance, err error, at time.Time) {
+	if err != nil {
+		// keep showing the last limits along with the error
+		v.limitsErr = err
+		return
+	}
+	v.limits, v.limitsErr, v.limitsAt = balances, nil, at
+}
+
+// render redraws the whole view from the top left corner of the terminal
+func (v *watchView) render(w io.Writer, now time.Time) error {
+	var buf bytes.Buffer
+	buf.WriteString(ansiHome)
+	for _, symbol := range v.symbols {
+		v.renderBook(&buf, symbol, now)
+		buf.WriteString("\n")
+	}
+	v.renderLimits(&buf, now)
+	buf.WriteString("\n")
+	v.renderNotifications(&buf)
+	buf.WriteString(ansiClearBelow)
+	// clear what is left of longer lines of the previous frame
+	_, err := w.Write(bytes.ReplaceAll(buf.Bytes(), []byte("\n"), []byte(ansiClearLine+"\n")))
+	return err
+}
+
+func (v *watchView) renderBook(buf *bytes.Buffer, symbol string, now time.Time) {
+	book, ok := v.books[symbol]
+	if !ok {
+		fmt.Fprintf(buf, "%s\n  waiting for levels\n", v.style(ansiBold, symbol))
+		return
+	}
+	levels := book.levels
+	updated := book.receivedAt
+	if levels.LastUpdate != 0 {
+		updated = time.UnixMilli(levels.LastUpdate)
+	}
+	header := fmt.Sprintf("%s  quote %s  updated %s ago", v.style(ansiBold, symbol), levels.QuoteID, age(now, updated))
+	if len(levels.Levels) > 0 {
+		top := levels.Levels[0]
+		spread := top.BuyPrice - top.SellPrice
+		if mid, ok := levels.Mid(); ok && mid != 0 {
+			header += fmt.Sprintf("  spread %s (%.1f bps)", formatFloat(spread), spread/mid*1e4)
+		} else {
+			header += "  spread " + formatFloat(spread)
+		}
+	}
+	buf.WriteString(header + "\n")
+
+	// colors are added after alignment as tabwriter counts escapes as text
+	var table bytes.Buffer
+	tw := tabwriter.NewWriter(&table, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "QTY\tBUY\tSELL\t")
+	for _, level := range levels.Levels {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t\n", formatFloat(level.MaxQuantity), formatFloat(level.BuyPrice), formatFloat(level.SellPrice))
+	}
+	tw.Flush()
+	for i, line := range strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n") {
+		if i == 0 {
+			buf.WriteString("  " + v.style(ansiDim, line) + "\n")
+			continue
+		}
+		buf.WriteString("  " + v.colorLevel(line, book.prev, i-1, levels.Levels[i-1]) + "\n")
+	}
+}
+
+// colorLevel colors the buy and sell prices of line by their change since prev
+func (v *watchView) colorLevel(line string, prev *dvotcWS.LevelData, i int, level dvotcWS.Level) string {
+	if !v.color || prev == nil || i >= len(prev.Levels) {
+		return line
+	}
+	spans := fieldSpans(line)
+	if len(spans) != 3 {
+		return line
+	}
+	was := prev.Levels[i]
+	buy, sell := spans[1], spans[2]
+	// keep the alignment of line by replacing the prices in place, from the right
+	// so the offsets of the buy price still hold
+	line = line[:sell[0]] + v.change(line[sell[0]:sell[1]], level.SellPrice, was.SellPrice) + line[sell[1]:]
+	return line[:buy[0]] + v.change(line[buy[0]:buy[1]], level.BuyPrice, was.BuyPrice) + line[buy[1]:]
+}
+
+// fieldSpans returns the start and end offsets of the space separated fields of line
+func fieldSpans(line string) [][2]int {
+	spans := make([][2]int, 0)
+	start := -1
+	for i, c := range line {
+		switch {
+		case c != ' ' && start < 0:
+			start = i
+		case c == ' ' && start >= 0:
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(line)})
+	}
+	return spans
+}
+
+func (v *watchView) change(text string, price, was float64) string {
+	switch {
+	case price > was:
+		return v.style(ansiGreen, text)
+	case price < was:
+		return v.style(ansiRed, text)
+	}
+	return text
+}
+
+func (v *watchView) renderLimits(buf *bytes.Buffer, now time.Time) {
+	title := "Limits"
+	if !v.limitsAt.IsZero() {
+		title += fmt.Sprintf("  updated %s ago", age(now, v.limitsAt))
+	}
+	buf.WriteString(v.style(ansiBold, title) + "\n")
+	if v.limitsErr != nil {
+		buf.WriteString("  " + v.style(ansiRed, v.limitsErr.Error()) + "\n")
+	}
+	if v.limits == nil {
+		return
+	}
+	var table bytes.Buffer
+	tw := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  ASSET\tPOSITION\tMAX BUY\tMAX SELL")
+	for _, asset := range v.limits.Assets {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", asset.Asset, formatFloat(asset.Position), formatFloat(asset.MaxBuy), formatFloat(asset.MaxSell))
+	}
+	fmt.Fprintf(tw, "  USD balance\t%s\t\t\n", formatFloat(v.limits.UsdBalance))
+	tw.Flush()
+	buf.Write(table.Bytes())
+}
+
+func (v *watchView) renderNotifications(buf *bytes.Buffer) {
+	buf.WriteString(v.style(ansiBold, "Notifications") + "\n")
+	if len(v.notifications) == 0 {
+		buf.WriteString("  none yet\n")
+	}
+	for _, line := range v.notifications {
+		buf.WriteString("  " + line + "\n")
+	}
+}
+
+func (v *watchView) style(code, text string) string {
+	if !v.color {
+		return text
+	}
+	return code + text + ansiReset
+}
+
+// age is the time since t rounded for display
+func age(now, t time.Time) string {
+	d := now.Sub(t)
+	if d < 0 {
+		d = 0
+	}
+	if d < time.Minute {
+		return d.Round(100 * time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
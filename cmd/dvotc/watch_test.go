@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// screen strips the escapes redrawing the screen, leaving the text and colors
+var screen = strings.NewReplacer(ansiHome, "", ansiClearLine, "", ansiClearBelow, "")
+
+func TestWatchRender(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.Local)
+	view := func(color bool) *watchView {
+		v := newWatchView([]string{"BTC/USD", "ETH/USD"}, 2, color)
+		v.setLevels("BTC/USD", &dvotcWS.LevelData{QuoteID: "q-1", Levels: []dvotcWS.Level{
+			{MaxQuantity: 1, BuyPrice: 20010, SellPrice: 19990},
+			{MaxQuantity: 10, BuyPrice: 20020, SellPrice: 19980},
+		}}, now.Add(-3*time.Second))
+		v.setLevels("BTC/USD", &dvotcWS.LevelData{QuoteID: "q-2", Levels: []dvotcWS.Level{
+			{MaxQuantity: 1, BuyPrice: 20020, SellPrice: 19980},
+			{MaxQuantity: 10, BuyPrice: 20020, SellPrice: 19985},
+		}}, now.Add(-1500*time.Millisecond))
+		v.setLimits(&dvotcWS.AssetBalance{Assets: []dvotcWS.Asset{{Asset: "BTC", Position: 2, MaxBuy: 10, MaxSell: 5}}, UsdBalance: 100000}, nil, now.Add(-90*time.Second))
+		v.setLimits(nil, errors.New("limits timed out"), now)
+		for i, topic := range []string{"ORDER_CREATED", "ORDER_FILLED", "ORDER_CANCELLED"} {
+			v.addNotification(now.Add(time.Duration(i)*time.Second), topic, "order-1")
+		}
+		return v
+	}
+
+	for _, tc := range []struct {
+		name  string
+		color bool
+		want  string
+	}{
+		{
+			name: "plain",
+			want: "BTC/USD  quote q-2  updated 1.5s ago  spread 40 (20.0 bps)\n" +
+				"    QTY    BUY   SELL\n" +
+				"      1  20020  19980\n" +
+				"     10  20020  19985\n" +
+				"\n" +
+				"ETH/USD\n" +
+				"  waiting for levels\n" +
+				"\n" +
+				"Limits  updated 1m30s ago\n" +
+				"  limits timed out\n" +
+				"  ASSET        POSITION  MAX BUY  MAX SELL\n" +
+				"  BTC          2         10       5\n" +
+				"  USD balance  100000             \n" +
+				"\n" +
+				"Notifications\n" +
+				"  12:00:02  ORDER_CANCELLED   order-1\n" +
+				"  12:00:01  ORDER_FILLED      order-1\n",
+		},
+		{
+			name:  "color",
+			color: true,
+			want: ansiBold + "BTC/USD" + ansiReset + "  quote q-2  updated 1.5s ago  spread 40 (20.0 bps)\n" +
+				"  " + ansiDim + "  QTY    BUY   SELL" + ansiReset + "\n" +
+				"      1  " + ansiGreen + "20020" + ansiReset + "  " + ansiRed + "19980" + ansiReset + "\n" +
+				"     10  20020  " + ansiGreen + "19985" + ansiReset + "\n" +
+				"\n" +
+				ansiBold + "ETH/USD" + ansiReset + "\n" +
+				"  waiting for levels\n" +
+				"\n" +
+				ansiBold + "Limits  updated 1m30s ago" + ansiReset + "\n" +
+				"  " + ansiRed + "limits timed out" + ansiReset + "\n" +
+				"  ASSET        POSITION  MAX BUY  MAX SELL\n" +
+				"  BTC          2         10       5\n" +
+				"  USD balance  100000             \n" +
+				"\n" +
+				ansiBold + "Notifications" + ansiReset + "\n" +
+				"  12:00:02  ORDER_CANCELLED   order-1\n" +
+				"  12:00:01  ORDER_FILLED      order-1\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			require.NoError(t, view(tc.color).render(&out, now))
+			frame := out.String()
+			assert.True(t, strings.HasPrefix(frame, ansiHome))
+			assert.True(t, strings.HasSuffix(frame, ansiClearBelow))
+			// every line clears what is left of the previous frame
+			assert.Equal(t, strings.Count(frame, "\n"), strings.Count(frame, ansiClearLine+"\n"))
+			assert.Equal(t, tc.want, screen.Replace(frame))
+		})
+	}
+
+	t.Run("no_notifications", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, newWatchView(nil, 5, false).render(&out, now))
+		assert.Equal(t, "Limits\n\nNotifications\n  none yet\n", screen.Replace(out.String()))
+	})
+}
+
+func TestWatchColorLevel(t *testing.T) {
+	green := func(s string) string { return ansiGreen + s + ansiReset }
+	red := func(s string) string { return ansiRed + s + ansiReset }
+	prev := &dvotcWS.LevelData{Levels: []dvotcWS.Level{{MaxQuantity: 1, BuyPrice: 19990, SellPrice: 20010}}}
+
+	for _, tc := range []struct {
+		name  string
+		color bool
+		prev  *dvotcWS.LevelData
+		line  string
+		level dvotcWS.Level
+		want  string
+	}{
+		{
+			name: "changed", color: true, prev: prev,
+			line: "      1  20010  19990", level: dvotcWS.Level{MaxQuantity: 1, BuyPrice: 20010, SellPrice: 19990},
+			want: "      1  " + green("20010") + "  " + red("19990"),
+		},
+		{
+			name: "same_buy_and_sell", color: true, prev: prev,
+			line: "      1  20000  20000", level: dvotcWS.Level{MaxQuantity: 1, BuyPrice: 20000, SellPrice: 20000},
+			want: "      1  " + green("20000") + "  " + red("20000"),
+		},
+		{
+			name: "qty_prints_as_a_price", color: true, prev: prev,
+			line: "  20000  20000  20010", level: dvotcWS.Level{MaxQuantity: 20000, BuyPrice: 20000, SellPrice: 20010},
+			want: "  20000  " + green("20000") + "  20010",
+		},
+		{
+			name: "unchanged", color: true, prev: prev,
+			line: "      1  19990  20010", level: dvotcWS.Level{MaxQuantity: 1, BuyPrice: 19990, SellPrice: 20010},
+			want: "      1  19990  20010",
+		},
+		{
+			name: "no_color", prev: prev,
+			line: "      1  20010  19990", level: dvotcWS.Level{MaxQuantity: 1, BuyPrice: 20010, SellPrice: 19990},
+			want: "      1  20010  19990",
+		},
+		{
+			name: "first_levels", color: true,
+			line: "      1  20010  19990", level: dvotcWS.Level{MaxQuantity: 1, BuyPrice: 20010, SellPrice: 19990},
+			want: "      1  20010  19990",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v := newWatchView(nil, 0, tc.color)
+			assert.Equal(t, tc.want, v.colorLevel(tc.line, tc.prev, 0, tc.level))
+		})
+	}
+
+	// a level deeper than the previous levels has nothing to compare with
+	v := newWatchView(nil, 0, true)
+	assert.Equal(t, "     10  20020  19980", v.colorLevel("     10  20020  19980", prev, 1, dvotcWS.Level{MaxQuantity: 10, BuyPrice: 20020, SellPrice: 19980}))
+}
+
+func TestAge(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		ago  time.Duration
+		want string
+	}{
+		{ago: 0, want: "0s"},
+		{ago: 1234 * time.Millisecond, want: "1.2s"},
+		{ago: 59*time.Second + 960*time.Millisecond, want: "1m0s"},
+		{ago: 90*time.Second + 400*time.Millisecond, want: "1m30s"},
+		{ago: 2 * time.Hour, want: "2h0m0s"},
+		// clocks running ahead never show a negative age
+		{ago: -time.Second, want: "0s"},
+	} {
+		assert.Equal(t, tc.want, age(now, now.Add(-tc.ago)), tc.ago.String())
+	}
+}
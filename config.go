@@ -0,0 +1,245 @@
+package dvotcWS
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrUnknownProfile    = errors.New("unknown profile")
+	ErrIncompleteProfile = errors.New("incomplete profile")
+	ErrUnresolvedSecret  = errors.New("unresolved secret")
+	ErrConfigFormat      = errors.New("unsupported config format")
+)
+
+const (
+	ProfileSandbox    = "sandbox"
+	ProfileProduction = "production"
+
+	SandboxURL    = "wss://sandbox.trade.dvchain.co"
+	ProductionURL = "wss://trade.dvchain.co"
+)
+
+// builtinURLs are used by the profiles of the same name when they have no URL
+var builtinURLs = map[string]string{
+	ProfileSandbox:    SandboxURL,
+	ProfileProduction: ProductionURL,
+}
+
+// Config holds named profiles, read from YAML, TOML or JSON by LoadConfig
+type Config struct {
+	// DefaultProfile is used when no profile is asked for and DVOTC_PROFILE is not set,
+	// defaults to the sandbox
+	DefaultProfile string             `json:"defaultProfile" yaml:"defaultProfile" toml:"defaultProfile"`
+	Profiles       map[string]Profile `json:"profiles" yaml:"profiles" toml:"profiles"`
+}
+
+// Profile is an endpoint, its credentials and the client options to use with it
+type Profile struct {
+	// URL defaults to SandboxURL for the sandbox profile and ProductionURL for production
+	URL       string    `json:"url" yaml:"url" toml:"url"`
+	APIKey    SecretRef `json:"apiKey" yaml:"apiKey" toml:"apiKey"`
+	APISecret SecretRef `json:"apiSecret" yaml:"apiSecret" toml:"apiSecret"`
+
+	// the options below keep the client defaults when zero
+	TimeWindow      Duration `json:"timeWindow" yaml:"timeWindow" toml:"timeWindow"`
+	RequestTimeout  Duration `json:"requestTimeout" yaml:"requestTimeout" toml:"requestTimeout"`
+	ConnectAttempts uint     `json:"connectAttempts" yaml:"connectAttempts" toml:"connectAttempts"`
+	ConnectDelay    Duration `json:"connectDelay" yaml:"connectDelay" toml:"connectDelay"`
+	// Risk enables the pre-trade risk checks when set
+	Risk *RiskConfig `json:"risk" yaml:"risk" toml:"risk"`
+}
+
+// SecretRef is a secret given as "env:NAME" for the value of an environment
+// variable, "file:PATH" for the trimmed content of a file, or as the value itself
+type SecretRef string
+
+// Resolve returns the secret referenced
+func (s SecretRef) Resolve() (string, error) {
+	ref := string(s)
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		name := strings.TrimPrefix(ref, "env:")
+		value := os.Getenv(name)
+		if value == "" {
+			return "", fmt.Errorf("%w: %s is not set", ErrUnresolvedSecret, name)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, "file:"):
+		data, err := os.ReadFile(strings.TrimPrefix(ref, "file:"))
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", ErrUnresolvedSecret, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return ref, nil
+}
+
+// Duration is a time.Duration written as "20s" or "1m30s" in config files
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// LoadConfig reads the config file at path, its format is chosen by its extension:
+// .yaml or .yml, .toml or .json. An empty path reads $DVOTC_CONFIG, or else the first
+// of config.yaml, config.yml, config.toml and config.json in the dvotc directory of
+// os.UserConfigDir, an empty config is returned when none of them exists.
+func LoadConfig(path string) (*Config, error) {
+	if path == "" {
+		path = os.Getenv("DVOTC_CONFIG")
+	}
+	if path == "" {
+		path = defaultConfigPath()
+		if path == "" {
+			return &Config{}, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	case ".json":
+		err = json.Unmarshal(data, cfg)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrConfigFormat, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// defaultConfigPath is the first config file found in the dvotc config directory
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	for _, name := range []string{"config.yaml", "config.yml", "config.toml", "config.json"} {
+		path := filepath.Join(dir, "dvotc", name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// Profile returns the profile called name, or DVOTC_PROFILE, or DefaultProfile, or
+// the sandbox. The sandbox and production profiles exist without being configured.
+// DVOTC_WS_URL, DVOTC_API_KEY and DVOTC_API_SECRET override the sandbox used when no
+// profile is selected, a selected profile only takes them for the values it leaves empty.
+// The credentials are taken as references to the variables, so their values are used
+// literally and never read as "env:" or "file:" references themselves.
+func (c *Config) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = os.Getenv("DVOTC_PROFILE")
+	}
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	selected := name != ""
+	if !selected {
+		name = ProfileSandbox
+	}
+	p, ok := c.Profiles[name]
+	if !ok && builtinURLs[name] == "" {
+		return p, fmt.Errorf("%w: %s", ErrUnknownProfile, name)
+	}
+	if p.URL == "" {
+		p.URL = builtinURLs[name]
+	}
+	if url := os.Getenv("DVOTC_WS_URL"); url != "" && (!selected || p.URL == "") {
+		p.URL = url
+	}
+	if os.Getenv("DVOTC_API_KEY") != "" && (!selected || p.APIKey == "") {
+		p.APIKey = "env:DVOTC_API_KEY"
+	}
+	if os.Getenv("DVOTC_API_SECRET") != "" && (!selected || p.APISecret == "") {
+		p.APISecret = "env:DVOTC_API_SECRET"
+	}
+	return p, nil
+}
+
+// NewClient returns a client for the profile called name, see Profile
+func (c *Config) NewClient(name string) (*DVOTCClient, error) {
+	p, err := c.Profile(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.NewClient()
+}
+
+// NewClient returns a client set up with the options of the profile, enabling risk
+// checks connects to load the limits. The credentials are checked here then resolved
+// again for every handshake, so secrets rotated in their files or variables are picked up.
+func (p Profile) NewClient() (*DVOTCClient, error) {
+	provider := SecretRefCredentials{APIKey: p.APIKey, APISecret: p.APISecret}
+	creds, err := provider.Credentials(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if p.URL == "" || creds.APIKey == "" || creds.APISecret == "" {
+		return nil, fmt.Errorf("%w: url, apiKey and apiSecret are required", ErrIncompleteProfile)
+	}
+
+	dvotc := NewDVOTCClientWithSigner(p.URL, NewHMACSigner(provider).RequireCredentials())
+	if p.TimeWindow != 0 {
+		dvotc.SetTimeWindow(time.Duration(p.TimeWindow))
+	}
+	if p.RequestTimeout != 0 {
+		dvotc.SetRequestTimeout(time.Duration(p.RequestTimeout))
+	}
+	if p.ConnectAttempts != 0 || p.ConnectDelay != 0 {
+		attempts, delay := p.ConnectAttempts, time.Duration(p.ConnectDelay)
+		if attempts == 0 {
+			attempts = defaultConnectAttempts
+		}
+		if delay == 0 {
+			delay = defaultConnectDelay
+		}
+		dvotc.SetConnectRetries(attempts, delay)
+	}
+	if p.Risk != nil {
+		if err := dvotc.EnableRiskChecks(*p.Risk); err != nil {
+			return nil, err
+		}
+	}
+	return dvotc, nil
+}
+
+// NewDVOTCClientFromConfig returns a client for profile from the config file found
+// by LoadConfig, an empty profile picks it as Config.Profile does
+func NewDVOTCClientFromConfig(profile string) (*DVOTCClient, error) {
+	cfg, err := LoadConfig("")
+	if err != nil {
+		return nil, err
+	}
+	return cfg.NewClient(profile)
+}
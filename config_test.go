@@ -0,0 +1,187 @@
+package dvotcWS_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/dvotctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const yamlConfig = `
+defaultProfile: desk
+profiles:
+  desk:
+    url: wss://desk.example
+    apiKey: desk-key
+    apiSecret: env:DESK_SECRET
+    timeWindow: 5s
+    connectAttempts: 3
+    risk:
+      rejectUnknownAssets: true
+      symbols:
+        BTC/USD:
+          maxNotional: 100000
+  production:
+    apiKey: prod-key
+    apiSecret: file:%s
+`
+
+const tomlConfig = `
+defaultProfile = "desk"
+
+[profiles.desk]
+url = "wss://desk.example"
+apiKey = "desk-key"
+apiSecret = "env:DESK_SECRET"
+timeWindow = "5s"
+connectAttempts = 3
+
+[profiles.desk.risk]
+rejectUnknownAssets = true
+
+[profiles.desk.risk.symbols."BTC/USD"]
+maxNotional = 100000
+
+[profiles.production]
+apiKey = "prod-key"
+apiSecret = "file:%s"
+`
+
+const jsonConfig = `{
+  "defaultProfile": "desk",
+  "profiles": {
+    "desk": {
+      "url": "wss://desk.example",
+      "apiKey": "desk-key",
+      "apiSecret": "env:DESK_SECRET",
+      "timeWindow": "5s",
+      "connectAttempts": 3,
+      "risk": {"rejectUnknownAssets": true, "symbols": {"BTC/USD": {"maxNotional": 100000}}}
+    },
+    "production": {"apiKey": "prod-key", "apiSecret": "file:%s"}
+  }
+}`
+
+func clearConfigEnv(t *testing.T) {
+	for _, env := range []string{"DVOTC_CONFIG", "DVOTC_PROFILE", "DVOTC_WS_URL", "DVOTC_API_KEY", "DVOTC_API_SECRET"} {
+		t.Setenv(env, "")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	clearConfigEnv(t)
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("prod-secret\n"), 0o600))
+
+	for name, content := range map[string]string{"config.yaml": yamlConfig, "config.toml": tomlConfig, "config.json": jsonConfig} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			require.NoError(t, os.WriteFile(path, []byte(withSecretFile(content, secretFile)), 0o600))
+
+			cfg, err := dvotcWS.LoadConfig(path)
+			require.NoError(t, err)
+
+			desk, err := cfg.Profile("")
+			require.NoError(t, err)
+			assert.Equal(t, "wss://desk.example", desk.URL)
+			assert.Equal(t, dvotcWS.SecretRef("desk-key"), desk.APIKey)
+			assert.Equal(t, dvotcWS.Duration(5*time.Second), desk.TimeWindow)
+			assert.Equal(t, uint(3), desk.ConnectAttempts)
+			require.NotNil(t, desk.Risk)
+			assert.True(t, desk.Risk.RejectUnknownAssets)
+			assert.Equal(t, 100000.0, desk.Risk.Symbols["BTC/USD"].MaxNotional)
+
+			prod, err := cfg.Profile(dvotcWS.ProfileProduction)
+			require.NoError(t, err)
+			assert.Equal(t, dvotcWS.ProductionURL, prod.URL)
+			secret, err := prod.APISecret.Resolve()
+			require.NoError(t, err)
+			assert.Equal(t, "prod-secret", secret)
+		})
+	}
+}
+
+func withSecretFile(format, path string) string {
+	// paths are quoted in TOML and JSON, keep Windows separators valid there
+	return fmt.Sprintf(format, filepath.ToSlash(path))
+}
+
+func TestConfigProfiles(t *testing.T) {
+	clearConfigEnv(t)
+	cfg := &dvotcWS.Config{Profiles: map[string]dvotcWS.Profile{"desk": {URL: "wss://desk.example"}}}
+
+	sandbox, err := cfg.Profile("")
+	require.NoError(t, err)
+	assert.Equal(t, dvotcWS.SandboxURL, sandbox.URL)
+
+	_, err = cfg.Profile("staging")
+	assert.ErrorIs(t, err, dvotcWS.ErrUnknownProfile)
+
+	// the environment replaces the sandbox only when no profile is selected
+	t.Setenv("DVOTC_WS_URL", "wss://env.example")
+	t.Setenv("DVOTC_API_KEY", "env-key")
+	sandbox, err = cfg.Profile("")
+	require.NoError(t, err)
+	assert.Equal(t, "wss://env.example", sandbox.URL)
+	assert.Equal(t, dvotcWS.SecretRef("env:DVOTC_API_KEY"), sandbox.APIKey)
+
+	sandbox, err = cfg.Profile(dvotcWS.ProfileSandbox)
+	require.NoError(t, err)
+	assert.Equal(t, dvotcWS.SandboxURL, sandbox.URL)
+
+	// a selected profile keeps its values and takes the rest from the environment
+	t.Setenv("DVOTC_PROFILE", "desk")
+	desk, err := cfg.Profile("")
+	require.NoError(t, err)
+	assert.Equal(t, "wss://desk.example", desk.URL)
+	assert.Equal(t, dvotcWS.SecretRef("env:DVOTC_API_KEY"), desk.APIKey)
+
+	_, err = desk.NewClient()
+	assert.ErrorIs(t, err, dvotcWS.ErrIncompleteProfile)
+
+	desk.APISecret = "env:DVOTC_TEST_UNSET_SECRET"
+	_, err = desk.NewClient()
+	assert.ErrorIs(t, err, dvotcWS.ErrUnresolvedSecret)
+
+	// values from the environment are secrets, not references
+	t.Setenv("DVOTC_API_KEY", "env:DVOTC_PROFILE")
+	t.Setenv("DVOTC_API_SECRET", "file:/nonexistent/secret")
+	desk, err = cfg.Profile("")
+	require.NoError(t, err)
+	key, err := desk.APIKey.Resolve()
+	require.NoError(t, err)
+	assert.Equal(t, "env:DVOTC_PROFILE", key)
+	secret, err := desk.APISecret.Resolve()
+	require.NoError(t, err)
+	assert.Equal(t, "file:/nonexistent/secret", secret)
+}
+
+func TestNewDVOTCClientFromConfig(t *testing.T) {
+	clearConfigEnv(t)
+	server := dvotctest.NewServer(dvotctest.Config{})
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+profiles:
+  local:
+    url: `+server.URL+`
+    apiKey: `+dvotctest.DefaultAPIKey+`
+    apiSecret: env:LOCAL_SECRET
+    timeWindow: 10s
+    requestTimeout: 5s
+`), 0o600))
+	t.Setenv("DVOTC_CONFIG", path)
+	t.Setenv("LOCAL_SECRET", dvotctest.DefaultAPISecret)
+
+	client, err := dvotcWS.NewDVOTCClientFromConfig("local")
+	require.NoError(t, err)
+	assert.NoError(t, client.Ping())
+}
@@ -0,0 +1,109 @@
+package dvotcWS
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var ErrMissingCredentials = errors.New("missing credentials")
+
+// Credentials are the API key and secret the handshake is signed with
+type Credentials struct {
+	APIKey    string
+	APISecret string
+}
+
+// CredentialsProvider returns the credentials to sign the next handshake with, it
+// is asked for every new connection so keys can rotate without a new client
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// CredentialsProviderFunc is a function used as a CredentialsProvider
+type CredentialsProviderFunc func(ctx context.Context) (Credentials, error)
+
+func (f CredentialsProviderFunc) Credentials(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// StaticCredentials always provides the same credentials
+type StaticCredentials Credentials
+
+func (c StaticCredentials) Credentials(context.Context) (Credentials, error) {
+	return Credentials(c), nil
+}
+
+// SecretRefCredentials resolves its references for every handshake, so rotating
+// the files or variables they point to rotates the keys of the client
+type SecretRefCredentials struct {
+	APIKey    SecretRef
+	APISecret SecretRef
+}
+
+func (c SecretRefCredentials) Credentials(context.Context) (Credentials, error) {
+	apiKey, err := c.APIKey.Resolve()
+	if err != nil {
+		return Credentials{}, fmt.Errorf("apiKey: %w", err)
+	}
+	apiSecret, err := c.APISecret.Resolve()
+	if err != nil {
+		return Credentials{}, fmt.Errorf("apiSecret: %w", err)
+	}
+	return Credentials{APIKey: apiKey, APISecret: apiSecret}, nil
+}
+
+// Signer signs the websocket handshake. It returns the API key sent as dv-api-key
+// and dv-signature, base64(HMAC-SHA256(secret, apiKey+timestamp+timeWindow)) with
+// timestamp and timeWindow in milliseconds. A signer backed by a secret agent or an
+// HSM keeps the secret out of this process.
+type Signer interface {
+	Sign(ctx context.Context, timestamp, timeWindow int64) (apiKey, signature string, err error)
+}
+
+// SignerFunc is a function used as a Signer
+type SignerFunc func(ctx context.Context, timestamp, timeWindow int64) (apiKey, signature string, err error)
+
+func (f SignerFunc) Sign(ctx context.Context, timestamp, timeWindow int64) (string, string, error) {
+	return f(ctx, timestamp, timeWindow)
+}
+
+// HMACSigner signs with the credentials of its provider, it is the default signer.
+// Empty credentials are signed as they are unless RequireCredentials is set.
+type HMACSigner struct {
+	provider CredentialsProvider
+	required bool
+}
+
+func NewHMACSigner(provider CredentialsProvider) *HMACSigner {
+	return &HMACSigner{provider: provider}
+}
+
+// RequireCredentials makes Sign fail with ErrMissingCredentials when the provider
+// returns an empty key or secret, instead of sending a handshake bound to be refused
+func (s *HMACSigner) RequireCredentials() *HMACSigner {
+	s.required = true
+	return s
+}
+
+func (s *HMACSigner) Sign(ctx context.Context, timestamp, timeWindow int64) (string, string, error) {
+	creds, err := s.provider.Credentials(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	if s.required && (creds.APIKey == "" || creds.APISecret == "") {
+		return "", "", ErrMissingCredentials
+	}
+	return creds.APIKey, Signature(creds.APISecret, creds.APIKey, timestamp, timeWindow), nil
+}
+
+// Signature is the dv-signature of the handshake, for signers computing it themselves
+func Signature(apiSecret, apiKey string, timestamp, timeWindow int64) string {
+	h := hmac.New(sha256.New, []byte(apiSecret))
+	h.Write([]byte(apiKey + strconv.FormatInt(timestamp, 10) + strconv.FormatInt(timeWindow, 10)))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
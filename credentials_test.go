@@ -0,0 +1,101 @@
+package dvotcWS_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/dvotctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignature(t *testing.T) {
+	h := hmac.New(sha256.New, []byte("secret"))
+	h.Write([]byte("key" + "1700000000000" + "20000"))
+	expected := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	assert.Equal(t, expected, dvotcWS.Signature("secret", "key", 1700000000000, 20000))
+
+	apiKey, signature, err := dvotcWS.NewHMACSigner(dvotcWS.StaticCredentials{APIKey: "key", APISecret: "secret"}).
+		Sign(context.Background(), 1700000000000, 20000)
+	require.NoError(t, err)
+	assert.Equal(t, "key", apiKey)
+	assert.Equal(t, expected, signature)
+
+	// empty credentials are signed as they are unless required
+	apiKey, signature, err = dvotcWS.NewHMACSigner(dvotcWS.StaticCredentials{APIKey: "key"}).Sign(context.Background(), 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "key", apiKey)
+	assert.Equal(t, dvotcWS.Signature("", "key", 1, 1), signature)
+
+	_, _, err = dvotcWS.NewHMACSigner(dvotcWS.StaticCredentials{APIKey: "key"}).RequireCredentials().Sign(context.Background(), 1, 1)
+	assert.ErrorIs(t, err, dvotcWS.ErrMissingCredentials)
+}
+
+func TestCredentialsProviderRotation(t *testing.T) {
+	server := dvotctest.NewServer(dvotctest.Config{})
+	defer server.Close()
+
+	var calls atomic.Int64
+	var current atomic.Value
+	current.Store(dvotcWS.Credentials{APIKey: dvotctest.DefaultAPIKey, APISecret: dvotctest.DefaultAPISecret})
+	client := dvotcWS.NewDVOTCClientWithCredentials(server.URL, dvotcWS.CredentialsProviderFunc(func(ctx context.Context) (dvotcWS.Credentials, error) {
+		calls.Add(1)
+		return current.Load().(dvotcWS.Credentials), nil
+	}))
+
+	require.NoError(t, client.Ping())
+	current.Store(dvotcWS.Credentials{APIKey: dvotctest.DefaultAPIKey, APISecret: "revoked"})
+	assert.Error(t, client.Ping())
+	current.Store(dvotcWS.Credentials{APIKey: dvotctest.DefaultAPIKey, APISecret: dvotctest.DefaultAPISecret})
+	assert.NoError(t, client.Ping())
+	assert.Equal(t, int64(3), calls.Load())
+}
+
+func TestSecretRefCredentials(t *testing.T) {
+	server := dvotctest.NewServer(dvotctest.Config{})
+	defer server.Close()
+
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("stale"), 0o600))
+	t.Setenv("DVOTC_TEST_KEY", dvotctest.DefaultAPIKey)
+
+	client := dvotcWS.NewDVOTCClientWithCredentials(server.URL, dvotcWS.SecretRefCredentials{
+		APIKey:    "env:DVOTC_TEST_KEY",
+		APISecret: dvotcWS.SecretRef("file:" + secretFile),
+	})
+	assert.Error(t, client.Ping())
+
+	// rotating the file is picked up by the next handshake
+	require.NoError(t, os.WriteFile(secretFile, []byte(dvotctest.DefaultAPISecret+"\n"), 0o600))
+	assert.NoError(t, client.Ping())
+}
+
+func TestExternalSigner(t *testing.T) {
+	server := dvotctest.NewServer(dvotctest.Config{})
+	defer server.Close()
+
+	// stands in for a secret agent holding the secret out of the client
+	agent := dvotcWS.SignerFunc(func(ctx context.Context, timestamp, timeWindow int64) (string, string, error) {
+		return dvotctest.DefaultAPIKey, dvotcWS.Signature(dvotctest.DefaultAPISecret, dvotctest.DefaultAPIKey, timestamp, timeWindow), nil
+	})
+	client := dvotcWS.NewDVOTCClientWithSigner(server.URL, agent)
+	require.NoError(t, client.Ping())
+
+	errAgentDown := errors.New("agent unreachable")
+	client.SetSigner(dvotcWS.SignerFunc(func(ctx context.Context, timestamp, timeWindow int64) (string, string, error) {
+		return "", "", errAgentDown
+	}))
+	assert.ErrorIs(t, client.Ping(), errAgentDown)
+
+	client.SetCredentialsProvider(dvotcWS.StaticCredentials{APIKey: dvotctest.DefaultAPIKey, APISecret: dvotctest.DefaultAPISecret})
+	assert.NoError(t, client.Ping())
+}
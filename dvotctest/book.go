@@ -0,0 +1,306 @@
+package dvotctest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+)
+
+var (
+	ErrUnknownSymbol = errors.New("unknown symbol")
+	ErrQuoteExpired  = errors.New("quote expired")
+	ErrNoLiquidity   = errors.New("quantity exceeds available liquidity")
+	ErrInvalidOrder  = errors.New("invalid order")
+	ErrOrderNotFound = errors.New("order not found")
+	ErrOrderNotOpen  = errors.New("order is not open")
+)
+
+// event is an order change to push to subscribers, notification is empty for
+// changes without a notification
+type event struct {
+	order        dvotcWS.OrderStatus
+	notification string
+}
+
+// book keeps the levels, orders and positions of the server, callers hold Server.mu
+type book struct {
+	user    dvotcWS.User
+	levels  map[string]*dvotcWS.LevelData
+	orders  []*dvotcWS.OrderStatus
+	byID    map[string]*dvotcWS.OrderStatus
+	assets  []dvotcWS.Asset
+	usd     float64
+	nextID  int
+	nextSeq int
+}
+
+func newBook(balances dvotcWS.AssetBalance, user dvotcWS.User) *book {
+	return &book{
+		user:   user,
+		levels: make(map[string]*dvotcWS.LevelData),
+		byID:   make(map[string]*dvotcWS.OrderStatus),
+		assets: append([]dvotcWS.Asset{}, balances.Assets...),
+		usd:    balances.UsdBalance,
+	}
+}
+
+func (b *book) symbols() []string {
+	symbols := make([]string, 0, len(b.levels))
+	for symbol := range b.levels {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+func (b *book) balances() dvotcWS.AssetBalance {
+	return dvotcWS.AssetBalance{
+		Assets:     append([]dvotcWS.Asset{}, b.assets...),
+		UsdBalance: b.usd,
+	}
+}
+
+func (b *book) setLevels(symbol string, levels []dvotcWS.Level) ([]byte, []event) {
+	b.nextSeq++
+	data := &dvotcWS.LevelData{
+		Levels:     append([]dvotcWS.Level{}, levels...),
+		LastUpdate: time.Now().UnixMilli(),
+		QuoteID:    fmt.Sprintf("quote-%d", b.nextSeq),
+		Market:     symbol,
+	}
+	b.levels[symbol] = data
+
+	events := make([]event, 0)
+	for _, order := range b.orders {
+		if order.Status != dvotcWS.OrderStatusOpen || symbolOf(order.Asset, order.CounterAsset) != symbol {
+			continue
+		}
+		if price, ok := crossingPrice(data, order); ok {
+			b.fill(order, price)
+			events = append(events, event{order: *order, notification: dvotcWS.NOTIFICATION_ORDER_FILLED})
+		}
+	}
+	encoded, _ := json.Marshal(data)
+	return encoded, events
+}
+
+func (b *book) createOrder(order dvotcWS.Order) (*dvotcWS.OrderStatus, []event, error) {
+	symbol := symbolOf(order.Asset, order.CounterAsset)
+	levels, ok := b.levels[symbol]
+	switch {
+	case !ok:
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnknownSymbol, symbol)
+	case order.Qty <= 0:
+		return nil, nil, fmt.Errorf("%w: quantity must be positive", ErrInvalidOrder)
+	case !strings.EqualFold(order.Side, "buy") && !strings.EqualFold(order.Side, "sell"):
+		return nil, nil, fmt.Errorf("%w: unknown side %q", ErrInvalidOrder, order.Side)
+	}
+
+	b.nextID++
+	status := &dvotcWS.OrderStatus{
+		ID:           fmt.Sprintf("order-%d", b.nextID),
+		ClientTag:    order.ClientTag,
+		Quantity:     order.Qty,
+		Side:         order.Side,
+		OrderType:    order.OrderType,
+		Asset:        order.Asset,
+		CounterAsset: order.CounterAsset,
+		Status:       dvotcWS.OrderStatusOpen,
+		User:         b.user,
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	events := make([]event, 0, 2)
+	if strings.EqualFold(order.OrderType, "limit") {
+		if order.LimitPrice == nil {
+			return nil, nil, fmt.Errorf("%w: limit price is required", ErrInvalidOrder)
+		}
+		limit, err := strconv.ParseFloat(*order.LimitPrice, 64)
+		if err != nil || limit <= 0 {
+			return nil, nil, fmt.Errorf("%w: limit price %q", ErrInvalidOrder, *order.LimitPrice)
+		}
+		status.LimitPrice = *order.LimitPrice
+		b.add(status)
+		events = append(events, event{order: *status, notification: dvotcWS.NOTIFICATION_ORDER_CREATED})
+		if price, ok := crossingPrice(levels, status); ok {
+			b.fill(status, price)
+			events = append(events, event{order: *status, notification: dvotcWS.NOTIFICATION_ORDER_FILLED})
+		}
+		result := *status
+		return &result, events, nil
+	}
+
+	if order.QuoteID != levels.QuoteID {
+		return nil, nil, fmt.Errorf("%w: %s", ErrQuoteExpired, order.QuoteID)
+	}
+	price, ok := levels.PriceFor(order.Side, order.Qty)
+	if !ok {
+		return nil, nil, ErrNoLiquidity
+	}
+	b.add(status)
+	b.fill(status, price)
+	events = append(events, event{order: *status, notification: dvotcWS.NOTIFICATION_ORDER_FILLED})
+	result := *status
+	return &result, events, nil
+}
+
+func (b *book) cancelOrder(orderID string) ([]event, error) {
+	order, ok := b.byID[orderID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrOrderNotFound, orderID)
+	}
+	if order.Status != dvotcWS.OrderStatusOpen {
+		return nil, fmt.Errorf("%w: %s is %s", ErrOrderNotOpen, orderID, order.Status)
+	}
+	now := time.Now().UTC()
+	order.Status = dvotcWS.OrderStatusCancelled
+	order.CancelledAt = &now
+	return []event{{order: *order, notification: dvotcWS.NOTIFICATION_ORDER_CANCELLED}}, nil
+}
+
+func (b *book) add(order *dvotcWS.OrderStatus) {
+	b.orders = append(b.orders, order)
+	b.byID[order.ID] = order
+}
+
+// fill completes order at price and moves the positions of both assets
+func (b *book) fill(order *dvotcWS.OrderStatus, price float64) {
+	now := time.Now().UTC()
+	order.Status = dvotcWS.OrderStatusComplete
+	order.Price = price
+	order.FilledAt = &now
+
+	qty := order.Quantity
+	if strings.EqualFold(order.Side, "sell") {
+		qty = -qty
+	}
+	b.move(order.Asset, qty)
+	b.move(order.CounterAsset, -qty*price)
+}
+
+func (b *book) move(asset string, qty float64) {
+	if asset == "USD" {
+		b.usd += qty
+	}
+	for i := range b.assets {
+		if b.assets[i].Asset == asset {
+			b.assets[i].Position += qty
+			return
+		}
+	}
+	// USD is reported as usdBalance unless the limits list it as an asset
+	if asset != "USD" {
+		b.assets = append(b.assets, dvotcWS.Asset{Asset: asset, Position: qty})
+	}
+}
+
+// trades filters orders like the tradestatus topic, by IDs and client tags, paging
+// with limit and cursor when they are sent
+func (b *book) trades(query dvotcWS.ListTradesPayload) []dvotcWS.Trade {
+	ids := splitSet(query.IDs)
+	tags := splitSet(query.ClientTags)
+	trades := make([]dvotcWS.Trade, 0)
+	started := query.Cursor == ""
+	for _, order := range b.orders {
+		if !started {
+			started = order.ID == query.Cursor
+			continue
+		}
+		trade := toTrade(order)
+		switch {
+		case ids != nil && !ids[trade.ID]:
+			continue
+		case tags != nil && !tags[trade.ClientTag]:
+			continue
+		}
+		trades = append(trades, trade)
+		if query.Limit > 0 && len(trades) == query.Limit {
+			break
+		}
+	}
+	return trades
+}
+
+func toTrade(order *dvotcWS.OrderStatus) dvotcWS.Trade {
+	limitPrice, _ := strconv.ParseFloat(order.LimitPrice, 64)
+	trade := dvotcWS.Trade{
+		ID:           order.ID,
+		Price:        order.Price,
+		LimitPrice:   limitPrice,
+		Quantity:     int64(math.Round(order.Quantity)),
+		Side:         order.Side,
+		ClientTag:    order.ClientTag,
+		Asset:        order.Asset,
+		CounterAsset: order.CounterAsset,
+		Status:       order.Status,
+		User:         order.User,
+		CreatedAt:    order.CreatedAt,
+	}
+	if order.FilledAt != nil {
+		trade.FilledAt = *order.FilledAt
+	}
+	if order.CancelledAt != nil {
+		trade.UpdatedAt = order.CancelledAt
+	} else if order.FilledAt != nil {
+		trade.UpdatedAt = order.FilledAt
+	}
+	return trade
+}
+
+func orderNotification(order dvotcWS.OrderStatus) dvotcWS.OrderNotification {
+	return dvotcWS.OrderNotification{
+		Asset:        order.Asset,
+		CounterAsset: order.CounterAsset,
+		ID:           order.ID,
+		Quantity:     order.Quantity,
+		Price:        order.Price,
+		LimitPrice:   json.Number(order.LimitPrice),
+		Side:         order.Side,
+		OrderType:    order.OrderType,
+		Source:       "dvotctest",
+		Status:       order.Status,
+		FilledAt:     order.FilledAt,
+		CreatedAt:    order.CreatedAt,
+		CancelledAt:  order.CancelledAt,
+		ClientTag:    order.ClientTag,
+		Text:         fmt.Sprintf("%s %s %s/%s order %s", order.Status, order.Side, order.Asset, order.CounterAsset, order.ID),
+	}
+}
+
+// crossingPrice returns the price a resting limit order fills at on levels
+func crossingPrice(levels *dvotcWS.LevelData, order *dvotcWS.OrderStatus) (float64, bool) {
+	limit, err := strconv.ParseFloat(order.LimitPrice, 64)
+	if err != nil {
+		return 0, false
+	}
+	price, ok := levels.PriceFor(order.Side, order.Quantity)
+	if !ok {
+		return 0, false
+	}
+	if strings.EqualFold(order.Side, "sell") {
+		return price, price >= limit
+	}
+	return price, price <= limit
+}
+
+func symbolOf(asset, counterAsset string) string {
+	return asset + "/" + counterAsset
+}
+
+func splitSet(list string) map[string]bool {
+	if list == "" {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, item := range strings.Split(list, ",") {
+		set[item] = true
+	}
+	return set
+}
@@ -0,0 +1,170 @@
+package dvotctest
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+)
+
+// Fault changes how the server answers requests and subscriptions on a topic,
+// see InjectFault
+type Fault struct {
+	// Delay holds the response this long before sending it
+	Delay time.Duration
+	// Error answers with an error carrying this message without processing the request
+	Error string
+	// Malformed sends the response cut in the middle of its JSON
+	Malformed bool
+	// Drop closes the connection instead of answering, the request is still processed
+	Drop bool
+	// Reorder holds responses until this many are pending and sends them newest first
+	Reorder int
+	// Times limits the fault to that many messages, zero applies it until ClearFaults
+	Times int
+}
+
+type faultRule struct {
+	prefix string
+	fault  Fault
+	used   int
+}
+
+// InjectFault applies f to the requests whose topic starts with topicPrefix, e.g.
+// "createorder" or "cancelorder/", subscriptions such as "BTC/USD" or "order/" only
+// honor Error. Faults are matched in the order they were injected
+func (s *Server) InjectFault(topicPrefix string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &faultRule{prefix: topicPrefix, fault: f})
+}
+
+// FailTopic answers every request whose topic starts with prefix with an error
+// carrying message until ClearFaults is called
+func (s *Server) FailTopic(prefix, message string) {
+	s.InjectFault(prefix, Fault{Error: message})
+}
+
+// ClearFaults removes every fault and sends the responses still held for reordering
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	s.faults = nil
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	for _, send := range pending {
+		send()
+	}
+}
+
+// takeFault returns the first fault matching topic and counts its use
+func (s *Server) takeFault(topic string) (Fault, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, rule := range s.faults {
+		if !strings.HasPrefix(topic, rule.prefix) {
+			continue
+		}
+		rule.used++
+		if rule.fault.Times > 0 && rule.used >= rule.fault.Times {
+			s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+		}
+		return rule.fault, true
+	}
+	return Fault{}, false
+}
+
+// respond sends resp to c, then the order updates and notifications of events,
+// as changed by fault f
+func (s *Server) respond(c *serverConn, resp dvotcWS.Payload, events []event, f Fault) {
+	send := func() {
+		if f.Malformed {
+			_ = c.writeRaw(truncate(resp))
+		} else {
+			_ = c.write(resp)
+		}
+		s.dispatch(events)
+	}
+
+	switch {
+	case f.Drop:
+		c.ws.Close()
+		s.dispatch(events)
+	case f.Reorder > 1:
+		s.mu.Lock()
+		s.pending = append(s.pending, send)
+		var ready []func()
+		if len(s.pending) >= f.Reorder {
+			ready = s.pending
+			s.pending = nil
+		}
+		s.mu.Unlock()
+		for i := len(ready) - 1; i >= 0; i-- {
+			ready[i]()
+		}
+	case f.Delay > 0:
+		go func() {
+			time.Sleep(f.Delay)
+			send()
+		}()
+	default:
+		send()
+	}
+}
+
+// SendReconnect asks the subscribers of topic and event to reconnect with an
+// info message and closes their connection like the server does before a restart
+func (s *Server) SendReconnect(topic, event string) int {
+	conns := s.subscribers(topic, event)
+	for _, c := range conns {
+		_ = c.write(dvotcWS.Payload{Type: dvotcWS.MessageTypeInfo, Event: "reconnect", Topic: topic})
+		c.ws.Close()
+	}
+	return len(conns)
+}
+
+// SendRaw writes raw, usually malformed, frames to the subscribers of topic and event
+func (s *Server) SendRaw(topic, event string, raw []byte) int {
+	conns := s.subscribers(topic, event)
+	for _, c := range conns {
+		_ = c.writeRaw(raw)
+	}
+	return len(conns)
+}
+
+// SendError writes an error for topic and event to its subscribers
+func (s *Server) SendError(topic, event, message string) int {
+	conns := s.subscribers(topic, event)
+	for _, c := range conns {
+		_ = c.write(errorPayload(dvotcWS.Payload{Topic: topic, Event: event}, message))
+	}
+	return len(conns)
+}
+
+// DropSubscribers closes the connections subscribed to topic and event mid-stream
+func (s *Server) DropSubscribers(topic, event string) int {
+	conns := s.subscribers(topic, event)
+	for _, c := range conns {
+		c.ws.Close()
+	}
+	return len(conns)
+}
+
+func (s *Server) subscribers(topic, event string) []*serverConn {
+	key := subKey(topic, event)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conns := make([]*serverConn, 0)
+	for c := range s.conns {
+		if _, ok := c.subs[key]; ok {
+			conns = append(conns, c)
+		}
+	}
+	return conns
+}
+
+func truncate(p dvotcWS.Payload) []byte {
+	data, _ := json.Marshal(p)
+	return data[:len(data)/2]
+}
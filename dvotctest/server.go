@@ -0,0 +1,399 @@
+// Package dvotctest provides an in-process fake DVOTC server for integration tests.
+//
+// The server authenticates connections like the real API, serves symbols, limits
+// and trade status, streams levels per symbol, fills orders against its own book
+// and pushes order updates and notifications:
+//
+//	srv := dvotctest.NewServer(dvotctest.Config{})
+//	defer srv.Close()
+//	srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 20010, SellPrice: 19990, MaxQuantity: 5})
+//	client := srv.Client()
+package dvotctest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/fasthttp/websocket"
+)
+
+const (
+	DefaultAPIKey    = "dvotctest-key"
+	DefaultAPISecret = "dvotctest-secret"
+)
+
+type Config struct {
+	// APIKey and APISecret are the only credentials accepted, default to DefaultAPIKey and DefaultAPISecret
+	APIKey    string
+	APISecret string
+	// Balances is served by the limits topic, positions move with every fill
+	Balances dvotcWS.AssetBalance
+	// User is set on orders and trades
+	User dvotcWS.User
+	// ClockOffset puts the server clock ahead of the local one, or behind when negative,
+	// it is used to check dv-timestamp and sent in the Date header of handshake responses
+	ClockOffset time.Duration
+}
+
+// Server is a stateful fake of the DVOTC websocket API
+type Server struct {
+	// URL is the websocket URL to pass to dvotcWS.NewDVOTCClient
+	URL string
+
+	cfg      Config
+	srv      *httptest.Server
+	upgrader websocket.Upgrader
+
+	mu       sync.Mutex
+	conns    map[*serverConn]struct{}
+	requests []dvotcWS.Payload
+	authFail int
+	book     *book
+	faults   []*faultRule
+	pending  []func()
+}
+
+type serverConn struct {
+	mu   sync.Mutex
+	ws   *websocket.Conn
+	subs map[string]struct{}
+}
+
+func (c *serverConn) write(p dvotcWS.Payload) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ws.WriteJSON(p)
+}
+
+func (c *serverConn) writeRaw(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ws.WriteMessage(websocket.TextMessage, data)
+}
+
+func NewServer(cfg Config) *Server {
+	if cfg.APIKey == "" {
+		cfg.APIKey = DefaultAPIKey
+	}
+	if cfg.APISecret == "" {
+		cfg.APISecret = DefaultAPISecret
+	}
+	if cfg.User.ID == "" {
+		cfg.User = dvotcWS.User{ID: "dvotctest-user", FirstName: "Test", LastName: "User"}
+	}
+
+	s := &Server{
+		cfg:   cfg,
+		conns: make(map[*serverConn]struct{}),
+		book:  newBook(cfg.Balances, cfg.User),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.handler))
+	u, _ := url.Parse(s.srv.URL)
+	u.Scheme = "ws"
+	s.URL = u.String()
+	return s
+}
+
+// Client returns a client authenticated with the credentials of the server
+func (s *Server) Client() *dvotcWS.DVOTCClient {
+	return dvotcWS.NewDVOTCClient(s.URL, s.cfg.APIKey, s.cfg.APISecret)
+}
+
+// Close closes every connection and stops the server
+func (s *Server) Close() {
+	s.DropConnections()
+	s.srv.Close()
+}
+
+// DropConnections closes every open connection without a close frame
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	conns := make([]*serverConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+	for _, c := range conns {
+		c.ws.Close()
+	}
+}
+
+// Requests returns every message received whose topic starts with prefix, in order
+func (s *Server) Requests(topicPrefix string) []dvotcWS.Payload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := make([]dvotcWS.Payload, 0)
+	for _, p := range s.requests {
+		if strings.HasPrefix(p.Topic, topicPrefix) {
+			requests = append(requests, p)
+		}
+	}
+	return requests
+}
+
+// AuthFailures returns the number of connections rejected for bad credentials
+func (s *Server) AuthFailures() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.authFail
+}
+
+// Subscribers returns the number of connections subscribed to topic and event
+func (s *Server) Subscribers(topic, event string) int {
+	return len(s.subscribers(topic, event))
+}
+
+// SetLevels replaces the levels of symbol under a new quote ID, streams them to
+// subscribers and fills the resting limit orders they cross
+func (s *Server) SetLevels(symbol string, levels ...dvotcWS.Level) {
+	s.mu.Lock()
+	data, events := s.book.setLevels(symbol, levels)
+	s.mu.Unlock()
+
+	s.publish(dvotcWS.Payload{Type: dvotcWS.MessageTypeSubscribe, Event: "levels", Topic: symbol}, data)
+	s.dispatch(events)
+}
+
+// Levels returns the current levels of symbol
+func (s *Server) Levels(symbol string) (dvotcWS.LevelData, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.book.levels[symbol]
+	if !ok {
+		return dvotcWS.LevelData{}, false
+	}
+	return *data, true
+}
+
+// Orders returns every order received, oldest first
+func (s *Server) Orders() []dvotcWS.OrderStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	orders := make([]dvotcWS.OrderStatus, 0, len(s.book.orders))
+	for _, order := range s.book.orders {
+		orders = append(orders, *order)
+	}
+	return orders
+}
+
+// Balances returns the limits and positions served by the limits topic
+func (s *Server) Balances() dvotcWS.AssetBalance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.book.balances()
+}
+
+// Notify pushes a notification to the subscribers of topic, e.g. NOTIFICATION_BATCH_CREATED
+func (s *Server) Notify(topic string, notification any) error {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	s.publish(dvotcWS.Payload{Type: dvotcWS.MessageTypeSubscribe, Event: "notifications", Topic: topic}, data)
+	return nil
+}
+
+func (s *Server) handler(w http.ResponseWriter, req *http.Request) {
+	date := s.now().UTC().Format(http.TimeFormat)
+	if err := s.authenticate(req.Header); err != nil {
+		s.mu.Lock()
+		s.authFail++
+		s.mu.Unlock()
+		w.Header().Set("Date", date)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	ws, err := s.upgrader.Upgrade(w, req, http.Header{"Date": {date}})
+	if err != nil {
+		return
+	}
+	c := &serverConn{ws: ws, subs: make(map[string]struct{})}
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		ws.Close()
+	}()
+
+	for {
+		p := dvotcWS.Payload{}
+		if err := ws.ReadJSON(&p); err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, p)
+		s.mu.Unlock()
+		s.serve(c, p)
+	}
+}
+
+// now is the time on the server clock
+func (s *Server) now() time.Time {
+	return time.Now().Add(s.cfg.ClockOffset)
+}
+
+// authenticate checks the headers signed by the client: base64(HMAC-SHA256(secret, key+timestamp+window))
+func (s *Server) authenticate(header http.Header) error {
+	apiKey := header.Get("dv-api-key")
+	timestamp := header.Get("dv-timestamp")
+	timeWindow := header.Get("dv-timewindow")
+	signature := header.Get("dv-signature")
+	if apiKey != s.cfg.APIKey {
+		return fmt.Errorf("unknown api key")
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.cfg.APISecret))
+	mac.Write([]byte(apiKey + timestamp + timeWindow))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp")
+	}
+	window, err := strconv.ParseInt(timeWindow, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid time window")
+	}
+	drift := s.now().UnixMilli() - ts
+	if drift < -window || drift > window {
+		return fmt.Errorf("timestamp outside of time window")
+	}
+	return nil
+}
+
+func (s *Server) serve(c *serverConn, p dvotcWS.Payload) {
+	switch p.Type {
+	case dvotcWS.MessageTypePingPong:
+		_ = c.write(dvotcWS.Payload{Type: dvotcWS.MessageTypePingPong, Event: p.Event, Topic: p.Topic})
+	case dvotcWS.MessageTypeSubscribe:
+		s.subscribe(c, p)
+	case dvotcWS.MessageTypeUnsubscribe:
+		s.mu.Lock()
+		delete(c.subs, subKey(p.Topic, p.Event))
+		s.mu.Unlock()
+	case dvotcWS.MessageTypeRequestResponse:
+		s.request(c, p)
+	default:
+		_ = c.write(errorPayload(p, fmt.Sprintf("unknown message type %q", p.Type)))
+	}
+}
+
+func (s *Server) subscribe(c *serverConn, p dvotcWS.Payload) {
+	if f, ok := s.takeFault(p.Topic); ok && f.Error != "" {
+		_ = c.write(errorPayload(p, f.Error))
+		return
+	}
+	s.mu.Lock()
+	c.subs[subKey(p.Topic, p.Event)] = struct{}{}
+	var current *dvotcWS.LevelData
+	if p.Event == "levels" {
+		current = s.book.levels[p.Topic]
+	}
+	var data []byte
+	if current != nil {
+		data, _ = json.Marshal(current)
+	}
+	s.mu.Unlock()
+
+	if data != nil {
+		_ = c.write(dvotcWS.Payload{Type: dvotcWS.MessageTypeSubscribe, Event: p.Event, Topic: p.Topic, Data: data})
+	}
+}
+
+func (s *Server) request(c *serverConn, p dvotcWS.Payload) {
+	f, _ := s.takeFault(p.Topic)
+	if f.Error != "" {
+		s.respond(c, errorPayload(p, f.Error), nil, f)
+		return
+	}
+
+	s.mu.Lock()
+	var (
+		result any
+		events []event
+		err    error
+	)
+	switch {
+	case p.Topic == "availablesymbols":
+		result = s.book.symbols()
+	case p.Topic == "limits":
+		result = s.book.balances()
+	case p.Topic == "tradestatus":
+		query := dvotcWS.ListTradesPayload{}
+		if len(p.Data) > 0 {
+			err = json.Unmarshal(p.Data, &query)
+		}
+		if err == nil {
+			result = s.book.trades(query)
+		}
+	case p.Topic == "createorder":
+		order := dvotcWS.Order{}
+		if err = json.Unmarshal(p.Data, &order); err == nil {
+			result, events, err = s.book.createOrder(order)
+		}
+	case strings.HasPrefix(p.Topic, "cancelorder/"):
+		events, err = s.book.cancelOrder(strings.TrimPrefix(p.Topic, "cancelorder/"))
+	default:
+		err = fmt.Errorf("unknown topic %s", p.Topic)
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		s.respond(c, errorPayload(p, err.Error()), nil, f)
+		return
+	}
+	resp := dvotcWS.Payload{Type: p.Type, Event: p.Event, Topic: p.Topic}
+	if result != nil {
+		resp.Data, _ = json.Marshal(result)
+	}
+	s.respond(c, resp, events, f)
+}
+
+// dispatch pushes order updates and notifications produced by the book
+func (s *Server) dispatch(events []event) {
+	for _, e := range events {
+		data, _ := json.Marshal(e.order)
+		for _, topic := range []string{"order/#", "order/" + e.order.Status} {
+			s.publish(dvotcWS.Payload{Type: dvotcWS.MessageTypeSubscribe, Event: "order-updates", Topic: topic}, data)
+		}
+		if e.notification != "" {
+			notification, _ := json.Marshal(orderNotification(e.order))
+			s.publish(dvotcWS.Payload{Type: dvotcWS.MessageTypeSubscribe, Event: "notifications", Topic: e.notification}, notification)
+		}
+	}
+}
+
+// publish writes data to every connection subscribed to the topic and event of p
+func (s *Server) publish(p dvotcWS.Payload, data []byte) {
+	p.Data = data
+	for _, c := range s.subscribers(p.Topic, p.Event) {
+		_ = c.write(p)
+	}
+}
+
+func subKey(topic, event string) string {
+	return topic + ":" + event
+}
+
+func errorPayload(p dvotcWS.Payload, message string) dvotcWS.Payload {
+	data, _ := json.Marshal(dvotcWS.ErrorResponse{Message: message, Code: http.StatusBadRequest})
+	return dvotcWS.Payload{Type: dvotcWS.MessageTypeError, Event: p.Event, Topic: p.Topic, Data: data}
+}
@@ -0,0 +1,183 @@
+package dvotctest_test
+
+import (
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/dvotctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var btcLevels = []dvotcWS.Level{
+	{BuyPrice: 20010, SellPrice: 19990, MaxQuantity: 1},
+	{BuyPrice: 20020, SellPrice: 19980, MaxQuantity: 5},
+}
+
+func newServer(t *testing.T) *dvotctest.Server {
+	srv := dvotctest.NewServer(dvotctest.Config{
+		Balances: dvotcWS.AssetBalance{
+			Assets:     []dvotcWS.Asset{{Asset: "BTC", MaxBuy: 10, MaxSell: 10, Position: 1}},
+			UsdBalance: 100000,
+		},
+	})
+	t.Cleanup(srv.Close)
+	srv.SetLevels("BTC/USD", btcLevels...)
+	srv.SetLevels("ETH/USD", dvotcWS.Level{BuyPrice: 1510, SellPrice: 1490, MaxQuantity: 50})
+	return srv
+}
+
+func TestServerAuth(t *testing.T) {
+	srv := newServer(t)
+	require.NoError(t, srv.Client().Ping())
+
+	bad := dvotcWS.NewDVOTCClient(srv.URL, dvotctest.DefaultAPIKey, "wrong-secret")
+	require.Error(t, bad.Ping())
+	unknown := dvotcWS.NewDVOTCClient(srv.URL, "someone-else", dvotctest.DefaultAPISecret)
+	require.Error(t, unknown.Ping())
+	assert.Equal(t, 2, srv.AuthFailures())
+}
+
+func TestServerReference(t *testing.T) {
+	srv := newServer(t)
+	client := srv.Client()
+
+	symbols, err := client.ListAvailableSymbols()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"BTC/USD", "ETH/USD"}, symbols)
+
+	limits, err := client.ListLimitsBalances()
+	require.NoError(t, err)
+	assert.Equal(t, 100000.0, limits.UsdBalance)
+	require.Len(t, limits.Assets, 1)
+	assert.Equal(t, 1.0, limits.Assets[0].Position)
+
+	srv.FailTopic("limits", "limits unavailable")
+	_, err = client.ListLimitsBalances()
+	require.ErrorContains(t, err, "limits unavailable")
+	srv.ClearFaults()
+	_, err = client.ListLimitsBalances()
+	require.NoError(t, err)
+}
+
+func TestServerLevels(t *testing.T) {
+	srv := newServer(t)
+	client := srv.Client()
+
+	sub, err := client.SubscribeLevels("BTC/USD")
+	require.NoError(t, err)
+	first := <-sub.Data
+	assert.Equal(t, btcLevels, first.Levels)
+	assert.Equal(t, "BTC/USD", first.Market)
+
+	srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 21010, SellPrice: 20990, MaxQuantity: 2})
+	second := <-sub.Data
+	assert.NotEqual(t, first.QuoteID, second.QuoteID)
+	assert.Equal(t, 21010.0, second.Levels[0].BuyPrice)
+}
+
+func TestServerOrders(t *testing.T) {
+	t.Run("market", func(t *testing.T) {
+		srv := newServer(t)
+		client := srv.Client()
+		levels, _ := srv.Levels("BTC/USD")
+
+		order, err := client.PlaceMarketOrder(dvotcWS.MarketOrderParams{
+			QuoteID:      levels.QuoteID,
+			Asset:        "BTC",
+			CounterAsset: "USD",
+			Price:        20020,
+			Qty:          2,
+			Side:         "Buy",
+			ClientTag:    "market-1",
+		})
+		require.NoError(t, err)
+		assert.True(t, order.IsFilled())
+		// two BTC only fit in the second level
+		assert.Equal(t, 20020.0, order.Price)
+
+		balances := srv.Balances()
+		assert.Equal(t, 3.0, balances.Assets[0].Position)
+		assert.Equal(t, 100000.0-40040, balances.UsdBalance)
+
+		_, err = client.PlaceMarketOrder(dvotcWS.MarketOrderParams{QuoteID: "stale", Asset: "BTC", CounterAsset: "USD", Qty: 1, Side: "Buy"})
+		require.ErrorContains(t, err, dvotctest.ErrQuoteExpired.Error())
+		_, err = client.PlaceMarketOrder(dvotcWS.MarketOrderParams{QuoteID: levels.QuoteID, Asset: "BTC", CounterAsset: "USD", Qty: 6, Side: "Sell"})
+		require.ErrorContains(t, err, dvotctest.ErrNoLiquidity.Error())
+
+		trades, err := client.ListTrades(nil, nil, []string{"market-1"})
+		require.NoError(t, err)
+		require.Len(t, trades, 1)
+		assert.Equal(t, order.ID, trades[0].ID)
+		assert.Equal(t, int64(2), trades[0].Quantity)
+	})
+
+	t.Run("limit_rests_then_fills", func(t *testing.T) {
+		srv := newServer(t)
+		client := srv.Client()
+
+		updates, err := client.SubscribeOrderChanges("#")
+		require.NoError(t, err)
+		defer updates.StopConsuming()
+		filled, err := client.SubscribeOrderFilled()
+		require.NoError(t, err)
+		defer filled.StopConsuming()
+		require.Eventually(t, func() bool {
+			return srv.Subscribers("order/#", "order-updates") == 1 &&
+				srv.Subscribers(dvotcWS.NOTIFICATION_ORDER_FILLED, "notifications") == 1
+		}, time.Second, 10*time.Millisecond)
+
+		order, err := client.PlaceLimitOrder(dvotcWS.LimitOrderParams{
+			Asset:        "BTC",
+			CounterAsset: "USD",
+			LimitPrice:   19000,
+			Qty:          1,
+			Side:         "Buy",
+		})
+		require.NoError(t, err)
+		assert.True(t, order.IsOpen())
+		assert.Equal(t, order.ID, (<-updates.Data).ID)
+
+		srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 18990, SellPrice: 18970, MaxQuantity: 1})
+		update := <-updates.Data
+		assert.Equal(t, order.ID, update.ID)
+		assert.True(t, update.IsFilled())
+		assert.Equal(t, 18990.0, update.Price)
+
+		notification := <-filled.Data
+		assert.Equal(t, order.ID, notification.ID)
+		assert.Equal(t, dvotcWS.OrderStatusComplete, notification.Status)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		srv := newServer(t)
+		client := srv.Client()
+
+		order, err := client.PlaceLimitOrder(dvotcWS.LimitOrderParams{Asset: "ETH", CounterAsset: "USD", LimitPrice: 2000, Qty: 3, Side: "Sell"})
+		require.NoError(t, err)
+		require.NoError(t, client.CancelOrder(order.ID))
+		assert.Equal(t, dvotcWS.OrderStatusCancelled, srv.Orders()[0].Status)
+		require.ErrorContains(t, client.CancelOrder(order.ID), dvotctest.ErrOrderNotOpen.Error())
+		require.ErrorContains(t, client.CancelOrder("missing"), dvotctest.ErrOrderNotFound.Error())
+	})
+}
+
+func TestServerNotify(t *testing.T) {
+	srv := newServer(t)
+	client := srv.Client()
+
+	sub, err := client.SubscribeBatchCreated()
+	require.NoError(t, err)
+	defer sub.StopConsuming()
+	require.Eventually(t, func() bool {
+		return srv.Subscribers(dvotcWS.NOTIFICATION_BATCH_CREATED, "notifications") == 1
+	}, time.Second, 10*time.Millisecond)
+
+	batch := dvotcWS.BatchCreatedNotification{
+		BatchUUID:    "batch-1",
+		BatchDetails: []dvotcWS.BatchDetail{{Symbol: "BTC", NetQuantity: "1"}},
+	}
+	require.NoError(t, srv.Notify(dvotcWS.NOTIFICATION_BATCH_CREATED, batch))
+	assert.Equal(t, batch, <-sub.Data)
+}
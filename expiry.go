@@ -0,0 +1,151 @@
+package dvotcWS
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+)
+
+var ErrInvalidExpiry = errors.New("limit order expiry is in the past")
+
+// expiryScheduler cancels limit orders client-side once their deadline passes
+type expiryScheduler struct {
+	mu        sync.Mutex
+	deadlines map[string]time.Time
+	stops     map[string]chan struct{}
+}
+
+func newExpiryScheduler() *expiryScheduler {
+	return &expiryScheduler{
+		deadlines: make(map[string]time.Time),
+		stops:     make(map[string]chan struct{}),
+	}
+}
+
+func limitOrderDeadline(limitOrder LimitOrderParams) (time.Time, error) {
+	switch {
+	case !limitOrder.GoodTill.IsZero():
+		if !limitOrder.GoodTill.After(time.Now()) {
+			return time.Time{}, ErrInvalidExpiry
+		}
+		return limitOrder.GoodTill, nil
+	case limitOrder.GoodFor < 0:
+		return time.Time{}, ErrInvalidExpiry
+	case limitOrder.GoodFor > 0:
+		return time.Now().Add(limitOrder.GoodFor), nil
+	}
+	return time.Time{}, nil
+}
+
+// scheduleExpiry cancels orderID at deadline unless an order update shows it
+// filled or cancelled first. Order updates are tracked from before the order is
+// sent, see placeLimitOrder, so a fill answered ahead of the order is in the
+// tracker already. Cancellation dials its own connection with retries so it is
+// not affected by the orders connection reconnecting
+func (dvotc *DVOTCClient) scheduleExpiry(orderID string, deadline time.Time) {
+	s := dvotc.expiries
+	stop := make(chan struct{})
+	s.mu.Lock()
+	if old, ok := s.stops[orderID]; ok {
+		close(old)
+	}
+	s.deadlines[orderID] = deadline
+	s.stops[orderID] = stop
+	s.mu.Unlock()
+
+	updates, stopWatching := dvotc.orders.watch(orderID)
+	go func() {
+		defer stopWatching()
+		defer s.remove(orderID, stop)
+		if order, ok := dvotc.orders.get(orderID); ok && orderResolved(order) {
+			return
+		}
+
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case update := <-updates:
+				if orderResolved(update) {
+					return
+				}
+			case <-timer.C:
+				dvotc.cancelExpired(orderID)
+				return
+			}
+		}
+	}()
+}
+
+// cancelExpired cancels orderID with retries, stopping once the order is seen
+// filled or cancelled on the server
+func (dvotc *DVOTCClient) cancelExpired(orderID string) {
+	err := retry.Do(func() error {
+		err := dvotc.CancelOrder(orderID)
+		if err == nil {
+			return nil
+		}
+		ctx, cancel := dvotc.withRequestTimeout(context.Background())
+		defer cancel()
+		if order, lookupErr := dvotc.fetchOrder(ctx, orderID); lookupErr == nil && orderResolved(*order) {
+			dvotc.orders.update(*order)
+			return retry.Unrecoverable(fmt.Errorf("order %s is %s", orderID, order.Status))
+		}
+		return err
+	},
+		retry.Attempts(5),
+		retry.Delay(1*time.Second),
+		retry.LastErrorOnly(true))
+	if err != nil {
+		log.Printf("failed to cancel expired order %s: %s", orderID, err)
+	}
+}
+
+func orderResolved(order OrderStatus) bool {
+	return isFilledOrderStatus(order) || isCancelledOrderStatus(order)
+}
+
+func (s *expiryScheduler) remove(orderID string, stop chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// the order could have been rescheduled in the meantime
+	if s.stops[orderID] != stop {
+		return
+	}
+	delete(s.stops, orderID)
+	delete(s.deadlines, orderID)
+}
+
+// PendingExpiries returns the deadline of every limit order waiting to be cancelled client-side
+func (dvotc *DVOTCClient) PendingExpiries() map[string]time.Time {
+	s := dvotc.expiries
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deadlines := make(map[string]time.Time, len(s.deadlines))
+	for k, v := range s.deadlines {
+		deadlines[k] = v
+	}
+	return deadlines
+}
+
+// CancelExpiry keeps orderID resting past its deadline, returns false if no expiry was pending
+func (dvotc *DVOTCClient) CancelExpiry(orderID string) bool {
+	s := dvotc.expiries
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stop, ok := s.stops[orderID]
+	if !ok {
+		return false
+	}
+	close(stop)
+	delete(s.stops, orderID)
+	delete(s.deadlines, orderID)
+	return true
+}
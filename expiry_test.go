@@ -0,0 +1,213 @@
+package dvotcWS_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupExpiryServer(t *testing.T) *routerWebsocketServer {
+	return &routerWebsocketServer{
+		t: t,
+		handlers: map[string]func(req dvotcWS.Payload) []dvotcWS.Payload{
+			"createorder": func(req dvotcWS.Payload) []dvotcWS.Payload {
+				order := dvotcWS.Order{}
+				require.NoError(t, json.Unmarshal(req.Data, &order))
+				status := dvotcWS.OrderStatus{
+					ID:        "id-" + order.ClientTag,
+					ClientTag: order.ClientTag,
+					Status:    dvotcWS.OrderStatusOpen,
+					CreatedAt: time.Now().UTC(),
+				}
+				req.Data, _ = json.Marshal(status)
+				return []dvotcWS.Payload{req}
+			},
+			"cancelorder/": func(req dvotcWS.Payload) []dvotcWS.Payload {
+				req.Data = nil
+				return []dvotcWS.Payload{req}
+			},
+		},
+	}
+}
+
+func TestLimitOrderExpiry(t *testing.T) {
+	t.Run("cancelled_at_deadline", func(t *testing.T) {
+		wsServer := setupExpiryServer(t)
+		url := setupRouterWebsocketServer(wsServer)
+		defer wsServer.StopServer()
+
+		client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+		defer client.StopTrackingOrderUpdates()
+
+		order, err := client.PlaceLimitOrder(dvotcWS.LimitOrderParams{
+			Asset:        "BTC",
+			CounterAsset: "USD",
+			LimitPrice:   20000,
+			Qty:          1,
+			Side:         "Buy",
+			ClientTag:    "gtt",
+			GoodFor:      200 * time.Millisecond,
+		})
+		require.NoError(t, err)
+		assert.Contains(t, client.PendingExpiries(), order.ID)
+
+		require.Eventually(t, func() bool {
+			return len(wsServer.Requests("cancelorder/id-gtt")) == 1
+		}, 2*time.Second, 20*time.Millisecond)
+		require.Eventually(t, func() bool {
+			return len(client.PendingExpiries()) == 0
+		}, time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("filled_before_deadline", func(t *testing.T) {
+		wsServer := setupExpiryServer(t)
+		url := setupRouterWebsocketServer(wsServer)
+		defer wsServer.StopServer()
+
+		client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+		defer client.StopTrackingOrderUpdates()
+
+		order, err := client.PlaceLimitOrder(dvotcWS.LimitOrderParams{
+			Asset:        "BTC",
+			CounterAsset: "USD",
+			LimitPrice:   20000,
+			Qty:          1,
+			Side:         "Buy",
+			ClientTag:    "fill",
+			GoodTill:     time.Now().Add(300 * time.Millisecond),
+		})
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			return len(wsServer.Requests("order/")) == 1
+		}, time.Second, 10*time.Millisecond)
+
+		now := time.Now().UTC()
+		order.Status = dvotcWS.OrderStatusComplete
+		order.FilledAt = &now
+		data, err := json.Marshal(order)
+		require.NoError(t, err)
+		wsServer.Publish(dvotcWS.Payload{Type: dvotcWS.MessageTypeSubscribe, Event: "order-updates", Topic: "order/#", Data: data})
+
+		require.Eventually(t, func() bool {
+			return len(client.PendingExpiries()) == 0
+		}, time.Second, 10*time.Millisecond)
+		time.Sleep(400 * time.Millisecond)
+		assert.Empty(t, wsServer.Requests("cancelorder/"))
+	})
+
+	t.Run("expiry_cancelled", func(t *testing.T) {
+		wsServer := setupExpiryServer(t)
+		url := setupRouterWebsocketServer(wsServer)
+		defer wsServer.StopServer()
+
+		client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+		defer client.StopTrackingOrderUpdates()
+
+		order, err := client.PlaceLimitOrder(dvotcWS.LimitOrderParams{
+			Asset:        "BTC",
+			CounterAsset: "USD",
+			Qty:          1,
+			Side:         "Buy",
+			ClientTag:    "keep",
+			GoodFor:      200 * time.Millisecond,
+		})
+		require.NoError(t, err)
+		assert.True(t, client.CancelExpiry(order.ID))
+		assert.False(t, client.CancelExpiry(order.ID))
+
+		time.Sleep(400 * time.Millisecond)
+		assert.Empty(t, wsServer.Requests("cancelorder/"))
+	})
+
+	t.Run("filled_ahead_of_response", func(t *testing.T) {
+		wsServer := setupExpiryServer(t)
+		createOrder := wsServer.handlers["createorder"]
+		wsServer.handlers["createorder"] = func(req dvotcWS.Payload) []dvotcWS.Payload {
+			// the fill is published before the order is answered
+			now := time.Now().UTC()
+			data, _ := json.Marshal(dvotcWS.OrderStatus{ID: "id-gap", Status: dvotcWS.OrderStatusComplete, FilledAt: &now})
+			wsServer.Publish(dvotcWS.Payload{Type: dvotcWS.MessageTypeSubscribe, Event: "order-updates", Topic: "order/#", Data: data})
+			time.Sleep(50 * time.Millisecond)
+			return createOrder(req)
+		}
+		url := setupRouterWebsocketServer(wsServer)
+		defer wsServer.StopServer()
+
+		client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+		defer client.StopTrackingOrderUpdates()
+
+		_, err := client.PlaceLimitOrder(dvotcWS.LimitOrderParams{
+			Asset:        "BTC",
+			CounterAsset: "USD",
+			LimitPrice:   20000,
+			Qty:          1,
+			Side:         "Buy",
+			ClientTag:    "gap",
+			GoodFor:      200 * time.Millisecond,
+		})
+		require.NoError(t, err)
+		// order updates were subscribed to before the order was sent
+		requests := wsServer.Requests("")
+		require.GreaterOrEqual(t, len(requests), 2)
+		assert.Equal(t, "order/#", requests[0].Topic)
+
+		require.Eventually(t, func() bool {
+			return len(client.PendingExpiries()) == 0
+		}, time.Second, 10*time.Millisecond)
+		time.Sleep(300 * time.Millisecond)
+		assert.Empty(t, wsServer.Requests("cancelorder/"))
+	})
+
+	t.Run("filled_when_cancelled", func(t *testing.T) {
+		wsServer := setupExpiryServer(t)
+		wsServer.handlers["cancelorder/"] = func(req dvotcWS.Payload) []dvotcWS.Payload {
+			req.Type = dvotcWS.MessageTypeError
+			req.Data = []byte(`{"message": "order already filled"}`)
+			return []dvotcWS.Payload{req}
+		}
+		wsServer.handlers["tradestatus"] = func(req dvotcWS.Payload) []dvotcWS.Payload {
+			req.Data = []byte(`[{"_id": "id-late", "status": "Complete"}]`)
+			return []dvotcWS.Payload{req}
+		}
+		url := setupRouterWebsocketServer(wsServer)
+		defer wsServer.StopServer()
+
+		client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+		defer client.StopTrackingOrderUpdates()
+
+		_, err := client.PlaceLimitOrder(dvotcWS.LimitOrderParams{
+			Asset:        "BTC",
+			CounterAsset: "USD",
+			LimitPrice:   20000,
+			Qty:          1,
+			Side:         "Buy",
+			ClientTag:    "late",
+			GoodFor:      100 * time.Millisecond,
+		})
+		require.NoError(t, err)
+
+		// the failed cancel is not retried once the order is seen filled
+		require.Eventually(t, func() bool {
+			return len(client.PendingExpiries()) == 0
+		}, 2*time.Second, 10*time.Millisecond)
+		assert.Len(t, wsServer.Requests("cancelorder/id-late"), 1)
+		assert.Len(t, wsServer.Requests("tradestatus"), 1)
+		assert.Empty(t, client.OpenOrders())
+	})
+
+	t.Run("deadline_in_past", func(t *testing.T) {
+		client := dvotcWS.NewDVOTCClient("ws://localhost/websocket", "123", "321")
+		_, err := client.PlaceLimitOrder(dvotcWS.LimitOrderParams{
+			Asset:        "BTC",
+			CounterAsset: "USD",
+			Qty:          1,
+			Side:         "Buy",
+			GoodTill:     time.Now().Add(-time.Minute),
+		})
+		require.ErrorIs(t, err, dvotcWS.ErrInvalidExpiry)
+	})
+}
@@ -0,0 +1,245 @@
+// Package export writes trade blotters as CSV or JSON Lines with a stable set of columns
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+)
+
+var ErrUnknownFormat = errors.New("unknown export format")
+
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatJSONL Format = "jsonl"
+)
+
+// Columns lists the exported fields in the order they are written, new columns are only ever appended
+var Columns = []string{
+	"id",
+	"client_tag",
+	"asset",
+	"counter_asset",
+	"side",
+	"status",
+	"quantity",
+	"price",
+	"limit_price",
+	"user_id",
+	"user_first_name",
+	"user_last_name",
+	"batch_id",
+	"batch_settled",
+	"created_at",
+	"updated_at",
+	"filled_at",
+}
+
+type Options struct {
+	// Location is the timezone of the exported times, defaults to UTC
+	Location *time.Location
+	// TimeFormat defaults to time.RFC3339Nano
+	TimeFormat string
+}
+
+func (o Options) withDefaults() Options {
+	if o.Location == nil {
+		o.Location = time.UTC
+	}
+	if o.TimeFormat == "" {
+		o.TimeFormat = time.RFC3339Nano
+	}
+	return o
+}
+
+// Writer writes trades one at a time, Flush must be called once done
+type Writer interface {
+	Write(trade dvotcWS.Trade) error
+	Flush() error
+}
+
+func NewWriter(format Format, w io.Writer, opts Options) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVWriter(w, opts), nil
+	case FormatJSONL:
+		return NewJSONLWriter(w, opts), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+// WriteTrades writes trades and flushes w
+func WriteTrades(w Writer, trades []dvotcWS.Trade) error {
+	for _, trade := range trades {
+		if err := w.Write(trade); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// WriteIterator writes every trade of it and flushes w, it returns the number of trades written
+func WriteIterator(w Writer, it *dvotcWS.TradeIterator) (int, error) {
+	n := 0
+	for it.Next() {
+		if err := w.Write(it.Trade()); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := it.Err(); err != nil {
+		return n, err
+	}
+	return n, w.Flush()
+}
+
+// CSVWriter writes a header row followed by one row per trade
+type CSVWriter struct {
+	csv         *csv.Writer
+	opts        Options
+	wroteHeader bool
+}
+
+func NewCSVWriter(w io.Writer, opts Options) *CSVWriter {
+	return &CSVWriter{
+		csv:  csv.NewWriter(w),
+		opts: opts.withDefaults(),
+	}
+}
+
+func (c *CSVWriter) Write(trade dvotcWS.Trade) error {
+	if err := c.header(); err != nil {
+		return err
+	}
+	return c.csv.Write(record(trade, c.opts))
+}
+
+// Flush writes the header even when there were no trades so empty exports keep their columns
+func (c *CSVWriter) Flush() error {
+	if err := c.header(); err != nil {
+		return err
+	}
+	c.csv.Flush()
+	return c.csv.Error()
+}
+
+func (c *CSVWriter) header() error {
+	if c.wroteHeader {
+		return nil
+	}
+	c.wroteHeader = true
+	return c.csv.Write(Columns)
+}
+
+// JSONLWriter writes one JSON object per trade and line with the keys of Columns in order,
+// numbers are written as plain decimals
+type JSONLWriter struct {
+	enc  *json.Encoder
+	opts Options
+}
+
+func NewJSONLWriter(w io.Writer, opts Options) *JSONLWriter {
+	return &JSONLWriter{
+		enc:  json.NewEncoder(w),
+		opts: opts.withDefaults(),
+	}
+}
+
+func (j *JSONLWriter) Write(trade dvotcWS.Trade) error {
+	return j.enc.Encode(newRow(trade, j.opts))
+}
+
+func (j *JSONLWriter) Flush() error {
+	return nil
+}
+
+// row mirrors Columns, keep both in the same order
+type row struct {
+	ID            string      `json:"id"`
+	ClientTag     string      `json:"client_tag"`
+	Asset         string      `json:"asset"`
+	CounterAsset  string      `json:"counter_asset"`
+	Side          string      `json:"side"`
+	Status        string      `json:"status"`
+	Quantity      json.Number `json:"quantity"`
+	Price         json.Number `json:"price"`
+	LimitPrice    json.Number `json:"limit_price"`
+	UserID        string      `json:"user_id"`
+	UserFirstName string      `json:"user_first_name"`
+	UserLastName  string      `json:"user_last_name"`
+	BatchID       string      `json:"batch_id"`
+	BatchSettled  bool        `json:"batch_settled"`
+	CreatedAt     string      `json:"created_at"`
+	UpdatedAt     string      `json:"updated_at"`
+	FilledAt      string      `json:"filled_at"`
+}
+
+func newRow(trade dvotcWS.Trade, opts Options) row {
+	updatedAt := ""
+	if trade.UpdatedAt != nil {
+		updatedAt = formatTime(*trade.UpdatedAt, opts)
+	}
+	return row{
+		ID:            trade.ID,
+		ClientTag:     trade.ClientTag,
+		Asset:         trade.Asset,
+		CounterAsset:  trade.CounterAsset,
+		Side:          trade.Side,
+		Status:        trade.Status,
+		Quantity:      json.Number(strconv.FormatInt(trade.Quantity, 10)),
+		Price:         json.Number(FormatDecimal(trade.Price)),
+		LimitPrice:    json.Number(FormatDecimal(trade.LimitPrice)),
+		UserID:        trade.User.ID,
+		UserFirstName: trade.User.FirstName,
+		UserLastName:  trade.User.LastName,
+		BatchID:       trade.Batch.ID,
+		BatchSettled:  trade.Batch.Settled,
+		CreatedAt:     formatTime(trade.CreatedAt, opts),
+		UpdatedAt:     updatedAt,
+		FilledAt:      formatTime(trade.FilledAt, opts),
+	}
+}
+
+func record(trade dvotcWS.Trade, opts Options) []string {
+	r := newRow(trade, opts)
+	return []string{
+		r.ID,
+		r.ClientTag,
+		r.Asset,
+		r.CounterAsset,
+		r.Side,
+		r.Status,
+		r.Quantity.String(),
+		r.Price.String(),
+		r.LimitPrice.String(),
+		r.UserID,
+		r.UserFirstName,
+		r.UserLastName,
+		r.BatchID,
+		strconv.FormatBool(r.BatchSettled),
+		r.CreatedAt,
+		r.UpdatedAt,
+		r.FilledAt,
+	}
+}
+
+// FormatDecimal formats f as the shortest plain decimal that parses back to f, never in exponent notation
+func FormatDecimal(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// formatTime leaves zero times empty
+func formatTime(t time.Time, opts Options) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(opts.Location).Format(opts.TimeFormat)
+}
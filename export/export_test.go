@@ -0,0 +1,90 @@
+package export_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/export"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportTrades() []dvotcWS.Trade {
+	created := time.Date(2023, 3, 1, 14, 30, 0, 0, time.UTC)
+	filled := created.Add(time.Second)
+	return []dvotcWS.Trade{
+		{
+			ID:           "t1",
+			ClientTag:    "desk, \"a\"",
+			Asset:        "BTC",
+			CounterAsset: "USD",
+			Side:         "Buy",
+			Status:       "Complete",
+			Quantity:     3,
+			Price:        0.00000001,
+			LimitPrice:   123456789012.5,
+			User:         dvotcWS.User{ID: "u1", FirstName: "Ada", LastName: "Lovelace"},
+			Batch:        dvotcWS.Batch{ID: "b1", Settled: true},
+			CreatedAt:    created,
+			FilledAt:     filled,
+		},
+		{ID: "t2", Asset: "ETH", CounterAsset: "USD", Side: "Sell", Quantity: 1, Price: 1500.25, CreatedAt: created},
+	}
+}
+
+func TestCSV(t *testing.T) {
+	nyc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, export.WriteTrades(export.NewCSVWriter(buf, export.Options{Location: nyc}), exportTrades()))
+
+	records, err := csv.NewReader(buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, export.Columns, records[0])
+	assert.Equal(t, []string{
+		"t1", "desk, \"a\"", "BTC", "USD", "Buy", "Complete", "3", "0.00000001", "123456789012.5",
+		"u1", "Ada", "Lovelace", "b1", "true",
+		"2023-03-01T09:30:00-05:00", "", "2023-03-01T09:30:01-05:00",
+	}, records[1])
+	assert.Equal(t, "", records[2][16])
+
+	empty := &bytes.Buffer{}
+	require.NoError(t, export.WriteTrades(export.NewCSVWriter(empty, export.Options{}), nil))
+	assert.Equal(t, strings.Join(export.Columns, ",")+"\n", empty.String())
+}
+
+func TestJSONL(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := export.NewWriter(export.FormatJSONL, buf, export.Options{})
+	require.NoError(t, err)
+	require.NoError(t, export.WriteTrades(w, exportTrades()))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], `{"id":"t1","client_tag":"desk, \"a\"","asset":"BTC"`))
+	assert.Contains(t, lines[0], `"price":0.00000001,"limit_price":123456789012.5`)
+	assert.Contains(t, lines[0], `"created_at":"2023-03-01T14:30:00Z"`)
+
+	row := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &row))
+	assert.Len(t, row, len(export.Columns))
+	for _, column := range export.Columns {
+		assert.Contains(t, row, column)
+	}
+
+	_, err = export.NewWriter("parquet", buf, export.Options{})
+	require.ErrorIs(t, err, export.ErrUnknownFormat)
+}
+
+func TestFormatDecimal(t *testing.T) {
+	assert.Equal(t, "0.1", export.FormatDecimal(0.1))
+	assert.Equal(t, "1000000000000000000000", export.FormatDecimal(1e21))
+	assert.Equal(t, "-0.000000123", export.FormatDecimal(-1.23e-7))
+}
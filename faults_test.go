@@ -0,0 +1,302 @@
+package dvotcWS_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/dvotctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupFaultServer(t *testing.T) (*dvotctest.Server, *dvotcWS.DVOTCClient) {
+	srv := dvotctest.NewServer(dvotctest.Config{})
+	t.Cleanup(srv.Close)
+	srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 20010, SellPrice: 19990, MaxQuantity: 10})
+	return srv, srv.Client()
+}
+
+func marketOrder(srv *dvotctest.Server, clientTag string) dvotcWS.MarketOrderParams {
+	levels, _ := srv.Levels("BTC/USD")
+	return dvotcWS.MarketOrderParams{
+		QuoteID:      levels.QuoteID,
+		Asset:        "BTC",
+		CounterAsset: "USD",
+		Price:        20010,
+		Qty:          1,
+		Side:         "Buy",
+		ClientTag:    clientTag,
+	}
+}
+
+func TestFaultDelay(t *testing.T) {
+	srv, client := setupFaultServer(t)
+	srv.InjectFault("createorder", dvotctest.Fault{Delay: 200 * time.Millisecond, Times: 2})
+
+	start := time.Now()
+	order, err := client.PlaceMarketOrder(marketOrder(srv, "slow"))
+	require.NoError(t, err)
+	assert.Equal(t, "slow", order.ClientTag)
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	results, err := client.PlaceOrders(ctx, []dvotcWS.OrderRequest{{Market: ptr(marketOrder(srv, "too-slow"))}})
+	require.ErrorIs(t, err, dvotcWS.ErrBatchPartialFailure)
+	require.ErrorIs(t, results[0].Err, context.DeadlineExceeded)
+
+	// the late response is dropped and the connection keeps serving
+	time.Sleep(250 * time.Millisecond)
+	_, err = client.PlaceMarketOrder(marketOrder(srv, "after"))
+	require.NoError(t, err)
+}
+
+func TestFaultDropConnection(t *testing.T) {
+	t.Run("pending_request", func(t *testing.T) {
+		srv, client := setupFaultServer(t)
+		srv.InjectFault("createorder", dvotctest.Fault{Drop: true, Times: 1})
+
+		_, err := client.PlaceMarketOrder(marketOrder(srv, "dropped"))
+		require.Error(t, err)
+
+		// the next order opens a new connection
+		order, err := client.PlaceMarketOrder(marketOrder(srv, "retried"))
+		require.NoError(t, err)
+		assert.Equal(t, "retried", order.ClientTag)
+	})
+
+	t.Run("mid_stream", func(t *testing.T) {
+		srv, client := setupFaultServer(t)
+		sub, err := client.SubscribeOrderChanges("#")
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			return srv.Subscribers("order/#", "order-updates") == 1
+		}, time.Second, 10*time.Millisecond)
+
+		assert.Equal(t, 1, srv.DropSubscribers("order/#", "order-updates"))
+		select {
+		case _, ok := <-sub.Data:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			t.Fatal("subscription not closed after the connection dropped")
+		}
+	})
+
+	t.Run("order_updates_resubscribed", func(t *testing.T) {
+		srv, client := setupFaultServer(t)
+		require.NoError(t, client.TrackOrderUpdates())
+		defer client.StopTrackingOrderUpdates()
+		require.Eventually(t, func() bool {
+			return srv.Subscribers("order/#", "order-updates") == 1
+		}, time.Second, 10*time.Millisecond)
+
+		order, err := client.PlaceLimitOrder(dvotcWS.LimitOrderParams{Asset: "BTC", CounterAsset: "USD", LimitPrice: 19000, Qty: 1, Side: "Buy"})
+		require.NoError(t, err)
+		require.Len(t, client.OpenOrders(), 1)
+
+		require.Equal(t, 1, srv.DropSubscribers("order/#", "order-updates"))
+		require.Eventually(t, func() bool {
+			return len(srv.Requests("order/#")) == 2 && srv.Subscribers("order/#", "order-updates") == 1
+		}, 2*time.Second, 10*time.Millisecond)
+
+		// the fill reaches the tracker through the new subscription
+		srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 18990, SellPrice: 18970, MaxQuantity: 10})
+		require.Eventually(t, func() bool {
+			return len(client.OpenOrders()) == 0
+		}, time.Second, 10*time.Millisecond)
+		_, err = client.ReplaceLimitOrder(context.Background(), order.ID, 19500, 1)
+		require.ErrorIs(t, err, dvotcWS.ErrOrderAlreadyFilled)
+	})
+
+	t.Run("levels_resubscribed", func(t *testing.T) {
+		srv, client := setupFaultServer(t)
+		sub, err := client.SubscribeLevels("BTC/USD")
+		require.NoError(t, err)
+		<-sub.Data
+
+		assert.Equal(t, 1, srv.DropSubscribers("BTC/USD", "levels"))
+		require.Eventually(t, func() bool {
+			return srv.Subscribers("BTC/USD", "levels") == 1
+		}, 2*time.Second, 10*time.Millisecond)
+		srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 21010, SellPrice: 20990, MaxQuantity: 10})
+		timeout := time.After(2 * time.Second)
+		for price := 0.0; price != 21010; {
+			select {
+			case levels, ok := <-sub.Data:
+				require.True(t, ok, "levels subscription closed after the connection dropped")
+				price = levels.Levels[0].BuyPrice
+			case <-timeout:
+				t.Fatal("no levels after the connection dropped")
+			}
+		}
+
+		// new subscriptions go to the replacement connection
+		srv.SetLevels("ETH/USD", dvotcWS.Level{BuyPrice: 1510, SellPrice: 1490, MaxQuantity: 10})
+		eth, err := client.SubscribeLevels("ETH/USD")
+		require.NoError(t, err)
+		select {
+		case levels := <-eth.Data:
+			assert.Equal(t, 1510.0, levels.Levels[0].BuyPrice)
+		case <-time.After(time.Second):
+			t.Fatal("no levels after subscribing on the replacement connection")
+		}
+	})
+
+	t.Run("levels_closed", func(t *testing.T) {
+		srv, client := setupFaultServer(t)
+		client.SetConnectRetries(1, 10*time.Millisecond)
+		sub, err := client.SubscribeLevels("BTC/USD")
+		require.NoError(t, err)
+		<-sub.Data
+
+		// the server is gone, reconnecting fails and the subscription is closed
+		srv.Close()
+		select {
+		case _, ok := <-sub.Data:
+			assert.False(t, ok)
+		case <-time.After(2 * time.Second):
+			t.Fatal("levels subscription not closed after reconnecting failed")
+		}
+		assert.ErrorIs(t, sub.StopConsuming(), dvotcWS.ErrSubscriptionAlreadyClosed)
+	})
+}
+
+func TestFaultReconnect(t *testing.T) {
+	t.Run("levels", func(t *testing.T) {
+		srv, client := setupFaultServer(t)
+		btc, err := client.SubscribeLevels("BTC/USD")
+		require.NoError(t, err)
+		<-btc.Data
+
+		assert.Equal(t, 1, srv.SendReconnect("BTC/USD", "levels"))
+		// resubscribed on the new connection, which sends the current levels again
+		<-btc.Data
+		srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 21010, SellPrice: 20990, MaxQuantity: 10})
+		assert.Equal(t, 21010.0, (<-btc.Data).Levels[0].BuyPrice)
+
+		// new symbols are subscribed on the new connection too
+		srv.SetLevels("ETH/USD", dvotcWS.Level{BuyPrice: 1510, SellPrice: 1490, MaxQuantity: 10})
+		eth, err := client.SubscribeLevels("ETH/USD")
+		require.NoError(t, err)
+		select {
+		case data := <-eth.Data:
+			assert.Equal(t, "ETH/USD", data.Market)
+		case <-time.After(time.Second):
+			t.Fatal("no levels after subscribing on the reconnected connection")
+		}
+	})
+
+	t.Run("order_updates", func(t *testing.T) {
+		srv, client := setupFaultServer(t)
+		sub, err := client.SubscribeOrderChanges("#")
+		require.NoError(t, err)
+		defer sub.StopConsuming()
+		require.Eventually(t, func() bool {
+			return srv.Subscribers("order/#", "order-updates") == 1
+		}, time.Second, 10*time.Millisecond)
+
+		assert.Equal(t, 1, srv.SendReconnect("order/#", "order-updates"))
+		require.Eventually(t, func() bool {
+			return len(srv.Requests("order/#")) == 2 && srv.Subscribers("order/#", "order-updates") == 1
+		}, 3*time.Second, 10*time.Millisecond)
+
+		order, err := client.PlaceMarketOrder(marketOrder(srv, "after-reconnect"))
+		require.NoError(t, err)
+		assert.Equal(t, order.ID, (<-sub.Data).ID)
+	})
+}
+
+func TestFaultMalformed(t *testing.T) {
+	t.Run("levels_stream", func(t *testing.T) {
+		srv, client := setupFaultServer(t)
+		sub, err := client.SubscribeLevels("BTC/USD")
+		require.NoError(t, err)
+		<-sub.Data
+
+		assert.Equal(t, 1, srv.SendRaw("BTC/USD", "levels", []byte(`{"type":"subscribe","topic":"BTC/USD","ev`)))
+		srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 21010, SellPrice: 20990, MaxQuantity: 10})
+		assert.Equal(t, 21010.0, (<-sub.Data).Levels[0].BuyPrice)
+	})
+
+	t.Run("order_response", func(t *testing.T) {
+		srv, client := setupFaultServer(t)
+		client.SetRequestTimeout(200 * time.Millisecond)
+		srv.InjectFault("createorder", dvotctest.Fault{Malformed: true, Times: 1})
+
+		_, err := client.PlaceMarketOrder(marketOrder(srv, "malformed"))
+		require.ErrorIs(t, err, dvotcWS.ErrRequestTimeout)
+
+		order, err := client.PlaceMarketOrder(marketOrder(srv, "fine"))
+		require.NoError(t, err)
+		assert.Equal(t, "fine", order.ClientTag)
+	})
+
+	t.Run("notifications", func(t *testing.T) {
+		srv, client := setupFaultServer(t)
+		sub, err := client.SubscribeOrderFilled()
+		require.NoError(t, err)
+		defer sub.StopConsuming()
+		require.Eventually(t, func() bool {
+			return srv.Subscribers(dvotcWS.NOTIFICATION_ORDER_FILLED, "notifications") == 1
+		}, time.Second, 10*time.Millisecond)
+
+		srv.SendRaw(dvotcWS.NOTIFICATION_ORDER_FILLED, "notifications", []byte(`not json`))
+		order, err := client.PlaceMarketOrder(marketOrder(srv, "notified"))
+		require.NoError(t, err)
+		assert.Equal(t, order.ID, (<-sub.Data).ID)
+	})
+}
+
+func TestFaultTopicErrors(t *testing.T) {
+	t.Run("requests", func(t *testing.T) {
+		srv, client := setupFaultServer(t)
+		srv.FailTopic("createorder", "trading disabled")
+		srv.FailTopic("tradestatus", "history unavailable")
+
+		_, err := client.PlaceMarketOrder(marketOrder(srv, "rejected"))
+		require.EqualError(t, err, "trading disabled")
+		_, err = client.ListTrades(nil, nil, nil)
+		require.ErrorContains(t, err, "history unavailable")
+		assert.Empty(t, srv.Orders())
+
+		srv.ClearFaults()
+		_, err = client.PlaceMarketOrder(marketOrder(srv, "accepted"))
+		require.NoError(t, err)
+	})
+
+	t.Run("subscription", func(t *testing.T) {
+		srv, client := setupFaultServer(t)
+		srv.InjectFault("order/", dvotctest.Fault{Error: "not allowed"})
+		sub, err := client.SubscribeOrderChanges("#")
+		require.NoError(t, err)
+		select {
+		case _, ok := <-sub.Data:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			t.Fatal("subscription not closed after an error")
+		}
+	})
+}
+
+func TestFaultReorderedResponses(t *testing.T) {
+	srv, client := setupFaultServer(t)
+	srv.InjectFault("createorder", dvotctest.Fault{Reorder: 3, Times: 3})
+
+	requests := make([]dvotcWS.OrderRequest, 0, 3)
+	for i := 0; i < 3; i++ {
+		requests = append(requests, dvotcWS.OrderRequest{Market: ptr(marketOrder(srv, fmt.Sprintf("order-%d", i)))})
+	}
+	results, err := client.PlaceOrders(context.Background(), requests)
+	require.NoError(t, err)
+	for i, result := range results {
+		require.NoError(t, result.Err)
+		assert.Equal(t, fmt.Sprintf("order-%d", i), result.Order.ClientTag)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -3,10 +3,12 @@ module github.com/dv-chain/dvotc-websocket-go
 go 1.19
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/avast/retry-go/v4 v4.3.2
 	github.com/fasthttp/websocket v1.5.0
 	github.com/go-faker/faker/v4 v4.0.0
 	github.com/stretchr/testify v1.8.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -18,5 +20,4 @@ require (
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasthttp v1.44.0 // indirect
 	golang.org/x/text v0.9.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
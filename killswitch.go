@@ -0,0 +1,113 @@
+package dvotcWS
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrTradingHalted        = errors.New("trading halted by kill switch")
+	ErrKillSwitchIncomplete = errors.New("kill switch could not cancel all open orders")
+)
+
+type KillSwitchReport struct {
+	// Cancelled holds the IDs of orders cancelled successfully
+	Cancelled []string
+	// Failed maps order IDs to the error returned cancelling them
+	Failed map[string]error
+	// DiscoveryErr is set when open orders could not be listed from the server,
+	// only orders tracked locally were cancelled then
+	DiscoveryErr error
+}
+
+// KillSwitch blocks all further order placement with ErrTradingHalted and
+// cancels every open order it can find through CancelOrders, trading stays
+// halted until Resume is called.
+//
+// Open orders are taken from the order tracker, kept current by TrackOrderUpdates.
+// Without it the trades listed with an open status are cancelled too, listing them
+// takes the whole trade history.
+// Orders still being placed are not waited for, they are cancelled once answered
+// and their placement returns ErrTradingHalted.
+func (dvotc *DVOTCClient) KillSwitch() (*KillSwitchReport, error) {
+	report := &KillSwitchReport{
+		Cancelled: make([]string, 0),
+		Failed:    make(map[string]error),
+	}
+
+	orderIDs := make([]string, 0)
+	dvotc.placing.Lock()
+	dvotc.halted.Store(true)
+	dvotc.killed = make(map[string]bool)
+	for _, o := range dvotc.orders.open() {
+		dvotc.killed[o.ID] = true
+		orderIDs = append(orderIDs, o.ID)
+	}
+	dvotc.placing.Unlock()
+
+	if !dvotc.trackingOrderUpdates() {
+		open, err := dvotc.listOpenTrades()
+		report.DiscoveryErr = err
+		dvotc.placing.Lock()
+		for _, id := range open {
+			if _, tracked := dvotc.orders.get(id); tracked || dvotc.killed[id] {
+				// cancelled above or by its placement
+				continue
+			}
+			dvotc.killed[id] = true
+			orderIDs = append(orderIDs, id)
+		}
+		dvotc.placing.Unlock()
+	}
+
+	results, _ := dvotc.CancelOrders(context.Background(), orderIDs)
+	for _, res := range results {
+		if res.Err != nil {
+			report.Failed[res.OrderID] = res.Err
+			continue
+		}
+		report.Cancelled = append(report.Cancelled, res.OrderID)
+	}
+
+	if report.DiscoveryErr != nil {
+		return report, fmt.Errorf("%w: listing open orders: %s", ErrKillSwitchIncomplete, report.DiscoveryErr)
+	}
+	if len(report.Failed) > 0 {
+		return report, fmt.Errorf("%w: %d of %d cancellations failed", ErrKillSwitchIncomplete, len(report.Failed), len(orderIDs))
+	}
+	return report, nil
+}
+
+// listOpenTrades lists the IDs of the trades with an open status, bounded by the request timeout
+func (dvotc *DVOTCClient) listOpenTrades() ([]string, error) {
+	ctx, cancel := dvotc.withRequestTimeout(context.Background())
+	defer cancel()
+	ids := make([]string, 0)
+	it := dvotc.Trades(ctx, NewTradeQuery())
+	for it.Next() {
+		if t := it.Trade(); isOpenOrderStatus(t.Status) {
+			ids = append(ids, t.ID)
+		}
+	}
+	return ids, it.Err()
+}
+
+// placedWhileHalted tracks order, just answered, and reports whether the kill switch
+// went off while it was in flight, cancel is set when the kill switch did not see it
+func (dvotc *DVOTCClient) placedWhileHalted(order OrderStatus) (halted, cancel bool) {
+	dvotc.placing.Lock()
+	defer dvotc.placing.Unlock()
+	dvotc.orders.update(order)
+	halted = dvotc.halted.Load() && isOpenOrderStatus(order.Status)
+	return halted, halted && !dvotc.killed[order.ID]
+}
+
+// Resume allows placing orders again after KillSwitch
+func (dvotc *DVOTCClient) Resume() {
+	dvotc.halted.Store(false)
+}
+
+func (dvotc *DVOTCClient) IsHalted() bool {
+	return dvotc.halted.Load()
+}
@@ -0,0 +1,180 @@
+package dvotcWS_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/dvotctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKillSwitch(t *testing.T) {
+	trades := []dvotcWS.Trade{
+		{ID: "open-1", Status: "Open", CreatedAt: time.Now().UTC(), FilledAt: time.Now().UTC()},
+		{ID: "open-2", Status: "Open", CreatedAt: time.Now().UTC(), FilledAt: time.Now().UTC()},
+		{ID: "fail-3", Status: "Open", CreatedAt: time.Now().UTC(), FilledAt: time.Now().UTC()},
+		{ID: "done-4", Status: "Complete", CreatedAt: time.Now().UTC(), FilledAt: time.Now().UTC()},
+	}
+	tradesBytes, err := json.Marshal(trades)
+	require.NoError(t, err)
+
+	wsServer := &routerWebsocketServer{
+		t: t,
+		handlers: map[string]func(req dvotcWS.Payload) []dvotcWS.Payload{
+			"tradestatus": func(req dvotcWS.Payload) []dvotcWS.Payload {
+				req.Data = tradesBytes
+				return []dvotcWS.Payload{req}
+			},
+			"cancelorder/": func(req dvotcWS.Payload) []dvotcWS.Payload {
+				req.Data = nil
+				if strings.HasSuffix(req.Topic, "fail-3") {
+					req.Type = dvotcWS.MessageTypeError
+					req.Data = []byte(`{"message": "order not found"}`)
+				}
+				return []dvotcWS.Payload{req}
+			},
+		},
+	}
+	url := setupRouterWebsocketServer(wsServer)
+	defer wsServer.StopServer()
+
+	client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+	assert.False(t, client.IsHalted())
+
+	report, err := client.KillSwitch()
+	require.ErrorIs(t, err, dvotcWS.ErrKillSwitchIncomplete)
+	assert.True(t, client.IsHalted())
+	assert.NoError(t, report.DiscoveryErr)
+	assert.ElementsMatch(t, []string{"open-1", "open-2"}, report.Cancelled)
+	require.Len(t, report.Failed, 1)
+	assert.ErrorContains(t, report.Failed["fail-3"], "order not found")
+	assert.Len(t, wsServer.Requests("cancelorder/"), 3)
+
+	// orders are blocked until resumed
+	_, err = client.PlaceMarketOrder(dvotcWS.MarketOrderParams{Asset: "BTC", CounterAsset: "USD", Qty: 1, Side: "Buy"})
+	require.ErrorIs(t, err, dvotcWS.ErrTradingHalted)
+	_, err = client.PlaceLimitOrder(dvotcWS.LimitOrderParams{Asset: "BTC", CounterAsset: "USD", Qty: 1, Side: "Buy"})
+	require.ErrorIs(t, err, dvotcWS.ErrTradingHalted)
+
+	client.Resume()
+	assert.False(t, client.IsHalted())
+}
+
+func TestKillSwitch_NoOpenOrders(t *testing.T) {
+	wsServer := &routerWebsocketServer{
+		t: t,
+		handlers: map[string]func(req dvotcWS.Payload) []dvotcWS.Payload{
+			"tradestatus": func(req dvotcWS.Payload) []dvotcWS.Payload {
+				req.Data = []byte(`[]`)
+				return []dvotcWS.Payload{req}
+			},
+		},
+	}
+	url := setupRouterWebsocketServer(wsServer)
+	defer wsServer.StopServer()
+
+	client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+	report, err := client.KillSwitch()
+	require.NoError(t, err)
+	assert.Empty(t, report.Cancelled)
+	assert.Empty(t, report.Failed)
+	assert.True(t, client.IsHalted())
+}
+
+func TestKillSwitch_InFlightOrder(t *testing.T) {
+	t.Run("listed_by_the_scan", func(t *testing.T) {
+		server := dvotctest.NewServer(dvotctest.Config{})
+		defer server.Close()
+		server.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 20010, SellPrice: 19990, MaxQuantity: 10})
+		client := server.Client()
+
+		// the order is created but its response is held when the kill switch starts
+		server.InjectFault("createorder", dvotctest.Fault{Delay: 300 * time.Millisecond})
+		placed := make(chan error, 1)
+		go func() {
+			_, err := client.PlaceLimitOrder(dvotcWS.LimitOrderParams{Asset: "BTC", CounterAsset: "USD", Qty: 1, Side: "Buy", LimitPrice: 19000})
+			placed <- err
+		}()
+		require.Eventually(t, func() bool { return len(server.Orders()) == 1 }, time.Second, 5*time.Millisecond)
+
+		report, err := client.KillSwitch()
+		require.NoError(t, err)
+		select {
+		case <-placed:
+			require.Fail(t, "kill switch waited for the in-flight order")
+		default:
+		}
+		assert.Equal(t, []string{server.Orders()[0].ID}, report.Cancelled)
+
+		// the placement is told, the order is not cancelled twice
+		require.ErrorIs(t, <-placed, dvotcWS.ErrTradingHalted)
+		assert.Len(t, server.Requests("cancelorder/"), 1)
+		assert.True(t, server.Orders()[0].IsCancelled())
+	})
+
+	t.Run("cancelled_by_its_placement", func(t *testing.T) {
+		answer := make(chan struct{})
+		wsServer := &routerWebsocketServer{
+			t: t,
+			handlers: map[string]func(req dvotcWS.Payload) []dvotcWS.Payload{
+				"tradestatus": func(req dvotcWS.Payload) []dvotcWS.Payload {
+					req.Data = []byte(`[]`)
+					return []dvotcWS.Payload{req}
+				},
+				"createorder": func(req dvotcWS.Payload) []dvotcWS.Payload {
+					<-answer
+					req.Data = []byte(`{"_id": "late-1", "status": "Open"}`)
+					return []dvotcWS.Payload{req}
+				},
+				"cancelorder/": func(req dvotcWS.Payload) []dvotcWS.Payload {
+					req.Data = nil
+					return []dvotcWS.Payload{req}
+				},
+			},
+		}
+		url := setupRouterWebsocketServer(wsServer)
+		defer wsServer.StopServer()
+		client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+
+		placed := make(chan error, 1)
+		go func() {
+			_, err := client.PlaceLimitOrder(dvotcWS.LimitOrderParams{Asset: "BTC", CounterAsset: "USD", Qty: 1, Side: "Buy", LimitPrice: 19000})
+			placed <- err
+		}()
+		require.Eventually(t, func() bool { return len(wsServer.Requests("createorder")) == 1 }, time.Second, 5*time.Millisecond)
+
+		// the server does not list the order yet
+		report, err := client.KillSwitch()
+		require.NoError(t, err)
+		assert.Empty(t, report.Cancelled)
+
+		close(answer)
+		require.ErrorIs(t, <-placed, dvotcWS.ErrTradingHalted)
+		cancels := wsServer.Requests("cancelorder/")
+		require.Len(t, cancels, 1)
+		assert.Equal(t, "cancelorder/late-1", cancels[0].Topic)
+		assert.Empty(t, client.OpenOrders())
+	})
+}
+
+func TestKillSwitch_TrackedOrders(t *testing.T) {
+	server := dvotctest.NewServer(dvotctest.Config{})
+	defer server.Close()
+	server.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 20010, SellPrice: 19990, MaxQuantity: 10})
+	client := server.Client()
+	require.NoError(t, client.TrackOrderUpdates())
+	defer client.StopTrackingOrderUpdates()
+
+	order, err := client.PlaceLimitOrder(dvotcWS.LimitOrderParams{Asset: "BTC", CounterAsset: "USD", Qty: 1, Side: "Buy", LimitPrice: 19000})
+	require.NoError(t, err)
+
+	// the tracker knows the open orders, the trade history is not listed
+	report, err := client.KillSwitch()
+	require.NoError(t, err)
+	assert.Equal(t, []string{order.ID}, report.Cancelled)
+	assert.Empty(t, server.Requests("tradestatus"))
+}
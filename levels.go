@@ -2,10 +2,14 @@ package dvotcWS
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/avast/retry-go/v4"
 	"github.com/fasthttp/websocket"
 )
 
@@ -24,6 +28,29 @@ type Level struct {
 
 type SubscribeLevelData = Subscription[*LevelData]
 
+// PriceFor returns the price of the first level deep enough to fill qty on side,
+// ok is false when no level has enough quantity
+func (l *LevelData) PriceFor(side string, qty float64) (price float64, ok bool) {
+	for _, level := range l.Levels {
+		if level.MaxQuantity < qty {
+			continue
+		}
+		if strings.EqualFold(side, "sell") {
+			return level.SellPrice, true
+		}
+		return level.BuyPrice, true
+	}
+	return 0, false
+}
+
+// Mid returns the mid price of the top level
+func (l *LevelData) Mid() (float64, bool) {
+	if len(l.Levels) == 0 {
+		return 0, false
+	}
+	return (l.Levels[0].BuyPrice + l.Levels[0].SellPrice) / 2, true
+}
+
 func (dvotc *DVOTCClient) SubscribeLevels(symbol string) (*SubscribeLevelData, error) {
 	sub := &SubscribeLevelData{
 		Data:  make(chan *LevelData, 5),
@@ -58,27 +85,41 @@ func (dvotc *DVOTCClient) SubscribeLevels(symbol string) (*SubscribeLevelData, e
 }
 
 func (dvotc *DVOTCClient) readLevelMessageLoop(conn *websocket.Conn) {
-	var err error
 	for {
 		resp := Payload{}
-		if err := conn.ReadJSON(&resp); err != nil {
-			if !websocket.IsUnexpectedCloseError(err, websocket.CloseAbnormalClosure) {
+		if err := readPayload(conn, &resp); err != nil {
+			if errors.Is(err, ErrMalformedMessage) {
+				log.Println(err)
+				continue
+			}
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				// the connection was closed by the client
+				return
+			}
+			if !websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) ||
+				!levelSubscriptionsLive(dvotc.levelChanStore, &dvotc.chanMutex) {
 				// server closed connection
 				log.Default().Print("server closed connection")
+				dvotc.dropLevelConn(conn)
+				return
 			}
-			return
+			if conn = dvotc.reconnectLevels(conn); conn == nil {
+				return
+			}
+			continue
 		}
 		switch resp.Type {
 		case MessageTypeError:
-			return
+			// the subscription was refused, its subscribers are told by closing their channels
+			log.Printf("levels %s: %s", resp.Topic, string(resp.Data))
+			closeLevelChannels(dvotc.levelChanStore, &dvotc.chanMutex, fmt.Sprintf("%s:%s", resp.Topic, resp.Event))
+			continue
 		case MessageTypeInfo:
 			if resp.Event == "reconnect" {
-				conn, err = dvotc.getConn()
-				if err != nil {
-					log.Println(err)
+				if conn = dvotc.reconnectLevels(conn); conn == nil {
 					return
 				}
-				reSubscribeToTopics(conn, dvotc.levelChanStore, &dvotc.chanMutex)
 			}
 			continue
 		}
@@ -92,6 +133,87 @@ func (dvotc *DVOTCClient) readLevelMessageLoop(conn *websocket.Conn) {
 	}
 }
 
+// dropLevelConn forgets the levels connection closed by the server, later subscriptions
+// open a new one, and closes the level channels still subscribed to it
+func (dvotc *DVOTCClient) dropLevelConn(closed *websocket.Conn) {
+	dvotc.mu.Lock()
+	if dvotc.wsConnStore[connectionLevel] == closed {
+		delete(dvotc.wsConnStore, connectionLevel)
+	}
+	dvotc.mu.Unlock()
+	closed.Close()
+	closeLevelChannels(dvotc.levelChanStore, &dvotc.chanMutex, "")
+}
+
+// reconnectLevels replaces the levels connection dead, or asked to be replaced, and
+// subscribes again to the symbols with open subscriptions. When no connection can be
+// made every level channel is closed, nil is returned when the loop reading dead must stop.
+func (dvotc *DVOTCClient) reconnectLevels(dead *websocket.Conn) *websocket.Conn {
+	dvotc.mu.Lock()
+	if dvotc.wsConnStore[connectionLevel] == dead {
+		delete(dvotc.wsConnStore, connectionLevel)
+	}
+	dvotc.mu.Unlock()
+	dead.Close()
+
+	var conn *websocket.Conn
+	err := retry.Do(func() (err error) {
+		conn, err = dvotc.getConn()
+		return err
+	},
+		retry.Attempts(uint(dvotc.connectAttempts.Load())),
+		retry.Delay(time.Duration(dvotc.connectDelay.Load())))
+	if err != nil {
+		log.Println(err)
+		closeLevelChannels(dvotc.levelChanStore, &dvotc.chanMutex, "")
+		return nil
+	}
+
+	dvotc.mu.Lock()
+	if existing, ok := dvotc.wsConnStore[connectionLevel]; ok {
+		// a subscription opened its own connection meanwhile, it takes over with its own loop
+		dvotc.mu.Unlock()
+		conn.Close()
+		reSubscribeToTopics(existing, dvotc.levelChanStore, &dvotc.chanMutex)
+		return nil
+	}
+	// later subscriptions must go to the new connection
+	dvotc.wsConnStore[connectionLevel] = conn
+	dvotc.mu.Unlock()
+	reSubscribeToTopics(conn, dvotc.levelChanStore, &dvotc.chanMutex)
+	return conn
+}
+
+// closeLevelChannels closes the channels subscribed to key, or every channel when key
+// is empty, their subscriptions then see Data closed
+func closeLevelChannels(levelChanStore map[string][]chan *LevelData, mutex *sync.RWMutex, key string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for k, channels := range levelChanStore {
+		if key != "" && k != key {
+			continue
+		}
+		for i, channel := range channels {
+			if channel != nil {
+				close(channel)
+				channels[i] = nil
+			}
+		}
+	}
+}
+
+// levelSubscriptionsLive reports whether any level channel is still subscribed
+func levelSubscriptionsLive(levelChanStore map[string][]chan *LevelData, mutex *sync.RWMutex) bool {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	for _, channels := range levelChanStore {
+		if !channelsEmpty(channels) {
+			return true
+		}
+	}
+	return false
+}
+
 func channelsEmpty(channels []chan *LevelData) bool {
 	if len(channels) == 0 {
 		return true
@@ -142,3 +142,30 @@ func TestListLevels_ReuseConnection(t *testing.T) {
 	err = sub2.StopConsuming()
 	require.NoError(t, err)
 }
+
+func TestLevelDataPrices(t *testing.T) {
+	data := &dvotcWS.LevelData{
+		Levels: []dvotcWS.Level{
+			{BuyPrice: 101, SellPrice: 99, MaxQuantity: 1},
+			{BuyPrice: 102, SellPrice: 98, MaxQuantity: 5},
+		},
+	}
+
+	price, ok := data.PriceFor("Buy", 0.5)
+	require.True(t, ok)
+	require.Equal(t, 101.0, price)
+
+	price, ok = data.PriceFor("Sell", 3)
+	require.True(t, ok)
+	require.Equal(t, 98.0, price)
+
+	_, ok = data.PriceFor("Buy", 10)
+	require.False(t, ok)
+
+	mid, ok := data.Mid()
+	require.True(t, ok)
+	require.Equal(t, 100.0, mid)
+
+	_, ok = (&dvotcWS.LevelData{}).Mid()
+	require.False(t, ok)
+}
@@ -2,7 +2,7 @@ package dvotcWS
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"time"
 
@@ -65,7 +65,7 @@ type OrderNotification struct {
 	Asset        string           `json:"asset"`
 	CounterAsset string           `json:"counterAsset"`
 	ID           string           `json:"_id"`
-	Quantity     int64            `json:"quantity"`
+	Quantity     float64          `json:"quantity"`
 	Price        float64          `json:"price"`
 	LimitPrice   json.Number      `json:"limitPrice"`
 	Side         string           `json:"side"`
@@ -208,16 +208,17 @@ func SubscribeNotifications[
 				return
 			default:
 				resp := Payload{}
-				if err := sub.conn.ReadJSON(&resp); err != nil {
+				if err := readPayload(sub.conn, &resp); err != nil {
+					if errors.Is(err, ErrMalformedMessage) {
+						log.Println(err)
+						continue
+					}
 					if !websocket.IsUnexpectedCloseError(err, websocket.CloseAbnormalClosure) {
 						// server closed connection
 						log.Default().Print("server closed connection")
 					}
 					return
 				}
-				if resp.Topic != "ping-pong" {
-					fmt.Println(resp.Event, resp.Topic, resp.Type, string(resp.Data))
-				}
 				switch resp.Type {
 				case MessageTypeError:
 					return
@@ -227,7 +228,7 @@ func SubscribeNotifications[
 						newConn, err := dvotc.retryConnWithPayload(payload)
 						if err != nil {
 							// can't do much after all retries fail
-							fmt.Println(err)
+							log.Println(err)
 							return
 						}
 						sub.conn = newConn
@@ -239,7 +240,7 @@ func SubscribeNotifications[
 
 				var payload K
 				if err := json.Unmarshal(resp.Data, &payload); err != nil {
-					fmt.Println(err)
+					log.Println(err)
 					return
 				}
 				sub.Data <- payload
@@ -266,7 +267,7 @@ func SubscribeNotifications[
 					Topic: "ping-pong",
 				}
 				if err := sub.conn.WriteJSON(payload); err != nil {
-					fmt.Println(err)
+					log.Println(err)
 					return
 				}
 			}
@@ -0,0 +1,279 @@
+package dvotcWS
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+)
+
+const (
+	OrderStatusOpen      = "Open"
+	OrderStatusComplete  = "Complete"
+	OrderStatusCancelled = "Cancelled"
+)
+
+func isOpenOrderStatus(status string) bool {
+	switch strings.ToLower(status) {
+	case "open", "pending", "new":
+		return true
+	}
+	return false
+}
+
+func isFilledOrderStatus(order OrderStatus) bool {
+	switch strings.ToLower(order.Status) {
+	case "complete", "completed", "filled":
+		return true
+	}
+	return order.FilledAt != nil
+}
+
+func isCancelledOrderStatus(order OrderStatus) bool {
+	switch strings.ToLower(order.Status) {
+	case "cancelled", "canceled":
+		return true
+	}
+	return order.CancelledAt != nil
+}
+
+func (o OrderStatus) IsOpen() bool {
+	return isOpenOrderStatus(o.Status)
+}
+
+func (o OrderStatus) IsFilled() bool {
+	return isFilledOrderStatus(o)
+}
+
+func (o OrderStatus) IsCancelled() bool {
+	return isCancelledOrderStatus(o)
+}
+
+// orderTracker keeps the last known state of every order seen through
+// order placement responses and order-update subscriptions
+type orderTracker struct {
+	mu       sync.RWMutex
+	orders   map[string]OrderStatus
+	watchers map[string][]chan OrderStatus
+	// resolved is called with the updates of orders no longer open
+	resolved func(OrderStatus)
+}
+
+func newOrderTracker() *orderTracker {
+	return &orderTracker{
+		orders:   make(map[string]OrderStatus),
+		watchers: make(map[string][]chan OrderStatus),
+	}
+}
+
+func (t *orderTracker) update(status OrderStatus) {
+	if status.ID == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if known, ok := t.orders[status.ID]; ok && isOpenOrderStatus(status.Status) &&
+		(isFilledOrderStatus(known) || isCancelledOrderStatus(known)) {
+		// an order does not open again, this is a response overtaken by an update
+		return
+	}
+	t.orders[status.ID] = status
+	if t.resolved != nil && !isOpenOrderStatus(status.Status) {
+		t.resolved(status)
+	}
+	for _, w := range t.watchers[status.ID] {
+		select {
+		case w <- status:
+		default:
+			// watcher buffer full skipping
+		}
+	}
+}
+
+// watch returns a channel receiving every update for orderID until the returned func is called
+func (t *orderTracker) watch(orderID string) (<-chan OrderStatus, func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w, stop := t.addWatcher(orderID)
+	return w, stop
+}
+
+// watchFrom is watch with the tracked state of orderID, if any, received first
+func (t *orderTracker) watchFrom(orderID string) (<-chan OrderStatus, func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w, stop := t.addWatcher(orderID)
+	if order, ok := t.orders[orderID]; ok {
+		w <- order
+	}
+	return w, stop
+}
+
+func (t *orderTracker) addWatcher(orderID string) (chan OrderStatus, func()) {
+	w := make(chan OrderStatus, 10)
+	t.watchers[orderID] = append(t.watchers[orderID], w)
+
+	return w, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		watchers := t.watchers[orderID]
+		for i, c := range watchers {
+			if c == w {
+				watchers = append(watchers[:i], watchers[i+1:]...)
+				break
+			}
+		}
+		if len(watchers) == 0 {
+			delete(t.watchers, orderID)
+			return
+		}
+		t.watchers[orderID] = watchers
+	}
+}
+
+func (t *orderTracker) setStatus(orderID, status string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	order, ok := t.orders[orderID]
+	if !ok {
+		return
+	}
+	order.Status = status
+	t.orders[orderID] = order
+	if t.resolved != nil && !isOpenOrderStatus(status) {
+		t.resolved(order)
+	}
+}
+
+func (t *orderTracker) get(orderID string) (OrderStatus, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	order, ok := t.orders[orderID]
+	return order, ok
+}
+
+func (t *orderTracker) open() []OrderStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	orders := make([]OrderStatus, 0)
+	for _, o := range t.orders {
+		if isOpenOrderStatus(o.Status) {
+			orders = append(orders, o)
+		}
+	}
+	return orders
+}
+
+// TrackOrderUpdates keeps an order-updates subscription open for all orders
+// so OpenOrders, KillSwitch and ReplaceLimitOrder see changes made elsewhere.
+// The subscription is opened again when its connection drops, if that fails
+// tracking stops until TrackOrderUpdates is called again.
+func (dvotc *DVOTCClient) TrackOrderUpdates() error {
+	dvotc.orderUpdatesMu.Lock()
+	defer dvotc.orderUpdatesMu.Unlock()
+	if dvotc.orderUpdates != nil {
+		return nil
+	}
+
+	sub, err := dvotc.SubscribeOrderChanges("#")
+	if err != nil {
+		return err
+	}
+	dvotc.orderUpdates = sub
+
+	go dvotc.keepTrackingOrderUpdates(sub)
+	return nil
+}
+
+// keepTrackingOrderUpdates drains sub and subscribes again when it ends with its
+// connection, until StopTrackingOrderUpdates is called. The orders open before are
+// looked up again as their updates may have been missed in between.
+func (dvotc *DVOTCClient) keepTrackingOrderUpdates(sub *Subscription[OrderStatus]) {
+	for {
+		// the subscription updates the tracker itself, just keep it drained
+		for range sub.Data {
+		}
+
+		var next *Subscription[OrderStatus]
+		err := retry.Do(func() (err error) {
+			next, err = dvotc.SubscribeOrderChanges("#")
+			return err
+		},
+			retry.Attempts(uint(dvotc.connectAttempts.Load())),
+			retry.Delay(time.Duration(dvotc.connectDelay.Load())))
+
+		dvotc.orderUpdatesMu.Lock()
+		if dvotc.orderUpdates != sub {
+			// stopped meanwhile
+			dvotc.orderUpdatesMu.Unlock()
+			if next != nil {
+				next.StopConsuming()
+			}
+			return
+		}
+		if err != nil {
+			// the next TrackOrderUpdates call, e.g. from ReplaceLimitOrder, tries again
+			dvotc.orderUpdates = nil
+			dvotc.orderUpdatesMu.Unlock()
+			log.Println("order updates lost:", err)
+			return
+		}
+		dvotc.orderUpdates = next
+		dvotc.orderUpdatesMu.Unlock()
+
+		sub = next
+		dvotc.refreshOpenOrders()
+	}
+}
+
+// refreshOpenOrders updates the orders tracked as open with their current status
+func (dvotc *DVOTCClient) refreshOpenOrders() {
+	open := dvotc.orders.open()
+	if len(open) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(open))
+	for _, o := range open {
+		ids = append(ids, o.ID)
+	}
+	trades, err := dvotc.ListTrades(ids, nil, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for _, t := range trades {
+		dvotc.orders.update(tradeOrderStatus(t))
+	}
+}
+
+func (dvotc *DVOTCClient) trackingOrderUpdates() bool {
+	dvotc.orderUpdatesMu.Lock()
+	defer dvotc.orderUpdatesMu.Unlock()
+	return dvotc.orderUpdates != nil
+}
+
+func (dvotc *DVOTCClient) StopTrackingOrderUpdates() error {
+	dvotc.orderUpdatesMu.Lock()
+	sub := dvotc.orderUpdates
+	dvotc.orderUpdates = nil
+	dvotc.orderUpdatesMu.Unlock()
+	if sub == nil {
+		return nil
+	}
+	return sub.StopConsuming()
+}
+
+// WatchOrder returns the updates of orderID seen by the client until stop is called,
+// updates made elsewhere need TrackOrderUpdates. The state the order is tracked in
+// is received first, so an update seen before the call is not missed. Updates are
+// skipped while the channel is full.
+func (dvotc *DVOTCClient) WatchOrder(orderID string) (updates <-chan OrderStatus, stop func()) {
+	return dvotc.orders.watchFrom(orderID)
+}
+
+// OpenOrders returns the orders known to be open from placement responses and order-update subscriptions
+func (dvotc *DVOTCClient) OpenOrders() []OrderStatus {
+	return dvotc.orders.open()
+}
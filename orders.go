@@ -1,6 +1,7 @@
 package dvotcWS
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -24,17 +25,19 @@ type Order struct {
 }
 
 type OrderStatus struct {
-	ID           string  `json:"_id"`
-	ClientTag    string  `json:"clientTag"`
-	LimitPrice   string  `json:"limitPrice"`
-	Price        float64 `json:"price"`
-	Quantity     float64 `json:"quantity"`
-	Side         string  `json:"side"`
-	OrderType    string  `json:"orderType,omitempty"`
-	Asset        string  `json:"asset"`
-	CounterAsset string  `json:"counterAsset"`
-	Status       string  `json:"status"`
-	User         User    `json:"user"`
+	ID         string  `json:"_id"`
+	ClientTag  string  `json:"clientTag"`
+	LimitPrice string  `json:"limitPrice"`
+	Price      float64 `json:"price"`
+	Quantity   float64 `json:"quantity"`
+	// FilledQuantity is the quantity filled so far, set by updates of partial fills
+	FilledQuantity float64 `json:"filledQuantity,omitempty"`
+	Side           string  `json:"side"`
+	OrderType      string  `json:"orderType,omitempty"`
+	Asset          string  `json:"asset"`
+	CounterAsset   string  `json:"counterAsset"`
+	Status         string  `json:"status"`
+	User           User    `json:"user"`
 
 	CreatedAt   time.Time  `json:"createdAt"`
 	FilledAt    *time.Time `json:"filledAt"`
@@ -58,17 +61,33 @@ type LimitOrderParams struct {
 	Qty          float64 `json:"qty"`
 	Side         string  `json:"side"`
 	ClientTag    string  `json:"clientTag"`
+
+	// GoodTill cancels the order client-side at this time, zero means good till cancelled
+	GoodTill time.Time `json:"-" faker:"-"`
+	// GoodFor cancels the order client-side this long after placing it, ignored when GoodTill is set
+	GoodFor time.Duration `json:"-" faker:"-"`
 }
 
 type OrderResponseData = Subscription[*OrderStatus]
 
 func (dvotc *DVOTCClient) PlaceMarketOrder(marketOrder MarketOrderParams) (*OrderStatus, error) {
-	conn, err := dvotc.getConnOrReuse(connectionOrders)
+	return dvotc.placeMarketOrder(context.Background(), marketOrder)
+}
+
+// PlaceMarketOrderContext is PlaceMarketOrder giving up waiting for the response once
+// ctx is done, an order already sent may still be filled by the server
+func (dvotc *DVOTCClient) PlaceMarketOrderContext(ctx context.Context, marketOrder MarketOrderParams) (*OrderStatus, error) {
+	return dvotc.placeMarketOrder(ctx, marketOrder)
+}
+
+func (dvotc *DVOTCClient) placeMarketOrder(ctx context.Context, marketOrder MarketOrderParams) (res *OrderStatus, err error) {
+	reserved, err := dvotc.preTradeChecks(marketOrder.Asset, marketOrder.CounterAsset, marketOrder.Side, marketOrder.Qty, marketOrder.Price)
 	if err != nil {
 		return nil, err
 	}
+	defer func() { reserved.placed(res, err) }()
 
-	order := &Order{
+	order := Order{
 		QuoteID:      marketOrder.QuoteID,
 		OrderType:    "market",
 		Asset:        marketOrder.Asset,
@@ -78,51 +97,29 @@ func (dvotc *DVOTCClient) PlaceMarketOrder(marketOrder MarketOrderParams) (*Orde
 		Side:         marketOrder.Side,
 		ClientTag:    marketOrder.ClientTag,
 	}
+	return dvotc.createOrder(ctx, order)
+}
 
-	data, err := json.Marshal(order)
-	if err != nil {
-		return nil, err
-	}
-
-	payload := Payload{
-		Type:  MessageTypeRequestResponse,
-		Event: dvotc.getRequestID(),
-		Topic: "createorder",
-		Data:  data,
-	}
-
-	sub := &OrderResponseData{
-		Data:  make(chan *OrderStatus),
-		Error: make(chan error),
-		topic: payload.Topic,
-		event: payload.Event,
-		conn:  conn,
-	}
-
-	storeTradeAndErrorChann(dvotc.orderChanStore, &dvotc.chanMutex, sub.event, sub.topic, sub.Data, sub.Error)
-	defer func() {
-		close(sub.Data)
-		close(sub.Error)
-		cleanupTradeAndErrorChan(dvotc.orderChanStore, &dvotc.chanMutex, sub.event, sub.topic)
-	}()
-
-	if err := dvotc.writeJSONMessage(conn, payload); err != nil {
-		return nil, err
-	}
+func (dvotc *DVOTCClient) PlaceLimitOrder(limitOrder LimitOrderParams) (*OrderStatus, error) {
+	return dvotc.placeLimitOrder(context.Background(), limitOrder)
+}
 
-	select {
-	case res := <-sub.Data:
-		return res, nil
-	case err := <-sub.Error:
+func (dvotc *DVOTCClient) placeLimitOrder(ctx context.Context, limitOrder LimitOrderParams) (res *OrderStatus, err error) {
+	reserved, err := dvotc.preTradeChecks(limitOrder.Asset, limitOrder.CounterAsset, limitOrder.Side, limitOrder.Qty, limitOrder.LimitPrice)
+	if err != nil {
 		return nil, err
 	}
-}
-
-func (dvotc *DVOTCClient) PlaceLimitOrder(limitOrder LimitOrderParams) (*OrderStatus, error) {
-	conn, err := dvotc.getConnOrReuse(connectionOrders)
+	defer func() { reserved.placed(res, err) }()
+	expireAt, err := limitOrderDeadline(limitOrder)
 	if err != nil {
 		return nil, err
 	}
+	if !expireAt.IsZero() {
+		// fills are only seen through order updates, a fill can come ahead of the response
+		if err := dvotc.TrackOrderUpdates(); err != nil {
+			log.Println(err)
+		}
+	}
 
 	sellPriceStr := fmt.Sprintf("%g", limitOrder.LimitPrice)
 	order := Order{
@@ -134,22 +131,71 @@ func (dvotc *DVOTCClient) PlaceLimitOrder(limitOrder LimitOrderParams) (*OrderSt
 		Side:         limitOrder.Side,
 		ClientTag:    limitOrder.ClientTag,
 	}
+	res, err = dvotc.createOrder(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	if !expireAt.IsZero() && res != nil && isOpenOrderStatus(res.Status) {
+		dvotc.scheduleExpiry(res.ID, expireAt)
+	}
+	return res, nil
+}
+
+// preTradeChecks checks an order before it is sent, the quantity reserved against the
+// risk limits must be released with placed once the order is answered
+func (dvotc *DVOTCClient) preTradeChecks(asset, counterAsset, side string, qty, price float64) (*reservation, error) {
+	if dvotc.halted.Load() {
+		return nil, ErrTradingHalted
+	}
+	return dvotc.checkRisk(asset, counterAsset, side, qty, price)
+}
 
+func (dvotc *DVOTCClient) createOrder(ctx context.Context, order Order) (*OrderStatus, error) {
 	data, err := json.Marshal(order)
 	if err != nil {
 		return nil, err
 	}
 
+	res, err := dvotc.orderRequest(ctx, "createorder", data)
+	if err != nil {
+		return nil, err
+	}
+	if res != nil {
+		if halted, cancel := dvotc.placedWhileHalted(*res); halted {
+			// the kill switch went off while the order was in flight
+			if cancel {
+				cancelCtx, stop := dvotc.withRequestTimeout(context.Background())
+				defer stop()
+				if err := dvotc.cancelOrder(cancelCtx, res.ID); err != nil {
+					return res, fmt.Errorf("%w: cancelling order %s placed meanwhile: %s", ErrTradingHalted, res.ID, err)
+				}
+			}
+			return nil, fmt.Errorf("%w: order %s placed meanwhile is cancelled", ErrTradingHalted, res.ID)
+		}
+	}
+	dvotc.refreshRiskAfterOrder(res)
+	return res, nil
+}
+
+// orderRequest sends a request-response message over the shared orders connection,
+// responses are matched back to the request by topic and event
+func (dvotc *DVOTCClient) orderRequest(ctx context.Context, topic string, data json.RawMessage) (*OrderStatus, error) {
+	conn, err := dvotc.getConnOrReuse(connectionOrders)
+	if err != nil {
+		return nil, err
+	}
+
 	payload := Payload{
 		Type:  MessageTypeRequestResponse,
 		Event: dvotc.getRequestID(),
-		Topic: "createorder",
+		Topic: topic,
 		Data:  data,
 	}
 
+	// buffered so a late response never blocks the read loop once we gave up waiting
 	sub := &OrderResponseData{
-		Data:  make(chan *OrderStatus),
-		Error: make(chan error),
+		Data:  make(chan *OrderStatus, 1),
+		Error: make(chan error, 1),
 		topic: payload.Topic,
 		event: payload.Event,
 		conn:  conn,
@@ -157,20 +203,31 @@ func (dvotc *DVOTCClient) PlaceLimitOrder(limitOrder LimitOrderParams) (*OrderSt
 
 	storeTradeAndErrorChann(dvotc.orderChanStore, &dvotc.chanMutex, sub.event, sub.topic, sub.Data, sub.Error)
 	defer func() {
+		cleanupTradeAndErrorChan(dvotc.orderChanStore, &dvotc.chanMutex, sub.event, sub.topic)
 		close(sub.Data)
 		close(sub.Error)
-		cleanupTradeAndErrorChan(dvotc.orderChanStore, &dvotc.chanMutex, sub.event, sub.topic)
 	}()
 
 	if err := dvotc.writeJSONMessage(conn, payload); err != nil {
 		return nil, err
 	}
 
+	var timeout <-chan time.Time
+	if d := time.Duration(dvotc.requestTimeout.Load()); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
 	select {
 	case res := <-sub.Data:
 		return res, nil
 	case err := <-sub.Error:
 		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timeout:
+		return nil, fmt.Errorf("%w: %s %s", ErrRequestTimeout, payload.Topic, payload.Event)
 	}
 }
 
@@ -200,6 +257,7 @@ func (dvotc *DVOTCClient) CancelOrder(orderID string) error {
 		return errors.New(string(resp.Data))
 	}
 
+	dvotc.orders.setStatus(orderID, OrderStatusCancelled)
 	return nil
 }
 
@@ -236,12 +294,17 @@ func (dvotc *DVOTCClient) SubscribeOrderChanges(status string) (*Subscription[Or
 				return
 			default:
 				resp := Payload{}
-				if err := sub.conn.ReadJSON(&resp); err != nil {
+				if err := readPayload(sub.conn, &resp); err != nil {
+					if errors.Is(err, ErrMalformedMessage) {
+						log.Println(err)
+						continue
+					}
 					if !websocket.IsUnexpectedCloseError(err, websocket.CloseAbnormalClosure) {
 						// server closed connection
 						log.Default().Print("server closed connection")
 					}
-					continue
+					// reading again from a failed connection panics
+					return
 				}
 				switch resp.Type {
 				case MessageTypeError:
@@ -251,7 +314,7 @@ func (dvotc *DVOTCClient) SubscribeOrderChanges(status string) (*Subscription[Or
 						newConn, err := dvotc.retryConnWithPayload(payload)
 						if err != nil {
 							// can't do much after all retries fail
-							fmt.Println(err)
+							log.Println(err)
 							return
 						}
 						sub.conn = newConn
@@ -263,6 +326,7 @@ func (dvotc *DVOTCClient) SubscribeOrderChanges(status string) (*Subscription[Or
 				if err := json.Unmarshal(resp.Data, &orderStatus); err != nil {
 					return
 				}
+				dvotc.orders.update(orderStatus)
 				sub.Data <- orderStatus
 			}
 		}
@@ -274,7 +338,12 @@ func (dvotc *DVOTCClient) SubscribeOrderChanges(status string) (*Subscription[Or
 func (dvotc *DVOTCClient) readOrderMessageLoop(conn *websocket.Conn, cleanupFunc func()) {
 	for {
 		resp := Payload{}
-		if err := conn.ReadJSON(&resp); err != nil {
+		if err := readPayload(conn, &resp); err != nil {
+			if errors.Is(err, ErrMalformedMessage) {
+				// the request it answered waits until its timeout
+				log.Println(err)
+				continue
+			}
 			if !websocket.IsUnexpectedCloseError(err, websocket.CloseAbnormalClosure) {
 				// server closed connection
 				log.Default().Print("server closed connection")
@@ -282,6 +351,8 @@ func (dvotc *DVOTCClient) readOrderMessageLoop(conn *websocket.Conn, cleanupFunc
 			if cleanupFunc != nil {
 				cleanupFunc()
 			}
+			// nothing will answer requests still waiting on this connection
+			failPendingTrades(dvotc.orderChanStore, &dvotc.chanMutex, err)
 			return
 		}
 
@@ -296,9 +367,16 @@ func (dvotc *DVOTCClient) readOrderMessageLoop(conn *websocket.Conn, cleanupFunc
 			continue
 		}
 
+		// cancellations are acknowledged without data
+		if len(resp.Data) == 0 {
+			dispatchTradeData(dvotc.orderChanStore, &dvotc.chanMutex, resp.Event, resp.Topic, nil, nil)
+			continue
+		}
+
 		orderStatus := OrderStatus{}
 		if err := json.Unmarshal(resp.Data, &orderStatus); err != nil {
-			return
+			dispatchTradeData(dvotc.orderChanStore, &dvotc.chanMutex, resp.Event, resp.Topic, nil, err)
+			continue
 		}
 		dispatchTradeData(dvotc.orderChanStore, &dvotc.chanMutex, resp.Event, resp.Topic, &orderStatus, nil)
 	}
@@ -310,7 +388,9 @@ func dispatchTradeData(safeChanStore map[string]tradeData, mutex *sync.RWMutex,
 	key := fmt.Sprintf("%s:%s", topic, event)
 	channels, ok := safeChanStore[key]
 	if !ok {
-		log.Panicf("key already exist for trade %s", key)
+		// requester gave up waiting, e.g. its context was cancelled
+		log.Printf("no pending request for %s, dropping response", key)
+		return
 	}
 	if err != nil {
 		channels.err <- err
@@ -319,6 +399,21 @@ func dispatchTradeData(safeChanStore map[string]tradeData, mutex *sync.RWMutex,
 	}
 }
 
+func failPendingTrades(safeChanStore map[string]tradeData, mutex *sync.RWMutex, err error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, channels := range safeChanStore {
+		if len(channels.data) > 0 {
+			// already answered, waiting to be read
+			continue
+		}
+		select {
+		case channels.err <- err:
+		default:
+		}
+	}
+}
+
 func storeTradeAndErrorChann(safeChanStore map[string]tradeData, mutex *sync.RWMutex, event, topic string, channel chan *OrderStatus, errChan chan error) {
 	mutex.Lock()
 	defer mutex.Unlock()
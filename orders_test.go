@@ -421,3 +421,30 @@ func TestSubscribeOrderChanges_Reconnect(t *testing.T) {
 	err = sub.StopConsuming()
 	require.ErrorIs(t, err, dvotcWS.ErrSubscriptionAlreadyClosed)
 }
+
+func TestWatchOrder(t *testing.T) {
+	wsServer := setupExpiryServer(t)
+	url := setupRouterWebsocketServer(wsServer)
+	defer wsServer.StopServer()
+
+	client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+	require.NoError(t, client.TrackOrderUpdates())
+	defer client.StopTrackingOrderUpdates()
+
+	order, err := client.PlaceLimitOrder(dvotcWS.LimitOrderParams{Asset: "BTC", CounterAsset: "USD", LimitPrice: 20000, Qty: 1, Side: "Buy", ClientTag: "watched"})
+	require.NoError(t, err)
+
+	updates, stop := client.WatchOrder(order.ID)
+	defer stop()
+	// the tracked state comes first
+	require.Equal(t, dvotcWS.OrderStatusOpen, (<-updates).Status)
+
+	data, _ := json.Marshal(dvotcWS.OrderStatus{ID: order.ID, Quantity: 1, FilledQuantity: 0.4, Status: dvotcWS.OrderStatusOpen})
+	wsServer.Publish(dvotcWS.Payload{Type: dvotcWS.MessageTypeSubscribe, Event: "order-updates", Topic: "order/#", Data: data})
+	select {
+	case update := <-updates:
+		require.Equal(t, 0.4, update.FilledQuantity)
+	case <-time.After(time.Second):
+		t.Fatal("partial fill not received")
+	}
+}
@@ -0,0 +1,427 @@
+// Package portfolio tracks positions, average cost and PnL of a DVOTC account
+package portfolio
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+)
+
+const (
+	// quantities below this are considered flat
+	qtyEpsilon = 1e-9
+	// appliedWindow is the number of order IDs remembered to skip fills seen twice
+	appliedWindow = 10000
+)
+
+// BalanceSource is satisfied by *dvotcWS.DVOTCClient
+type BalanceSource interface {
+	ListLimitsBalances() (*dvotcWS.AssetBalance, error)
+}
+
+type Config struct {
+	// QuoteAsset is the asset PnL and costs are reported in, defaults to USD
+	QuoteAsset string
+}
+
+type Position struct {
+	Asset    string
+	Quantity float64
+	// AvgCost is the average entry price of the open quantity in the quote asset
+	AvgCost float64
+	// MarkPrice is the last level stream mid of the asset against the quote asset
+	MarkPrice     float64
+	Marked        bool
+	MarketValue   float64
+	RealizedPnL   float64
+	UnrealizedPnL float64
+}
+
+type Snapshot struct {
+	Time          time.Time
+	QuoteAsset    string
+	Positions     []Position
+	RealizedPnL   float64
+	UnrealizedPnL float64
+	TotalPnL      float64
+	// PendingFills counts fills waiting for the mark of their counter asset
+	PendingFills int
+}
+
+// fill is a fill waiting for the mark of its counter asset
+type fill struct {
+	orderID      string
+	asset        string
+	counterAsset string
+	side         string
+	qty          float64
+	price        float64
+}
+
+type position struct {
+	quantity float64
+	avgCost  float64
+	realized float64
+	// costed is false for seeded quantities until a mark gives them a cost
+	costed bool
+}
+
+// Portfolio keeps positions seeded from ListLimitsBalances up to date with fills
+// and marks them against the mid of the level stream
+type Portfolio struct {
+	source BalanceSource
+	quote  string
+
+	mu        sync.Mutex
+	positions map[string]*position
+	marks     map[string]float64
+	// applied keeps the last appliedWindow order IDs accounted, oldest first in
+	// appliedOrder, so the notification and the order status of the same fill
+	// are not counted twice
+	applied      map[string]struct{}
+	appliedOrder []string
+	// pending holds the fills waiting for a mark in arrival order, see applyFill
+	pending []fill
+}
+
+// New creates a portfolio seeded from source, source may be nil to start flat
+func New(source BalanceSource, cfg Config) (*Portfolio, error) {
+	if cfg.QuoteAsset == "" {
+		cfg.QuoteAsset = "USD"
+	}
+	p := &Portfolio{
+		source:    source,
+		quote:     cfg.QuoteAsset,
+		positions: make(map[string]*position),
+		marks:     make(map[string]float64),
+		applied:   make(map[string]struct{}),
+	}
+	if source == nil {
+		return p, nil
+	}
+	if err := p.Seed(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Seed replaces position quantities with the ones reported by ListLimitsBalances,
+// quantities that changed lose their cost and are costed again at the next mark
+func (p *Portfolio) Seed() error {
+	if p.source == nil {
+		return nil
+	}
+	balances, err := p.source.ListLimitsBalances()
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	seen := make(map[string]bool)
+	for _, asset := range balances.Assets {
+		seen[asset.Asset] = true
+		p.seedPosition(asset.Asset, asset.Position)
+	}
+	if !seen[p.quote] && strings.EqualFold(p.quote, "USD") {
+		p.seedPosition(p.quote, balances.UsdBalance)
+	}
+	return nil
+}
+
+func (p *Portfolio) seedPosition(asset string, qty float64) {
+	pos := p.position(asset)
+	if math.Abs(pos.quantity-qty) <= qtyEpsilon {
+		return
+	}
+	pos.quantity = qty
+	pos.avgCost = 0
+	pos.costed = false
+	if mark, ok := p.rate(asset); ok {
+		pos.avgCost = mark
+		pos.costed = true
+	}
+}
+
+// ApplyOrder accounts the fill of order, orders that are not filled are ignored
+func (p *Portfolio) ApplyOrder(order dvotcWS.OrderStatus) error {
+	if !order.IsFilled() {
+		return nil
+	}
+	return p.applyFill(order.ID, order.Asset, order.CounterAsset, order.Side, order.Quantity, order.Price)
+}
+
+// ApplyNotification accounts an ORDER_FILLED notification
+func (p *Portfolio) ApplyNotification(n dvotcWS.OrderNotification) error {
+	return p.applyFill(n.ID, n.Asset, n.CounterAsset, n.Side, n.Quantity, n.Price)
+}
+
+// applyFill accounts a fill in the quote asset. A fill whose counter asset has no
+// mark yet waits for it, as do later fills of the same assets to keep their order.
+func (p *Portfolio) applyFill(orderID, asset, counterAsset, side string, qty, price float64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.applied[orderID]; ok && orderID != "" {
+		return nil
+	}
+	if qty <= 0 || price <= 0 {
+		return fmt.Errorf("invalid fill of order %s: quantity %v at %v", orderID, qty, price)
+	}
+	p.remember(orderID)
+
+	f := fill{orderID: orderID, asset: asset, counterAsset: counterAsset, side: side, qty: qty, price: price}
+	if _, ok := p.rate(counterAsset); !ok || p.waiting(f) {
+		p.pending = append(p.pending, f)
+		return nil
+	}
+	p.apply(f)
+	return nil
+}
+
+// waiting reports whether a pending fill is on one of the assets of f, the quote
+// asset aside as fills move it the same in any order
+func (p *Portfolio) waiting(f fill) bool {
+	for _, pending := range p.pending {
+		for _, asset := range []string{pending.asset, pending.counterAsset} {
+			if asset != p.quote && (asset == f.asset || asset == f.counterAsset) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyPending applies the pending fills that can be valued now, in arrival order
+// for each asset, callers hold p.mu
+func (p *Portfolio) applyPending() {
+	blocked := make(map[string]bool)
+	rest := p.pending[:0]
+	for _, f := range p.pending {
+		_, marked := p.rate(f.counterAsset)
+		if !marked || blocked[f.asset] || blocked[f.counterAsset] {
+			for _, asset := range []string{f.asset, f.counterAsset} {
+				if asset != p.quote {
+					blocked[asset] = true
+				}
+			}
+			rest = append(rest, f)
+			continue
+		}
+		p.apply(f)
+	}
+	p.pending = rest
+}
+
+// apply accounts f, valued at the mark of its counter asset, callers hold p.mu
+func (p *Portfolio) apply(f fill) {
+	counterRate, _ := p.rate(f.counterAsset)
+	qty := f.qty
+	if strings.EqualFold(f.side, "sell") {
+		qty = -qty
+	}
+	p.trade(f.asset, qty, f.price*counterRate)
+	p.trade(f.counterAsset, -qty*f.price, counterRate)
+}
+
+// remember marks orderID as applied, forgetting the oldest ID past appliedWindow
+func (p *Portfolio) remember(orderID string) {
+	if orderID == "" {
+		return
+	}
+	p.applied[orderID] = struct{}{}
+	p.appliedOrder = append(p.appliedOrder, orderID)
+	if len(p.appliedOrder) > appliedWindow {
+		delete(p.applied, p.appliedOrder[0])
+		p.appliedOrder = p.appliedOrder[1:]
+	}
+}
+
+// trade moves the position of asset by qty at price in the quote asset
+func (p *Portfolio) trade(asset string, qty, price float64) {
+	pos := p.position(asset)
+	if asset == p.quote {
+		pos.quantity += qty
+		return
+	}
+	if !pos.costed {
+		// a seeded position without a mark is costed at the first fill
+		pos.avgCost = price
+		pos.costed = true
+	}
+
+	switch {
+	case math.Abs(pos.quantity) <= qtyEpsilon || (pos.quantity > 0) == (qty > 0):
+		total := math.Abs(pos.quantity) + math.Abs(qty)
+		pos.avgCost = (math.Abs(pos.quantity)*pos.avgCost + math.Abs(qty)*price) / total
+		pos.quantity += qty
+	default:
+		closed := math.Min(math.Abs(qty), math.Abs(pos.quantity))
+		direction := 1.0
+		if pos.quantity < 0 {
+			direction = -1
+		}
+		pos.realized += closed * (price - pos.avgCost) * direction
+		pos.quantity += qty
+		switch {
+		case math.Abs(pos.quantity) <= qtyEpsilon:
+			pos.quantity = 0
+			pos.avgCost = 0
+		case (pos.quantity > 0) != (direction > 0):
+			// the fill flipped the position, the rest is opened at price
+			pos.avgCost = price
+		}
+	}
+}
+
+func (p *Portfolio) position(asset string) *position {
+	pos, ok := p.positions[asset]
+	if !ok {
+		pos = &position{}
+		if asset == p.quote {
+			pos.avgCost = 1
+			pos.costed = true
+		}
+		p.positions[asset] = pos
+	}
+	return pos
+}
+
+// rate returns the value of one unit of asset in the quote asset, callers hold p.mu
+func (p *Portfolio) rate(asset string) (float64, bool) {
+	if asset == p.quote {
+		return 1, true
+	}
+	mark, ok := p.marks[asset]
+	return mark, ok
+}
+
+// Mark records the mid of data as the price of the base asset of symbol,
+// symbols not quoted in the quote asset are ignored
+func (p *Portfolio) Mark(symbol string, data *dvotcWS.LevelData) {
+	if data == nil {
+		return
+	}
+	mid, ok := data.Mid()
+	if !ok {
+		return
+	}
+	assets := strings.SplitN(symbol, "/", 2)
+	if len(assets) != 2 || assets[1] != p.quote {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.marks[assets[0]] = mid
+	if pos, ok := p.positions[assets[0]]; ok && !pos.costed {
+		pos.avgCost = mid
+		pos.costed = true
+	}
+	if len(p.pending) > 0 {
+		p.applyPending()
+	}
+}
+
+// WatchLevels marks symbol on every level update until levels is closed or ctx is done,
+// levels is usually the Data channel of SubscribeLevels
+func (p *Portfolio) WatchLevels(ctx context.Context, symbol string, levels <-chan *dvotcWS.LevelData) error {
+	for {
+		select {
+		case data, ok := <-levels:
+			if !ok {
+				return nil
+			}
+			p.Mark(symbol, data)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// WatchFills applies fills until both channels are closed or ctx is done, filled is
+// usually the Data channel of SubscribeOrderFilled and orders the one of
+// SubscribeOrderChanges, either may be nil
+func (p *Portfolio) WatchFills(ctx context.Context, filled <-chan dvotcWS.OrderNotification, orders <-chan dvotcWS.OrderStatus) error {
+	for filled != nil || orders != nil {
+		var err error
+		select {
+		case n, ok := <-filled:
+			if !ok {
+				filled = nil
+				continue
+			}
+			err = p.ApplyNotification(n)
+		case order, ok := <-orders:
+			if !ok {
+				orders = nil
+				continue
+			}
+			err = p.ApplyOrder(order)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Printf("portfolio: %s", err)
+		}
+	}
+	return nil
+}
+
+// Position returns the position of asset marked at the last mid
+func (p *Portfolio) Position(asset string) (Position, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pos, ok := p.positions[asset]
+	if !ok {
+		return Position{}, false
+	}
+	return p.snapshotPosition(asset, pos), true
+}
+
+// Snapshot returns every position and the PnL totals in the quote asset
+func (p *Portfolio) Snapshot() Snapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	snapshot := Snapshot{
+		Time:         time.Now().UTC(),
+		QuoteAsset:   p.quote,
+		Positions:    make([]Position, 0, len(p.positions)),
+		PendingFills: len(p.pending),
+	}
+	for asset, pos := range p.positions {
+		position := p.snapshotPosition(asset, pos)
+		snapshot.Positions = append(snapshot.Positions, position)
+		snapshot.RealizedPnL += position.RealizedPnL
+		snapshot.UnrealizedPnL += position.UnrealizedPnL
+	}
+	sort.Slice(snapshot.Positions, func(i, j int) bool {
+		return snapshot.Positions[i].Asset < snapshot.Positions[j].Asset
+	})
+	snapshot.TotalPnL = snapshot.RealizedPnL + snapshot.UnrealizedPnL
+	return snapshot
+}
+
+func (p *Portfolio) snapshotPosition(asset string, pos *position) Position {
+	position := Position{
+		Asset:       asset,
+		Quantity:    pos.quantity,
+		AvgCost:     pos.avgCost,
+		RealizedPnL: pos.realized,
+	}
+	if mark, ok := p.rate(asset); ok {
+		position.MarkPrice = mark
+		position.Marked = true
+		position.MarketValue = pos.quantity * mark
+		if pos.costed && asset != p.quote {
+			position.UnrealizedPnL = pos.quantity * (mark - pos.avgCost)
+		}
+	}
+	return position
+}
@@ -0,0 +1,201 @@
+package portfolio_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/portfolio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBalances struct {
+	balances *dvotcWS.AssetBalance
+	err      error
+}
+
+func (f *fakeBalances) ListLimitsBalances() (*dvotcWS.AssetBalance, error) {
+	return f.balances, f.err
+}
+
+func quote(buy, sell float64) *dvotcWS.LevelData {
+	return &dvotcWS.LevelData{
+		QuoteID: "quote",
+		Levels:  []dvotcWS.Level{{BuyPrice: buy, SellPrice: sell, MaxQuantity: 10}},
+	}
+}
+
+func filled(id, side string, qty, price float64) dvotcWS.OrderNotification {
+	now := time.Now()
+	return dvotcWS.OrderNotification{
+		ID:           id,
+		Asset:        "BTC",
+		CounterAsset: "USD",
+		Quantity:     qty,
+		Price:        price,
+		Side:         side,
+		Status:       dvotcWS.OrderStatusComplete,
+		FilledAt:     &now,
+	}
+}
+
+func TestPortfolio(t *testing.T) {
+	t.Run("average_cost_and_pnl", func(t *testing.T) {
+		p, err := portfolio.New(nil, portfolio.Config{})
+		require.NoError(t, err)
+
+		require.NoError(t, p.ApplyNotification(filled("1", "Buy", 2, 20000)))
+		require.NoError(t, p.ApplyNotification(filled("2", "Buy", 2, 22000)))
+		// the order status of an already applied notification is not counted again
+		now := time.Now()
+		require.NoError(t, p.ApplyOrder(dvotcWS.OrderStatus{ID: "2", Asset: "BTC", CounterAsset: "USD", Side: "Buy", Quantity: 2, Price: 22000, FilledAt: &now}))
+		// open orders are ignored
+		require.NoError(t, p.ApplyOrder(dvotcWS.OrderStatus{ID: "3", Asset: "BTC", CounterAsset: "USD", Side: "Buy", Quantity: 2, Price: 22000, Status: dvotcWS.OrderStatusOpen}))
+
+		btc, ok := p.Position("BTC")
+		require.True(t, ok)
+		assert.Equal(t, 4.0, btc.Quantity)
+		assert.Equal(t, 21000.0, btc.AvgCost)
+		assert.False(t, btc.Marked)
+
+		require.NoError(t, p.ApplyNotification(filled("4", "Sell", 1, 23000)))
+		p.Mark("BTC/USD", quote(22010, 21990))
+
+		btc, _ = p.Position("BTC")
+		assert.Equal(t, 3.0, btc.Quantity)
+		assert.Equal(t, 21000.0, btc.AvgCost)
+		assert.Equal(t, 2000.0, btc.RealizedPnL)
+		assert.Equal(t, 22000.0, btc.MarkPrice)
+		assert.Equal(t, 3000.0, btc.UnrealizedPnL)
+		assert.Equal(t, 66000.0, btc.MarketValue)
+
+		usd, _ := p.Position("USD")
+		assert.Equal(t, -84000.0+23000.0, usd.Quantity)
+		assert.Equal(t, 0.0, usd.UnrealizedPnL)
+
+		snapshot := p.Snapshot()
+		assert.Equal(t, "USD", snapshot.QuoteAsset)
+		require.Len(t, snapshot.Positions, 2)
+		assert.Equal(t, "BTC", snapshot.Positions[0].Asset)
+		assert.Equal(t, 2000.0, snapshot.RealizedPnL)
+		assert.Equal(t, 3000.0, snapshot.UnrealizedPnL)
+		assert.Equal(t, 5000.0, snapshot.TotalPnL)
+	})
+
+	t.Run("flip_position", func(t *testing.T) {
+		p, err := portfolio.New(nil, portfolio.Config{})
+		require.NoError(t, err)
+		require.NoError(t, p.ApplyNotification(filled("1", "Buy", 1, 100)))
+		require.NoError(t, p.ApplyNotification(filled("2", "Sell", 3, 110)))
+
+		btc, _ := p.Position("BTC")
+		assert.Equal(t, -2.0, btc.Quantity)
+		assert.Equal(t, 110.0, btc.AvgCost)
+		assert.Equal(t, 10.0, btc.RealizedPnL)
+
+		p.Mark("BTC/USD", quote(101, 99))
+		btc, _ = p.Position("BTC")
+		assert.Equal(t, 20.0, btc.UnrealizedPnL)
+	})
+
+	t.Run("seeded_from_balances", func(t *testing.T) {
+		source := &fakeBalances{balances: &dvotcWS.AssetBalance{
+			Assets:     []dvotcWS.Asset{{Asset: "BTC", Position: 2}, {Asset: "ETH", Position: -5}},
+			UsdBalance: 1000,
+		}}
+		p, err := portfolio.New(source, portfolio.Config{})
+		require.NoError(t, err)
+
+		// seeded positions are costed at the first mark
+		p.Mark("BTC/USD", quote(20010, 19990))
+		p.Mark("BTC/USD", quote(21010, 20990))
+		btc, _ := p.Position("BTC")
+		assert.Equal(t, 2.0, btc.Quantity)
+		assert.Equal(t, 20000.0, btc.AvgCost)
+		assert.Equal(t, 2000.0, btc.UnrealizedPnL)
+
+		eth, _ := p.Position("ETH")
+		assert.Equal(t, -5.0, eth.Quantity)
+		assert.False(t, eth.Marked)
+
+		usd, _ := p.Position("USD")
+		assert.Equal(t, 1000.0, usd.Quantity)
+
+		// reseeding the same quantities keeps their cost
+		require.NoError(t, p.Seed())
+		btc, _ = p.Position("BTC")
+		assert.Equal(t, 20000.0, btc.AvgCost)
+
+		source.err = errors.New("unavailable")
+		_, err = portfolio.New(source, portfolio.Config{})
+		require.Error(t, err)
+	})
+
+	t.Run("cross_fill_waits_for_mark", func(t *testing.T) {
+		p, err := portfolio.New(nil, portfolio.Config{})
+		require.NoError(t, err)
+		fill := dvotcWS.OrderNotification{ID: "1", Asset: "ETH", CounterAsset: "BTC", Side: "Buy", Quantity: 10, Price: 0.05}
+		require.NoError(t, p.ApplyNotification(fill))
+		// a later fill of ETH waits behind it, one of other assets does not
+		later := dvotcWS.OrderNotification{ID: "2", Asset: "ETH", CounterAsset: "USD", Side: "Sell", Quantity: 5, Price: 1100}
+		require.NoError(t, p.ApplyNotification(later))
+		require.NoError(t, p.ApplyNotification(dvotcWS.OrderNotification{ID: "3", Asset: "SOL", CounterAsset: "USD", Side: "Buy", Quantity: 1, Price: 20}))
+		_, ok := p.Position("ETH")
+		assert.False(t, ok)
+		assert.Equal(t, 2, p.Snapshot().PendingFills)
+
+		p.Mark("BTC/USD", quote(20010, 19990))
+		assert.Zero(t, p.Snapshot().PendingFills)
+		eth, _ := p.Position("ETH")
+		assert.Equal(t, 5.0, eth.Quantity)
+		assert.Equal(t, 1000.0, eth.AvgCost)
+		assert.Equal(t, 500.0, eth.RealizedPnL)
+		btc, _ := p.Position("BTC")
+		assert.Equal(t, -0.5, btc.Quantity)
+
+		// the fill is not queued again once applied
+		require.NoError(t, p.ApplyNotification(fill))
+		eth, _ = p.Position("ETH")
+		assert.Equal(t, 5.0, eth.Quantity)
+	})
+
+	t.Run("applied_ids_bounded", func(t *testing.T) {
+		p, err := portfolio.New(nil, portfolio.Config{})
+		require.NoError(t, err)
+		require.NoError(t, p.ApplyNotification(filled("first", "Buy", 1, 100)))
+		for i := 0; i < 10000; i++ {
+			require.NoError(t, p.ApplyNotification(filled(fmt.Sprintf("order-%d", i), "Buy", 1, 100)))
+		}
+		// the oldest ID is forgotten, the recent ones are still skipped
+		require.NoError(t, p.ApplyNotification(filled("first", "Buy", 1, 100)))
+		require.NoError(t, p.ApplyNotification(filled("order-9999", "Buy", 1, 100)))
+		btc, _ := p.Position("BTC")
+		assert.Equal(t, 10002.0, btc.Quantity)
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		p, err := portfolio.New(nil, portfolio.Config{})
+		require.NoError(t, err)
+
+		notifications := make(chan dvotcWS.OrderNotification, 1)
+		orders := make(chan dvotcWS.OrderStatus, 1)
+		notifications <- filled("1", "Buy", 1, 100)
+		orders <- dvotcWS.OrderStatus{ID: "1", Status: dvotcWS.OrderStatusComplete}
+		close(notifications)
+		close(orders)
+		require.NoError(t, p.WatchFills(context.Background(), notifications, orders))
+
+		levels := make(chan *dvotcWS.LevelData, 1)
+		levels <- quote(111, 109)
+		close(levels)
+		require.NoError(t, p.WatchLevels(context.Background(), "BTC/USD", levels))
+
+		btc, _ := p.Position("BTC")
+		assert.Equal(t, 1.0, btc.Quantity)
+		assert.Equal(t, 10.0, btc.UnrealizedPnL)
+	})
+}
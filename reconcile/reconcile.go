@@ -0,0 +1,310 @@
+// Package reconcile matches the fills of an internal ledger against the trades reported by DV Chain
+package reconcile
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+)
+
+var ErrInvalidLedger = errors.New("invalid ledger")
+
+const (
+	defaultTolerance = 1e-9
+	defaultBatchSize = 100
+)
+
+// Fill is one trade recorded in the internal ledger, Side and Status are only
+// compared when set
+type Fill struct {
+	ClientTag string  `json:"clientTag"`
+	Qty       float64 `json:"qty"`
+	Price     float64 `json:"price"`
+	Side      string  `json:"side,omitempty"`
+	Status    string  `json:"status,omitempty"`
+}
+
+// LoadFills reads a ledger file, the format is picked from the extension: .csv, .jsonl or .ndjson
+func LoadFills(path string) ([]Fill, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ReadCSV(f)
+	case ".jsonl", ".ndjson":
+		return ReadJSONL(f)
+	}
+	return nil, fmt.Errorf("%w: unknown file extension of %s", ErrInvalidLedger, path)
+}
+
+// ReadCSV reads fills from a CSV with a header row, client_tag, qty and price columns
+// are required, side and status are optional
+func ReadCSV(r io.Reader) ([]Fill, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading header: %s", ErrInvalidLedger, err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[normalizeColumn(name)] = i
+	}
+	for _, required := range []string{"clienttag", "qty", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: missing column %s", ErrInvalidLedger, required)
+		}
+	}
+	column := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	fills := make([]Fill, 0)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return fills, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidLedger, err)
+		}
+		qty, err := strconv.ParseFloat(column(record, "qty"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: qty: %s", ErrInvalidLedger, line, err)
+		}
+		price, err := strconv.ParseFloat(column(record, "price"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: price: %s", ErrInvalidLedger, line, err)
+		}
+		fills = append(fills, Fill{
+			ClientTag: column(record, "clienttag"),
+			Qty:       qty,
+			Price:     price,
+			Side:      column(record, "side"),
+			Status:    column(record, "status"),
+		})
+	}
+}
+
+// normalizeColumn lets client_tag, clientTag and "Client Tag" name the same column,
+// quantity is accepted for qty
+func normalizeColumn(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.NewReplacer("_", "", " ", "", "-", "").Replace(name)
+	if name == "quantity" {
+		return "qty"
+	}
+	return name
+}
+
+// ReadJSONL reads one JSON encoded Fill per line, blank lines are skipped
+func ReadJSONL(r io.Reader) ([]Fill, error) {
+	fills := make([]Fill, 0)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		fill := Fill{}
+		if err := json.Unmarshal([]byte(text), &fill); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %s", ErrInvalidLedger, line, err)
+		}
+		fills = append(fills, fill)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return fills, nil
+}
+
+// TradeLister is satisfied by *dvotcWS.DVOTCClient
+type TradeLister interface {
+	ListTrades(IDs []string, tradeKeys []string, clientTags []string) ([]dvotcWS.Trade, error)
+}
+
+// FetchTrades lists the trades of every client tag of fills, batchSize tags per request
+func FetchTrades(lister TradeLister, fills []Fill, batchSize int) ([]dvotcWS.Trade, error) {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	tags := make([]string, 0, len(fills))
+	seen := make(map[string]bool)
+	for _, fill := range fills {
+		if fill.ClientTag == "" || seen[fill.ClientTag] {
+			continue
+		}
+		seen[fill.ClientTag] = true
+		tags = append(tags, fill.ClientTag)
+	}
+
+	trades := make([]dvotcWS.Trade, 0, len(tags))
+	for start := 0; start < len(tags); start += batchSize {
+		end := start + batchSize
+		if end > len(tags) {
+			end = len(tags)
+		}
+		batch, err := lister.ListTrades(nil, nil, tags[start:end])
+		if err != nil {
+			return nil, err
+		}
+		trades = append(trades, batch...)
+	}
+	return trades, nil
+}
+
+type Options struct {
+	// PriceTolerance and QtyTolerance are the largest differences not reported
+	// as mismatches, both default to 1e-9
+	PriceTolerance float64
+	QtyTolerance   float64
+}
+
+type Mismatch struct {
+	ClientTag string
+	Fill      Fill
+	Trade     dvotcWS.Trade
+	// Fields lists the fields that differ: price, qty, side or status
+	Fields []string
+}
+
+type Report struct {
+	Matched    int
+	Missing    []Fill
+	Extra      []dvotcWS.Trade
+	Mismatched []Mismatch
+}
+
+// HasBreaks reports if anything did not reconcile
+func (r *Report) HasBreaks() bool {
+	return len(r.Missing) > 0 || len(r.Extra) > 0 || len(r.Mismatched) > 0
+}
+
+// Reconcile matches fills to trades by client tag, in order when a tag is used more than once.
+// Fills without a trade are missing, trades without a fill are extra
+func Reconcile(fills []Fill, trades []dvotcWS.Trade, opts Options) *Report {
+	if opts.PriceTolerance <= 0 {
+		opts.PriceTolerance = defaultTolerance
+	}
+	if opts.QtyTolerance <= 0 {
+		opts.QtyTolerance = defaultTolerance
+	}
+
+	byTag := make(map[string][]dvotcWS.Trade)
+	seen := make(map[string]bool)
+	for _, trade := range trades {
+		// the same trade may come back from overlapping queries
+		if trade.ID != "" && seen[trade.ID] {
+			continue
+		}
+		seen[trade.ID] = true
+		byTag[trade.ClientTag] = append(byTag[trade.ClientTag], trade)
+	}
+
+	report := &Report{
+		Missing:    make([]Fill, 0),
+		Extra:      make([]dvotcWS.Trade, 0),
+		Mismatched: make([]Mismatch, 0),
+	}
+	for _, fill := range fills {
+		candidates := byTag[fill.ClientTag]
+		if fill.ClientTag == "" || len(candidates) == 0 {
+			report.Missing = append(report.Missing, fill)
+			continue
+		}
+		trade := candidates[0]
+		byTag[fill.ClientTag] = candidates[1:]
+
+		fields := compare(fill, trade, opts)
+		if len(fields) == 0 {
+			report.Matched++
+			continue
+		}
+		report.Mismatched = append(report.Mismatched, Mismatch{
+			ClientTag: fill.ClientTag,
+			Fill:      fill,
+			Trade:     trade,
+			Fields:    fields,
+		})
+	}
+
+	for _, trades := range byTag {
+		report.Extra = append(report.Extra, trades...)
+	}
+	sort.Slice(report.Extra, func(i, j int) bool {
+		if report.Extra[i].ClientTag == report.Extra[j].ClientTag {
+			return report.Extra[i].ID < report.Extra[j].ID
+		}
+		return report.Extra[i].ClientTag < report.Extra[j].ClientTag
+	})
+	return report
+}
+
+func compare(fill Fill, trade dvotcWS.Trade, opts Options) []string {
+	fields := make([]string, 0)
+	if math.Abs(fill.Price-trade.Price) > opts.PriceTolerance {
+		fields = append(fields, "price")
+	}
+	if math.Abs(fill.Qty-float64(trade.Quantity)) > opts.QtyTolerance {
+		fields = append(fields, "qty")
+	}
+	if fill.Side != "" && !strings.EqualFold(fill.Side, trade.Side) {
+		fields = append(fields, "side")
+	}
+	if fill.Status != "" && !strings.EqualFold(fill.Status, trade.Status) {
+		fields = append(fields, "status")
+	}
+	return fields
+}
+
+// WriteText writes a human readable report
+func (r *Report) WriteText(w io.Writer) error {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "matched: %d, missing: %d, extra: %d, mismatched: %d\n", r.Matched, len(r.Missing), len(r.Extra), len(r.Mismatched))
+	for _, fill := range r.Missing {
+		fmt.Fprintf(b, "MISSING    %s qty=%s price=%s side=%s\n", fill.ClientTag, formatFloat(fill.Qty), formatFloat(fill.Price), fill.Side)
+	}
+	for _, trade := range r.Extra {
+		fmt.Fprintf(b, "EXTRA      %s id=%s qty=%d price=%s side=%s status=%s\n", trade.ClientTag, trade.ID, trade.Quantity, formatFloat(trade.Price), trade.Side, trade.Status)
+	}
+	for _, m := range r.Mismatched {
+		fmt.Fprintf(b, "MISMATCHED %s id=%s", m.ClientTag, m.Trade.ID)
+		for _, field := range m.Fields {
+			switch field {
+			case "price":
+				fmt.Fprintf(b, " price=%s/%s", formatFloat(m.Fill.Price), formatFloat(m.Trade.Price))
+			case "qty":
+				fmt.Fprintf(b, " qty=%s/%d", formatFloat(m.Fill.Qty), m.Trade.Quantity)
+			case "side":
+				fmt.Fprintf(b, " side=%s/%s", m.Fill.Side, m.Trade.Side)
+			case "status":
+				fmt.Fprintf(b, " status=%s/%s", m.Fill.Status, m.Trade.Status)
+			}
+		}
+		b.WriteString(" (ledger/dvchain)\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
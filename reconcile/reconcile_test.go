@@ -0,0 +1,96 @@
+package reconcile_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/reconcile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLister struct {
+	trades   []dvotcWS.Trade
+	requests [][]string
+}
+
+func (f *fakeLister) ListTrades(IDs []string, tradeKeys []string, clientTags []string) ([]dvotcWS.Trade, error) {
+	f.requests = append(f.requests, clientTags)
+	trades := make([]dvotcWS.Trade, 0)
+	for _, trade := range f.trades {
+		for _, tag := range clientTags {
+			if trade.ClientTag == tag {
+				trades = append(trades, trade)
+			}
+		}
+	}
+	return trades, nil
+}
+
+func TestLoadFills(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "fills.csv")
+	require.NoError(t, os.WriteFile(csvPath, []byte("Client Tag,Quantity,price,side\na,1,100.5,Buy\nb,2,99,Sell\n"), 0o600))
+	jsonlPath := filepath.Join(dir, "fills.jsonl")
+	require.NoError(t, os.WriteFile(jsonlPath, []byte(`{"clientTag":"a","qty":1,"price":100.5,"side":"Buy"}`+"\n\n"+`{"clientTag":"b","qty":2,"price":99,"side":"Sell"}`+"\n"), 0o600))
+
+	fromCSV, err := reconcile.LoadFills(csvPath)
+	require.NoError(t, err)
+	fromJSONL, err := reconcile.LoadFills(jsonlPath)
+	require.NoError(t, err)
+	require.Len(t, fromCSV, 2)
+	assert.Equal(t, fromCSV, fromJSONL)
+	assert.Equal(t, reconcile.Fill{ClientTag: "a", Qty: 1, Price: 100.5, Side: "Buy"}, fromCSV[0])
+
+	_, err = reconcile.ReadCSV(strings.NewReader("client_tag,price\na,1\n"))
+	require.ErrorIs(t, err, reconcile.ErrInvalidLedger)
+	_, err = reconcile.ReadCSV(strings.NewReader("client_tag,qty,price\na,one,1\n"))
+	require.ErrorIs(t, err, reconcile.ErrInvalidLedger)
+	_, err = reconcile.LoadFills(filepath.Join(dir, "fills.xlsx"))
+	require.Error(t, err)
+}
+
+func TestReconcile(t *testing.T) {
+	lister := &fakeLister{trades: []dvotcWS.Trade{
+		{ID: "1", ClientTag: "ok", Quantity: 1, Price: 100, Side: "Buy", Status: "Complete"},
+		{ID: "2", ClientTag: "bad-price", Quantity: 2, Price: 101, Side: "Sell", Status: "Complete"},
+		{ID: "3", ClientTag: "bad-side", Quantity: 3, Price: 100, Side: "Sell", Status: "Complete"},
+		{ID: "4", ClientTag: "twice", Quantity: 1, Price: 100, Side: "Buy", Status: "Complete"},
+		{ID: "5", ClientTag: "twice", Quantity: 1, Price: 100, Side: "Buy", Status: "Complete"},
+	}}
+	fills := []reconcile.Fill{
+		{ClientTag: "ok", Qty: 1, Price: 100, Side: "buy", Status: "complete"},
+		{ClientTag: "bad-price", Qty: 2, Price: 100, Side: "Sell"},
+		{ClientTag: "bad-side", Qty: 4, Price: 100, Side: "Buy"},
+		{ClientTag: "twice", Qty: 1, Price: 100},
+		{ClientTag: "missing", Qty: 1, Price: 100},
+	}
+
+	trades, err := reconcile.FetchTrades(lister, fills, 2)
+	require.NoError(t, err)
+	require.Len(t, lister.requests, 3)
+	assert.Equal(t, []string{"ok", "bad-price"}, lister.requests[0])
+
+	report := reconcile.Reconcile(fills, trades, reconcile.Options{})
+	assert.True(t, report.HasBreaks())
+	assert.Equal(t, 2, report.Matched)
+	require.Len(t, report.Missing, 1)
+	assert.Equal(t, "missing", report.Missing[0].ClientTag)
+	require.Len(t, report.Extra, 1)
+	assert.Equal(t, "5", report.Extra[0].ID)
+	require.Len(t, report.Mismatched, 2)
+	assert.Equal(t, []string{"price"}, report.Mismatched[0].Fields)
+	assert.Equal(t, []string{"qty", "side"}, report.Mismatched[1].Fields)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, report.WriteText(buf))
+	assert.Contains(t, buf.String(), "matched: 2, missing: 1, extra: 1, mismatched: 2")
+	assert.Contains(t, buf.String(), "MISMATCHED bad-side id=3 qty=4/3 side=Buy/Sell (ledger/dvchain)")
+
+	clean := reconcile.Reconcile(fills[:1], trades[:1], reconcile.Options{})
+	assert.False(t, clean.HasBreaks())
+}
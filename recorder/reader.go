@@ -0,0 +1,233 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Query selects the records to read, zero values select everything
+type Query struct {
+	// Symbols defaults to every symbol of the archive
+	Symbols []string
+	// From and To bound the receive time, To is exclusive
+	From time.Time
+	To   time.Time
+}
+
+func (q Query) matches(rec Record) bool {
+	if !q.From.IsZero() && rec.Received.Before(q.From) {
+		return false
+	}
+	return q.To.IsZero() || rec.Received.Before(q.To)
+}
+
+// Symbols returns the symbols archived in dir, sorted
+func Symbols(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	symbols := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			symbols = append(symbols, dirSymbol(e.Name()))
+		}
+	}
+	sort.Strings(symbols)
+	return symbols, nil
+}
+
+// Files returns the files of symbol in dir holding records of q, oldest first
+func Files(dir, symbol string, q Query) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, symbolDir(symbol)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	files := make([]string, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		hour, err := time.Parse(hourLayout, strings.TrimSuffix(name, fileSuffix))
+		if err != nil {
+			continue
+		}
+		if !q.From.IsZero() && !hour.Add(time.Hour).After(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && !hour.Before(q.To) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, symbolDir(symbol), name))
+	}
+	// the hour layout sorts chronologically
+	sort.Strings(files)
+	return files, nil
+}
+
+// stream reads the records of one symbol file after file
+type stream struct {
+	files []string
+	f     *os.File
+	gz    *gzip.Reader
+	dec   *json.Decoder
+	head  *Record
+}
+
+// advance loads the next record into head, head is nil at the end of the stream
+func (s *stream) advance(q Query) error {
+	s.head = nil
+	for {
+		if s.dec == nil {
+			if len(s.files) == 0 {
+				return nil
+			}
+			if err := s.open(s.files[0]); err != nil {
+				return err
+			}
+			s.files = s.files[1:]
+		}
+		var rec Record
+		err := s.dec.Decode(&rec)
+		// a file cut short while its last member was being written, by a crash or
+		// a recorder still running, ends there and keeps the records before the cut
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			s.close()
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.f.Name(), err)
+		}
+		if q.matches(rec) {
+			s.head = &rec
+			return nil
+		}
+	}
+}
+
+func (s *stream) open(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	s.f, s.gz, s.dec = f, gz, json.NewDecoder(gz)
+	return nil
+}
+
+func (s *stream) close() {
+	if s.f != nil {
+		s.gz.Close()
+		s.f.Close()
+	}
+	s.f, s.gz, s.dec = nil, nil, nil
+}
+
+// Reader iterates the records of an archive in receive order across symbols
+//
+//	r, err := recorder.Open(dir, recorder.Query{Symbols: []string{"BTC/USD"}})
+//	defer r.Close()
+//	for r.Next() {
+//		rec := r.Record()
+//	}
+//	err = r.Err()
+type Reader struct {
+	query   Query
+	streams []*stream
+	started bool
+	rec     Record
+	err     error
+}
+
+// Open returns a reader of the records of q in dir
+func Open(dir string, q Query) (*Reader, error) {
+	symbols := q.Symbols
+	if len(symbols) == 0 {
+		var err error
+		if symbols, err = Symbols(dir); err != nil {
+			return nil, err
+		}
+	}
+	r := &Reader{query: q}
+	for _, symbol := range symbols {
+		files, err := Files(dir, symbol, q)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) > 0 {
+			r.streams = append(r.streams, &stream{files: files})
+		}
+	}
+	return r, nil
+}
+
+// Next moves to the next record, it returns false at the end of the archive or
+// on error, see Err
+func (r *Reader) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	if !r.started {
+		r.started = true
+		for _, s := range r.streams {
+			if r.err = s.advance(r.query); r.err != nil {
+				return false
+			}
+		}
+	}
+
+	var next *stream
+	for _, s := range r.streams {
+		if s.head != nil && (next == nil || s.head.Received.Before(next.head.Received)) {
+			next = s
+		}
+	}
+	if next == nil {
+		return false
+	}
+	r.rec = *next.head
+	r.err = next.advance(r.query)
+	return true
+}
+
+// Record returns the current record
+func (r *Reader) Record() Record {
+	return r.rec
+}
+
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Close releases the files still open
+func (r *Reader) Close() error {
+	for _, s := range r.streams {
+		s.close()
+	}
+	return nil
+}
+
+// All reads every remaining record
+func (r *Reader) All() ([]Record, error) {
+	records := make([]Record, 0)
+	for r.Next() {
+		records = append(records, r.Record())
+	}
+	return records, r.Err()
+}
@@ -0,0 +1,306 @@
+// Package recorder archives DVOTC level streams to gzip compressed JSONL files,
+// one per symbol and hour, and reads them back in receive order.
+//
+// Files are laid out as <dir>/<BASE-COUNTER>/<YYYY-MM-DDTHH>.jsonl.gz in UTC, a "-"
+// or "%" in the symbol itself is written %2D or %25. A file reopened within its hour
+// gets a new gzip member appended, which readers handle, as well as a file cut short.
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+)
+
+const (
+	hourLayout = "2006-01-02T15"
+	fileSuffix = ".jsonl.gz"
+)
+
+var ErrClosed = errors.New("recorder is closed")
+
+// LevelSource is satisfied by *dvotcWS.DVOTCClient
+type LevelSource interface {
+	SubscribeLevels(symbol string) (*dvotcWS.SubscribeLevelData, error)
+}
+
+// Record is one line of an archive file
+type Record struct {
+	// Received is when the levels reached the recorder
+	Received   time.Time       `json:"received"`
+	Symbol     string          `json:"symbol"`
+	QuoteID    string          `json:"quoteId"`
+	LastUpdate int64           `json:"lastUpdate"`
+	Levels     []dvotcWS.Level `json:"levels"`
+}
+
+// LevelData returns the levels as the stream delivered them
+func (r Record) LevelData() *dvotcWS.LevelData {
+	return &dvotcWS.LevelData{
+		Levels:     r.Levels,
+		LastUpdate: r.LastUpdate,
+		QuoteID:    r.QuoteID,
+		Market:     r.Symbol,
+	}
+}
+
+type Config struct {
+	// Dir is the root of the archive, created if missing
+	Dir string
+	// FlushInterval is how often Run flushes open files and closes the ones of past
+	// hours, defaults to 5 seconds
+	FlushInterval time.Duration
+	// Now returns the receive time of levels, defaults to time.Now
+	Now func() time.Time
+}
+
+type hourFile struct {
+	hour time.Time
+	f    *os.File
+	buf  *bufio.Writer
+	gz   *gzip.Writer
+	enc  *json.Encoder
+}
+
+func (h *hourFile) flush() error {
+	if err := h.gz.Flush(); err != nil {
+		return err
+	}
+	return h.buf.Flush()
+}
+
+func (h *hourFile) close() error {
+	err := h.gz.Close()
+	if ferr := h.buf.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := h.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Recorder writes levels to the current hour file of their symbol
+type Recorder struct {
+	cfg Config
+
+	mu     sync.Mutex
+	files  map[string]*hourFile
+	closed bool
+}
+
+func New(cfg Config) (*Recorder, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("recorder: dir is required")
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Recorder{cfg: cfg, files: make(map[string]*hourFile)}, nil
+}
+
+// Write records data of symbol as received now
+func (r *Recorder) Write(symbol string, data *dvotcWS.LevelData) error {
+	return r.WriteRecord(Record{
+		Received:   r.cfg.Now().UTC(),
+		Symbol:     symbol,
+		QuoteID:    data.QuoteID,
+		LastUpdate: data.LastUpdate,
+		Levels:     data.Levels,
+	})
+}
+
+// WriteRecord appends rec to the file of its symbol and receive hour, rotating
+// the previous file of the symbol when the hour changed
+func (r *Recorder) WriteRecord(rec Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrClosed
+	}
+	hour := rec.Received.UTC().Truncate(time.Hour)
+	file, ok := r.files[rec.Symbol]
+	if ok && !file.hour.Equal(hour) {
+		delete(r.files, rec.Symbol)
+		if err := file.close(); err != nil {
+			return err
+		}
+		ok = false
+	}
+	if !ok {
+		var err error
+		if file, err = r.open(rec.Symbol, hour); err != nil {
+			return err
+		}
+		r.files[rec.Symbol] = file
+	}
+	return file.enc.Encode(rec)
+}
+
+func (r *Recorder) open(symbol string, hour time.Time) (*hourFile, error) {
+	path := FilePath(r.cfg.Dir, symbol, hour)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(f)
+	gz := gzip.NewWriter(buf)
+	return &hourFile{hour: hour, f: f, buf: buf, gz: gz, enc: json.NewEncoder(gz)}, nil
+}
+
+// Flush writes the buffered records to disk and closes the files of past hours
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current := r.cfg.Now().UTC().Truncate(time.Hour)
+	var firstErr error
+	for symbol, file := range r.files {
+		var err error
+		if file.hour.Before(current) {
+			delete(r.files, symbol)
+			err = file.close()
+		} else {
+			err = file.flush()
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", symbol, err)
+		}
+	}
+	return firstErr
+}
+
+// Close completes every open file, the recorder can't be written to afterwards
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	var firstErr error
+	for symbol, file := range r.files {
+		if err := file.close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", symbol, err)
+		}
+	}
+	r.files = nil
+	return firstErr
+}
+
+// Watch records the levels of symbol until levels is closed or ctx is done
+func (r *Recorder) Watch(ctx context.Context, symbol string, levels <-chan *dvotcWS.LevelData) error {
+	for {
+		select {
+		case data, ok := <-levels:
+			if !ok {
+				return nil
+			}
+			if err := r.Write(symbol, data); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Run subscribes to the levels of symbols and records them until ctx is done,
+// flushing every FlushInterval. The subscriptions are stopped and the files
+// completed before it returns, so cancelling ctx shuts down cleanly and returns ctx.Err()
+func (r *Recorder) Run(parent context.Context, src LevelSource, symbols ...string) error {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	subs := make([]*dvotcWS.SubscribeLevelData, 0, len(symbols))
+	defer func() {
+		for _, sub := range subs {
+			_ = sub.StopConsuming()
+		}
+	}()
+	for _, symbol := range symbols {
+		sub, err := src.SubscribeLevels(symbol)
+		if err != nil {
+			_ = r.Close()
+			return fmt.Errorf("subscribe %s: %w", symbol, err)
+		}
+		subs = append(subs, sub)
+	}
+
+	errs := make(chan error, len(symbols))
+	var wg sync.WaitGroup
+	for i, symbol := range symbols {
+		wg.Add(1)
+		go func(symbol string, levels <-chan *dvotcWS.LevelData) {
+			defer wg.Done()
+			if err := r.Watch(ctx, symbol, levels); err != nil && ctx.Err() == nil {
+				errs <- fmt.Errorf("%s: %w", symbol, err)
+				cancel()
+			}
+		}(symbol, subs[i].Data)
+	}
+
+	ticker := time.NewTicker(r.cfg.FlushInterval)
+	defer ticker.Stop()
+loop:
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Flush(); err != nil {
+				log.Println(err)
+			}
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	wg.Wait()
+
+	if err := r.Close(); err != nil {
+		return err
+	}
+	select {
+	case err := <-errs:
+		return err
+	default:
+	}
+	return parent.Err()
+}
+
+// FilePath returns the file holding the levels of symbol received during hour
+func FilePath(dir, symbol string, hour time.Time) string {
+	return filepath.Join(dir, symbolDir(symbol), hour.UTC().Format(hourLayout)+fileSuffix)
+}
+
+var (
+	dirEscaper   = strings.NewReplacer("%", "%25", "-", "%2D", "/", "-")
+	dirUnescaper = strings.NewReplacer("-", "/", "%2D", "-", "%25", "%")
+)
+
+// symbolDir names the directory of symbol, "/" becomes "-" and the "-" and "%"
+// already in the symbol are escaped so dirSymbol gives the symbol back
+func symbolDir(symbol string) string {
+	return dirEscaper.Replace(symbol)
+}
+
+func dirSymbol(dir string) string {
+	return dirUnescaper.Replace(dir)
+}
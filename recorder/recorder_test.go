@@ -0,0 +1,193 @@
+package recorder_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/dvotctest"
+	"github.com/dv-chain/dvotc-websocket-go/recorder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func levels(quoteID string, buy float64) *dvotcWS.LevelData {
+	return &dvotcWS.LevelData{
+		Levels:     []dvotcWS.Level{{BuyPrice: buy, SellPrice: buy - 20, MaxQuantity: 5}},
+		LastUpdate: 1682935200000,
+		QuoteID:    quoteID,
+	}
+}
+
+func quoteIDs(records []recorder.Record) []string {
+	ids := make([]string, 0, len(records))
+	for _, rec := range records {
+		ids = append(ids, rec.QuoteID)
+	}
+	return ids
+}
+
+func readAll(t *testing.T, dir string, q recorder.Query) []recorder.Record {
+	r, err := recorder.Open(dir, q)
+	require.NoError(t, err)
+	defer r.Close()
+	records, err := r.All()
+	require.NoError(t, err)
+	return records
+}
+
+func TestRecorderPartitions(t *testing.T) {
+	dir := t.TempDir()
+	c := &clock{now: start.Add(30 * time.Minute)}
+	rec, err := recorder.New(recorder.Config{Dir: dir, Now: c.Now})
+	require.NoError(t, err)
+
+	require.NoError(t, rec.Write("ETH/USD", levels("eth-1", 1510)))
+	c.now = start.Add(59 * time.Minute)
+	require.NoError(t, rec.Write("BTC/USD", levels("btc-1", 20010)))
+	c.now = start.Add(61 * time.Minute)
+	require.NoError(t, rec.Write("BTC/USD", levels("btc-2", 20020)))
+	c.now = start.Add(62 * time.Minute)
+	require.NoError(t, rec.Write("ETH/USD", levels("eth-2", 1520)))
+	require.NoError(t, rec.Close())
+	require.ErrorIs(t, rec.Write("BTC/USD", levels("btc-3", 20030)), recorder.ErrClosed)
+
+	for _, path := range []string{
+		recorder.FilePath(dir, "BTC/USD", start),
+		recorder.FilePath(dir, "BTC/USD", start.Add(time.Hour)),
+		recorder.FilePath(dir, "ETH/USD", start),
+		recorder.FilePath(dir, "ETH/USD", start.Add(time.Hour)),
+	} {
+		assert.FileExists(t, path)
+	}
+	assert.Equal(t, filepath.Join(dir, "BTC-USD", "2023-05-01T10.jsonl.gz"), recorder.FilePath(dir, "BTC/USD", start))
+
+	symbols, err := recorder.Symbols(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"BTC/USD", "ETH/USD"}, symbols)
+
+	records := readAll(t, dir, recorder.Query{})
+	assert.Equal(t, []string{"eth-1", "btc-1", "btc-2", "eth-2"}, quoteIDs(records))
+	first := records[1]
+	assert.Equal(t, "BTC/USD", first.Symbol)
+	assert.Equal(t, start.Add(59*time.Minute), first.Received)
+	assert.Equal(t, int64(1682935200000), first.LastUpdate)
+	assert.Equal(t, levels("btc-1", 20010).Levels, first.LevelData().Levels)
+	assert.Equal(t, "BTC/USD", first.LevelData().Market)
+
+	assert.Equal(t, []string{"btc-1", "btc-2"}, quoteIDs(readAll(t, dir, recorder.Query{Symbols: []string{"BTC/USD"}})))
+	assert.Equal(t, []string{"btc-1", "btc-2"}, quoteIDs(readAll(t, dir, recorder.Query{
+		From: start.Add(45 * time.Minute),
+		To:   start.Add(62 * time.Minute),
+	})))
+	assert.Empty(t, readAll(t, dir, recorder.Query{Symbols: []string{"SOL/USD"}}))
+}
+
+func TestRecorderReopen(t *testing.T) {
+	dir := t.TempDir()
+	c := &clock{now: start}
+	for i, id := range []string{"btc-1", "btc-2"} {
+		rec, err := recorder.New(recorder.Config{Dir: dir, Now: c.Now})
+		require.NoError(t, err)
+		c.now = start.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, rec.Write("BTC/USD", levels(id, 20010)))
+		require.NoError(t, rec.Close())
+	}
+	// the second run appended a gzip member to the same hour file
+	assert.Equal(t, []string{"btc-1", "btc-2"}, quoteIDs(readAll(t, dir, recorder.Query{})))
+}
+
+func TestRecorderFlush(t *testing.T) {
+	dir := t.TempDir()
+	c := &clock{now: start}
+	rec, err := recorder.New(recorder.Config{Dir: dir, Now: c.Now})
+	require.NoError(t, err)
+	defer rec.Close()
+
+	require.NoError(t, rec.Write("BTC/USD", levels("btc-1", 20010)))
+	require.NoError(t, rec.Flush())
+	info, err := os.Stat(recorder.FilePath(dir, "BTC/USD", start))
+	require.NoError(t, err)
+	assert.Positive(t, info.Size())
+
+	// past hours are completed so readers don't have to wait for Close
+	c.now = start.Add(time.Hour)
+	require.NoError(t, rec.Flush())
+	assert.Equal(t, []string{"btc-1"}, quoteIDs(readAll(t, dir, recorder.Query{})))
+}
+
+func TestRecorderTruncated(t *testing.T) {
+	dir := t.TempDir()
+	c := &clock{now: start}
+	rec, err := recorder.New(recorder.Config{Dir: dir, Now: c.Now})
+	require.NoError(t, err)
+	defer rec.Close()
+
+	// a flushed member has no gzip trailer yet, as after a crash
+	require.NoError(t, rec.Write("BTC/USD", levels("btc-1", 20010)))
+	require.NoError(t, rec.Write("BTC/USD", levels("btc-2", 20020)))
+	require.NoError(t, rec.Flush())
+	assert.Equal(t, []string{"btc-1", "btc-2"}, quoteIDs(readAll(t, dir, recorder.Query{})))
+}
+
+func TestRecorderSymbolDirs(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := recorder.New(recorder.Config{Dir: dir, Now: func() time.Time { return start }})
+	require.NoError(t, err)
+	for _, symbol := range []string{"BTC/USD", "BTC-PERP/USD", "USD%/EUR"} {
+		require.NoError(t, rec.Write(symbol, levels(symbol, 20010)))
+	}
+	require.NoError(t, rec.Close())
+
+	assert.FileExists(t, filepath.Join(dir, "BTC%2DPERP-USD", "2023-05-01T10.jsonl.gz"))
+	symbols, err := recorder.Symbols(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"BTC-PERP/USD", "BTC/USD", "USD%/EUR"}, symbols)
+	assert.Equal(t, []string{"BTC-PERP/USD"}, quoteIDs(readAll(t, dir, recorder.Query{Symbols: []string{"BTC-PERP/USD"}})))
+}
+
+func TestRecorderRun(t *testing.T) {
+	srv := dvotctest.NewServer(dvotctest.Config{})
+	defer srv.Close()
+	srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 20010, SellPrice: 19990, MaxQuantity: 5})
+	srv.SetLevels("ETH/USD", dvotcWS.Level{BuyPrice: 1510, SellPrice: 1490, MaxQuantity: 50})
+
+	var writes atomic.Int64
+	dir := t.TempDir()
+	rec, err := recorder.New(recorder.Config{Dir: dir, FlushInterval: time.Hour, Now: func() time.Time {
+		writes.Add(1)
+		return time.Now()
+	}})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- rec.Run(ctx, srv.Client(), "BTC/USD", "ETH/USD")
+	}()
+
+	require.Eventually(t, func() bool { return writes.Load() == 2 }, time.Second, 10*time.Millisecond)
+	srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 20110, SellPrice: 20090, MaxQuantity: 5})
+	require.Eventually(t, func() bool { return writes.Load() == 3 }, time.Second, 10*time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	records := readAll(t, dir, recorder.Query{Symbols: []string{"BTC/USD"}})
+	require.Len(t, records, 2)
+	assert.Equal(t, 20110.0, records[1].Levels[0].BuyPrice)
+	assert.Len(t, readAll(t, dir, recorder.Query{}), 3)
+}
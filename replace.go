@@ -0,0 +1,157 @@
+package dvotcWS
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrOrderNotFound      = errors.New("order not found")
+	ErrOrderAlreadyFilled = errors.New("order filled before it could be cancelled")
+	ErrCancelNotConfirmed = errors.New("order cancellation not confirmed")
+)
+
+type ReplaceResult struct {
+	// Original is the last known state of the order being replaced
+	Original *OrderStatus
+	// Replacement is nil when the original filled before the cancel went through
+	Replacement *OrderStatus
+}
+
+// ReplaceLimitOrder cancels orderID, waits for the cancellation to be confirmed
+// through order updates and only then places a limit order with the same asset,
+// side and client tag at newPrice for newQty. ErrOrderAlreadyFilled is returned
+// with the filled original if it filled in the meantime. Without an update within
+// the request timeout the order status is listed from the server instead, and
+// ErrCancelNotConfirmed is returned if it is not cancelled there either
+func (dvotc *DVOTCClient) ReplaceLimitOrder(ctx context.Context, orderID string, newPrice, newQty float64) (*ReplaceResult, error) {
+	if dvotc.halted.Load() {
+		return nil, ErrTradingHalted
+	}
+
+	original, err := dvotc.lookupOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	result := &ReplaceResult{Original: original}
+	if isFilledOrderStatus(*original) {
+		return result, ErrOrderAlreadyFilled
+	}
+
+	// confirmation only comes through the order-updates stream
+	if err := dvotc.TrackOrderUpdates(); err != nil {
+		return nil, err
+	}
+	updates, stopWatching := dvotc.orders.watch(orderID)
+	defer stopWatching()
+
+	if err := dvotc.cancelOrder(ctx, orderID); err != nil {
+		// cancel is rejected when the order is no longer open, find out if it filled
+		if latest, lookupErr := dvotc.fetchOrder(ctx, orderID); lookupErr == nil {
+			result.Original = latest
+			if isFilledOrderStatus(*latest) {
+				return result, ErrOrderAlreadyFilled
+			}
+		}
+		return result, fmt.Errorf("cancelling order %s: %w", orderID, err)
+	}
+
+	var timeout <-chan time.Time
+	if d := time.Duration(dvotc.requestTimeout.Load()); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for confirmed := false; !confirmed; {
+		select {
+		case update := <-updates:
+			result.Original = &update
+			if isFilledOrderStatus(update) {
+				return result, ErrOrderAlreadyFilled
+			}
+			confirmed = isCancelledOrderStatus(update)
+		case <-timeout:
+			// the update may have been lost, ask the server
+			latest, err := dvotc.fetchOrder(ctx, orderID)
+			if err != nil {
+				return result, fmt.Errorf("%w: %s: %s", ErrCancelNotConfirmed, orderID, err)
+			}
+			result.Original = latest
+			if isFilledOrderStatus(*latest) {
+				return result, ErrOrderAlreadyFilled
+			}
+			if !isCancelledOrderStatus(*latest) {
+				return result, fmt.Errorf("%w: %s is %s", ErrCancelNotConfirmed, orderID, latest.Status)
+			}
+			confirmed = true
+		case <-ctx.Done():
+			return result, ctx.Err()
+		}
+	}
+
+	replacement, err := dvotc.placeLimitOrder(ctx, LimitOrderParams{
+		Asset:        original.Asset,
+		CounterAsset: original.CounterAsset,
+		LimitPrice:   newPrice,
+		Qty:          newQty,
+		Side:         original.Side,
+		ClientTag:    original.ClientTag,
+	})
+	if err != nil {
+		return result, err
+	}
+	result.Replacement = replacement
+	return result, nil
+}
+
+// lookupOrder returns the tracked state of an order or fetches it from the server
+func (dvotc *DVOTCClient) lookupOrder(ctx context.Context, orderID string) (*OrderStatus, error) {
+	if order, ok := dvotc.orders.get(orderID); ok {
+		return &order, nil
+	}
+	return dvotc.fetchOrder(ctx, orderID)
+}
+
+// FetchOrder returns the current status of an order as listed by the server
+func (dvotc *DVOTCClient) FetchOrder(ctx context.Context, orderID string) (*OrderStatus, error) {
+	return dvotc.fetchOrder(ctx, orderID)
+}
+
+func (dvotc *DVOTCClient) fetchOrder(ctx context.Context, orderID string) (*OrderStatus, error) {
+	trades, err := dvotc.listTrades(ctx, ListTradesPayload{IDs: orderID})
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range trades {
+		if t.ID != orderID {
+			continue
+		}
+		order := tradeOrderStatus(t)
+		return &order, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrOrderNotFound, orderID)
+}
+
+// tradeOrderStatus returns the order status of a trade listed by ListTrades
+func tradeOrderStatus(t Trade) OrderStatus {
+	order := OrderStatus{
+		ID:           t.ID,
+		ClientTag:    t.ClientTag,
+		LimitPrice:   fmt.Sprintf("%g", t.LimitPrice),
+		Price:        t.Price,
+		Quantity:     float64(t.Quantity),
+		Side:         t.Side,
+		Asset:        t.Asset,
+		CounterAsset: t.CounterAsset,
+		Status:       t.Status,
+		User:         t.User,
+		CreatedAt:    t.CreatedAt,
+	}
+	if isFilledOrderStatus(OrderStatus{Status: t.Status}) {
+		filledAt := t.FilledAt
+		order.FilledAt = &filledAt
+	}
+	return order
+}
@@ -0,0 +1,182 @@
+package dvotcWS_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupReplaceServer acks every cancel and then publishes cancelStatus for the
+// order on the order-updates stream, if set. The original order is listed with
+// the next of tradeStatuses, the last one repeating, Open when there are none
+func setupReplaceServer(t *testing.T, cancelStatus string, tradeStatuses ...string) *routerWebsocketServer {
+	if len(tradeStatuses) == 0 {
+		tradeStatuses = []string{dvotcWS.OrderStatusOpen}
+	}
+	var listed atomic.Int64
+	wsServer := &routerWebsocketServer{t: t}
+	wsServer.handlers = map[string]func(req dvotcWS.Payload) []dvotcWS.Payload{
+		"createorder": func(req dvotcWS.Payload) []dvotcWS.Payload {
+			order := dvotcWS.Order{}
+			require.NoError(t, json.Unmarshal(req.Data, &order))
+			status := dvotcWS.OrderStatus{
+				ID:           "replacement",
+				ClientTag:    order.ClientTag,
+				LimitPrice:   *order.LimitPrice,
+				Quantity:     order.Qty,
+				Side:         order.Side,
+				OrderType:    order.OrderType,
+				Asset:        order.Asset,
+				CounterAsset: order.CounterAsset,
+				Status:       dvotcWS.OrderStatusOpen,
+				CreatedAt:    time.Now().UTC(),
+			}
+			req.Data, _ = json.Marshal(status)
+			return []dvotcWS.Payload{req}
+		},
+		"cancelorder/": func(req dvotcWS.Payload) []dvotcWS.Payload {
+			orderID := strings.TrimPrefix(req.Topic, "cancelorder/")
+			update := dvotcWS.OrderStatus{
+				ID:           orderID,
+				ClientTag:    "tag",
+				Side:         "Buy",
+				Asset:        "BTC",
+				CounterAsset: "USD",
+				Status:       cancelStatus,
+				CreatedAt:    time.Now().UTC(),
+			}
+			if cancelStatus != "" {
+				data, _ := json.Marshal(update)
+				wsServer.Publish(dvotcWS.Payload{Type: dvotcWS.MessageTypeSubscribe, Event: "order-updates", Topic: "order/#", Data: data})
+			}
+
+			req.Data = nil
+			return []dvotcWS.Payload{req}
+		},
+		"tradestatus": func(req dvotcWS.Payload) []dvotcWS.Payload {
+			trades := []dvotcWS.Trade{}
+			if strings.Contains(string(req.Data), "original") {
+				i := int(listed.Add(1)) - 1
+				if i >= len(tradeStatuses) {
+					i = len(tradeStatuses) - 1
+				}
+				trades = append(trades, dvotcWS.Trade{
+					ID:           "original",
+					ClientTag:    "tag",
+					Side:         "Buy",
+					Asset:        "BTC",
+					CounterAsset: "USD",
+					Status:       tradeStatuses[i],
+					LimitPrice:   20000,
+					Quantity:     1,
+				})
+			}
+			req.Data, _ = json.Marshal(trades)
+			return []dvotcWS.Payload{req}
+		},
+	}
+	return wsServer
+}
+
+func setupReplaceClient(t *testing.T, wsServer *routerWebsocketServer) *dvotcWS.DVOTCClient {
+	url := setupRouterWebsocketServer(wsServer)
+	client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+	require.NoError(t, client.TrackOrderUpdates())
+	require.Eventually(t, func() bool {
+		return len(wsServer.Requests("order/")) == 1
+	}, time.Second, 10*time.Millisecond)
+	return client
+}
+
+func TestReplaceLimitOrder(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		wsServer := setupReplaceServer(t, dvotcWS.OrderStatusCancelled)
+		defer wsServer.StopServer()
+		client := setupReplaceClient(t, wsServer)
+		defer client.StopTrackingOrderUpdates()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		res, err := client.ReplaceLimitOrder(ctx, "original", 21000, 2)
+		require.NoError(t, err)
+		assert.Equal(t, dvotcWS.OrderStatusCancelled, res.Original.Status)
+		require.NotNil(t, res.Replacement)
+		assert.Equal(t, "replacement", res.Replacement.ID)
+		assert.Equal(t, "21000", res.Replacement.LimitPrice)
+		assert.Equal(t, 2.0, res.Replacement.Quantity)
+		assert.Equal(t, "tag", res.Replacement.ClientTag)
+		assert.Equal(t, "Buy", res.Replacement.Side)
+
+		// cancel is confirmed before placing the replacement
+		require.Len(t, wsServer.Requests("cancelorder/original"), 1)
+		require.Len(t, wsServer.Requests("createorder"), 1)
+	})
+
+	t.Run("filled_before_cancel", func(t *testing.T) {
+		wsServer := setupReplaceServer(t, dvotcWS.OrderStatusComplete)
+		defer wsServer.StopServer()
+		client := setupReplaceClient(t, wsServer)
+		defer client.StopTrackingOrderUpdates()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		res, err := client.ReplaceLimitOrder(ctx, "original", 21000, 2)
+		require.ErrorIs(t, err, dvotcWS.ErrOrderAlreadyFilled)
+		assert.Equal(t, dvotcWS.OrderStatusComplete, res.Original.Status)
+		assert.Nil(t, res.Replacement)
+		assert.Empty(t, wsServer.Requests("createorder"))
+	})
+
+	t.Run("confirmed_by_trade_status", func(t *testing.T) {
+		// the cancel update is lost, the order is listed as cancelled after the timeout
+		wsServer := setupReplaceServer(t, "", dvotcWS.OrderStatusOpen, dvotcWS.OrderStatusCancelled)
+		defer wsServer.StopServer()
+		client := setupReplaceClient(t, wsServer)
+		defer client.StopTrackingOrderUpdates()
+		client.SetRequestTimeout(200 * time.Millisecond)
+
+		res, err := client.ReplaceLimitOrder(context.Background(), "original", 21000, 2)
+		require.NoError(t, err)
+		assert.Equal(t, dvotcWS.OrderStatusCancelled, res.Original.Status)
+		require.NotNil(t, res.Replacement)
+		assert.Len(t, wsServer.Requests("tradestatus"), 2)
+	})
+
+	t.Run("not_confirmed", func(t *testing.T) {
+		wsServer := setupReplaceServer(t, "")
+		defer wsServer.StopServer()
+		client := setupReplaceClient(t, wsServer)
+		defer client.StopTrackingOrderUpdates()
+		client.SetRequestTimeout(200 * time.Millisecond)
+
+		res, err := client.ReplaceLimitOrder(context.Background(), "original", 21000, 2)
+		require.ErrorIs(t, err, dvotcWS.ErrCancelNotConfirmed)
+		assert.Equal(t, dvotcWS.OrderStatusOpen, res.Original.Status)
+		assert.Nil(t, res.Replacement)
+		assert.Empty(t, wsServer.Requests("createorder"))
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		wsServer := setupReplaceServer(t, dvotcWS.OrderStatusCancelled)
+		defer wsServer.StopServer()
+		url := setupRouterWebsocketServer(wsServer)
+		client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+
+		_, err := client.ReplaceLimitOrder(context.Background(), "missing", 21000, 2)
+		require.ErrorIs(t, err, dvotcWS.ErrOrderNotFound)
+	})
+
+	t.Run("halted", func(t *testing.T) {
+		client := dvotcWS.NewDVOTCClient("ws://localhost/websocket", "123", "321")
+		client.KillSwitch()
+		_, err := client.ReplaceLimitOrder(context.Background(), "original", 21000, 2)
+		require.ErrorIs(t, err, dvotcWS.ErrTradingHalted)
+	})
+}
@@ -0,0 +1,147 @@
+package replay
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fasthttp/websocket"
+)
+
+// Recorder is a local websocket proxy to the DVOTC API that writes every frame
+// exchanged with its clients to a session file
+type Recorder struct {
+	// URL is the websocket URL to pass to dvotcWS.NewDVOTCClient instead of the API URL
+	URL string
+
+	upstream string
+	srv      *http.Server
+	upgrader websocket.Upgrader
+	wg       sync.WaitGroup
+
+	mu     sync.Mutex
+	enc    *json.Encoder
+	err    error
+	conns  map[*websocket.Conn]struct{}
+	nextID int
+}
+
+// NewRecorder starts a recorder proxying to upstream, the URL the client would
+// otherwise use, e.g. "wss://trade.dvchain.co", and writing the session to w
+func NewRecorder(upstream string, w io.Writer) (*Recorder, error) {
+	r := &Recorder{
+		upstream: strings.TrimSuffix(upstream, "/"),
+		enc:      json.NewEncoder(w),
+		conns:    make(map[*websocket.Conn]struct{}),
+	}
+	u, srv, err := listen(http.HandlerFunc(r.handler))
+	if err != nil {
+		return nil, err
+	}
+	r.URL, r.srv = u, srv
+	return r, nil
+}
+
+// Close stops the recorder, closes the proxied connections and returns the first
+// error met writing the session
+func (r *Recorder) Close() error {
+	_ = r.srv.Close()
+	r.mu.Lock()
+	for c := range r.conns {
+		c.Close()
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) handler(w http.ResponseWriter, req *http.Request) {
+	// the auth headers are signed by the client, pass them through untouched
+	header := http.Header{}
+	for k, v := range req.Header {
+		if strings.HasPrefix(strings.ToLower(k), "dv-") {
+			header[k] = v
+		}
+	}
+	target := r.upstream + req.URL.Path
+	if req.URL.RawQuery != "" {
+		target += "?" + req.URL.RawQuery
+	}
+	server, resp, err := websocket.DefaultDialer.Dial(target, header)
+	if err != nil {
+		log.Printf("replay: dial %s: %v", target, err)
+		status := http.StatusBadGateway
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	client, err := r.upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		server.Close()
+		return
+	}
+
+	r.mu.Lock()
+	r.nextID++
+	id := r.nextID
+	r.conns[client] = struct{}{}
+	r.conns[server] = struct{}{}
+	r.mu.Unlock()
+	r.record(Entry{Time: time.Now().UTC(), Conn: id, Direction: DirOpen})
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		stop := func() {
+			client.Close()
+			server.Close()
+		}
+		var pipes sync.WaitGroup
+		pipes.Add(1)
+		go func() {
+			defer pipes.Done()
+			r.pipe(id, DirIn, server, client, stop)
+		}()
+		r.pipe(id, DirOut, client, server, stop)
+		pipes.Wait()
+
+		r.mu.Lock()
+		delete(r.conns, client)
+		delete(r.conns, server)
+		r.mu.Unlock()
+		r.record(Entry{Time: time.Now().UTC(), Conn: id, Direction: DirClose})
+	}()
+}
+
+// pipe copies and records the frames read from src to dst until either side fails
+func (r *Recorder) pipe(id int, dir Direction, src, dst *websocket.Conn, stop func()) {
+	defer stop()
+	for {
+		messageType, data, err := src.ReadMessage()
+		if err != nil {
+			return
+		}
+		r.record(newFrameEntry(id, dir, data))
+		if err := dst.WriteMessage(messageType, data); err != nil {
+			return
+		}
+	}
+}
+
+func (r *Recorder) record(e Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.err = r.enc.Encode(e)
+}
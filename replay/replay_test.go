@@ -0,0 +1,168 @@
+package replay_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/dvotctest"
+	"github.com/dv-chain/dvotc-websocket-go/replay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var order = dvotcWS.MarketOrderParams{
+	Asset:        "BTC",
+	CounterAsset: "USD",
+	Price:        20010,
+	Qty:          1,
+	Side:         "Buy",
+	ClientTag:    "recorded",
+}
+
+// recordSession records levels around a reconnect and a market order against a fake server
+func recordSession(t *testing.T) []replay.Entry {
+	srv := dvotctest.NewServer(dvotctest.Config{})
+	defer srv.Close()
+	srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 20010, SellPrice: 19990, MaxQuantity: 5})
+
+	var session bytes.Buffer
+	rec, err := replay.NewRecorder(srv.URL, &session)
+	require.NoError(t, err)
+	client := dvotcWS.NewDVOTCClient(rec.URL, dvotctest.DefaultAPIKey, dvotctest.DefaultAPISecret)
+
+	sub, err := client.SubscribeLevels("BTC/USD")
+	require.NoError(t, err)
+	<-sub.Data
+	srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 20110, SellPrice: 20090, MaxQuantity: 5})
+	<-sub.Data
+	srv.SendReconnect("BTC/USD", "levels")
+	<-sub.Data
+	srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 20210, SellPrice: 20190, MaxQuantity: 5})
+	levels := <-sub.Data
+
+	params := order
+	params.QuoteID = levels.QuoteID
+	_, err = client.PlaceMarketOrder(params)
+	require.NoError(t, err)
+	require.NoError(t, rec.Close())
+
+	entries, err := replay.ReadSession(&session)
+	require.NoError(t, err)
+	return entries
+}
+
+func TestRecorder(t *testing.T) {
+	entries := recordSession(t)
+
+	conns := make(map[int][]replay.Direction)
+	for _, e := range entries {
+		conns[e.Conn] = append(conns[e.Conn], e.Direction)
+		assert.False(t, e.Time.IsZero())
+	}
+	// levels, levels after the reconnect, orders
+	require.Len(t, conns, 3)
+	assert.Equal(t, []replay.Direction{
+		replay.DirOpen, replay.DirOut, replay.DirIn, replay.DirIn, replay.DirIn, replay.DirClose,
+	}, conns[1])
+	assert.Equal(t, []replay.Direction{
+		replay.DirOpen, replay.DirOut, replay.DirIn, replay.DirIn, replay.DirClose,
+	}, conns[2])
+	assert.Equal(t, []replay.Direction{
+		replay.DirOpen, replay.DirOut, replay.DirIn, replay.DirClose,
+	}, conns[3])
+
+	assert.Equal(t, "reconnect", entries[4].Payload.Event)
+	assert.Equal(t, "createorder", entries[len(entries)-4].Payload.Topic)
+}
+
+func TestReplay(t *testing.T) {
+	entries := recordSession(t)
+	srv, err := replay.NewServer(entries, replay.Options{Speed: replay.Instant})
+	require.NoError(t, err)
+	defer srv.Close()
+
+	client := dvotcWS.NewDVOTCClient(srv.URL, "any-key", "any-secret")
+	sub, err := client.SubscribeLevels("BTC/USD")
+	require.NoError(t, err)
+	quotes := make([]string, 0, 4)
+	prices := make([]float64, 0, 4)
+	for i := 0; i < 4; i++ {
+		levels := <-sub.Data
+		quotes = append(quotes, levels.QuoteID)
+		prices = append(prices, levels.Levels[0].BuyPrice)
+	}
+	assert.Equal(t, []string{"quote-1", "quote-2", "quote-2", "quote-3"}, quotes)
+	assert.Equal(t, []float64{20010, 20110, 20110, 20210}, prices)
+
+	params := order
+	params.QuoteID = quotes[3]
+	placed, err := client.PlaceMarketOrder(params)
+	require.NoError(t, err)
+	assert.Equal(t, "recorded", placed.ClientTag)
+	assert.True(t, placed.IsFilled())
+
+	select {
+	case <-srv.Done():
+	case <-time.After(time.Second):
+		t.Fatal("session not played to its end")
+	}
+	assert.Empty(t, srv.Mismatches())
+}
+
+func TestReplayTiming(t *testing.T) {
+	start := time.Now()
+	levels, _ := json.Marshal(dvotcWS.LevelData{QuoteID: "q-1", Market: "BTC/USD"})
+	session := []replay.Entry{
+		{Time: start, Conn: 1, Direction: replay.DirOpen},
+		{Time: start, Conn: 1, Direction: replay.DirOut, Payload: &dvotcWS.Payload{Type: dvotcWS.MessageTypeSubscribe, Topic: "BTC/USD", Event: "levels"}},
+		{Time: start.Add(400 * time.Millisecond), Conn: 1, Direction: replay.DirIn, Payload: &dvotcWS.Payload{Type: dvotcWS.MessageTypeSubscribe, Topic: "BTC/USD", Event: "levels", Data: levels}},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		speed    float64
+		min, max time.Duration
+	}{
+		{name: "original", min: 400 * time.Millisecond, max: time.Second},
+		{name: "accelerated", speed: 4, min: 100 * time.Millisecond, max: 350 * time.Millisecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, err := replay.NewServer(session, replay.Options{Speed: tc.speed})
+			require.NoError(t, err)
+			defer srv.Close()
+
+			client := dvotcWS.NewDVOTCClient(srv.URL, "any-key", "any-secret")
+			subscribed := time.Now()
+			sub, err := client.SubscribeLevels("BTC/USD")
+			require.NoError(t, err)
+			assert.Equal(t, "q-1", (<-sub.Data).QuoteID)
+			elapsed := time.Since(subscribed)
+			assert.GreaterOrEqual(t, elapsed, tc.min)
+			assert.Less(t, elapsed, tc.max)
+		})
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	start := time.Now()
+	session := []replay.Entry{
+		{Time: start, Conn: 1, Direction: replay.DirOpen},
+		{Time: start, Conn: 1, Direction: replay.DirOut, Payload: &dvotcWS.Payload{Type: dvotcWS.MessageTypeSubscribe, Topic: "BTC/USD", Event: "levels"}},
+	}
+	srv, err := replay.NewServer(session, replay.Options{})
+	require.NoError(t, err)
+	defer srv.Close()
+
+	client := dvotcWS.NewDVOTCClient(srv.URL, "any-key", "any-secret")
+	_, err = client.SubscribeLevels("ETH/USD")
+	require.NoError(t, err)
+	<-srv.Done()
+
+	mismatches := srv.Mismatches()
+	require.Len(t, mismatches, 1)
+	assert.Equal(t, "BTC/USD", mismatches[0].Expected.Topic)
+	assert.Equal(t, "ETH/USD", mismatches[0].Got.Topic)
+}
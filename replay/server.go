@@ -0,0 +1,251 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/fasthttp/websocket"
+)
+
+// Instant plays a session without waiting between frames
+var Instant = math.Inf(1)
+
+type Options struct {
+	// Speed divides the recorded gaps between frames, 10 plays ten times faster,
+	// zero keeps the original timing and Instant sends frames back to back
+	Speed float64
+}
+
+// Mismatch is a frame of the client that differs from the recorded one
+type Mismatch struct {
+	Conn     int
+	Expected dvotcWS.Payload
+	Got      dvotcWS.Payload
+}
+
+func (m Mismatch) Error() string {
+	return fmt.Sprintf("conn %d: expected %s %s, got %s %s",
+		m.Conn, m.Expected.Type, m.Expected.Topic, m.Got.Type, m.Got.Topic)
+}
+
+// Server plays a recorded session back to the clients connecting to it. The n-th
+// connection opened gets the n-th recorded connection: the server waits for each
+// recorded client frame, then sends the recorded server frames with their original
+// gaps scaled by Options.Speed. Request events are rewritten to the ones the client
+// uses, so responses match even if request IDs differ from the recording.
+// Credentials are not checked
+type Server struct {
+	// URL is the websocket URL to pass to dvotcWS.NewDVOTCClient
+	URL string
+
+	speed    float64
+	srv      *http.Server
+	upgrader websocket.Upgrader
+	wg       sync.WaitGroup
+	done     chan struct{}
+
+	mu         sync.Mutex
+	conns      [][]Entry
+	next       int
+	playing    int
+	open       map[*websocket.Conn]struct{}
+	mismatches []Mismatch
+}
+
+// NewServer starts a server playing session, as returned by ReadSession
+func NewServer(session []Entry, opts Options) (*Server, error) {
+	if opts.Speed <= 0 {
+		opts.Speed = 1
+	}
+	s := &Server{
+		speed: opts.Speed,
+		conns: splitConns(session),
+		open:  make(map[*websocket.Conn]struct{}),
+		done:  make(chan struct{}),
+	}
+	if len(s.conns) == 0 {
+		close(s.done)
+	}
+	u, srv, err := listen(http.HandlerFunc(s.handler))
+	if err != nil {
+		return nil, err
+	}
+	s.URL, s.srv = u, srv
+	return s, nil
+}
+
+// Close stops the server and closes the connections being played
+func (s *Server) Close() {
+	_ = s.srv.Close()
+	s.mu.Lock()
+	for c := range s.open {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Done is closed once every recorded connection was played to its end
+func (s *Server) Done() <-chan struct{} {
+	return s.done
+}
+
+// Mismatches returns the client frames that differed from the recording, in order
+func (s *Server) Mismatches() []Mismatch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Mismatch{}, s.mismatches...)
+}
+
+// splitConns groups entries by connection, in the order the connections were opened
+func splitConns(session []Entry) [][]Entry {
+	byConn := make(map[int][]Entry)
+	first := make(map[int]int)
+	for i, e := range session {
+		if _, ok := first[e.Conn]; !ok {
+			first[e.Conn] = i
+		}
+		byConn[e.Conn] = append(byConn[e.Conn], e)
+	}
+	ids := make([]int, 0, len(byConn))
+	for id := range byConn {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return first[ids[i]] < first[ids[j]] })
+
+	conns := make([][]Entry, 0, len(ids))
+	for _, id := range ids {
+		conns = append(conns, byConn[id])
+	}
+	return conns
+}
+
+func (s *Server) handler(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	if s.next == len(s.conns) {
+		s.mu.Unlock()
+		http.Error(w, "replay: no recorded connection left", http.StatusServiceUnavailable)
+		return
+	}
+	entries := s.conns[s.next]
+	s.next++
+	s.playing++
+	s.mu.Unlock()
+
+	conn, err := s.upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		s.finished()
+		return
+	}
+	s.mu.Lock()
+	s.open[conn] = struct{}{}
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer conn.Close()
+		s.play(conn, entries)
+
+		s.mu.Lock()
+		delete(s.open, conn)
+		s.mu.Unlock()
+	}()
+}
+
+// finished counts a connection played to its end and closes done after the last one
+func (s *Server) finished() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.playing--
+	if s.playing == 0 && s.next == len(s.conns) {
+		close(s.done)
+	}
+}
+
+func (s *Server) play(conn *websocket.Conn, entries []Entry) {
+	events := make(map[string]string)
+	var recorded time.Time
+	played := time.Now()
+	for _, e := range entries {
+		if recorded.IsZero() {
+			recorded = e.Time
+		}
+		switch e.Direction {
+		case DirOut:
+			if !s.expect(conn, e, events) {
+				s.finished()
+				return
+			}
+		case DirIn:
+			wait := time.Duration(float64(e.Time.Sub(recorded)) / s.speed)
+			time.Sleep(time.Until(played.Add(wait)))
+			if err := s.send(conn, e, events); err != nil {
+				s.finished()
+				return
+			}
+		case DirClose:
+			// a clean close, the client must not take it for a dropped connection
+			_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			s.finished()
+			return
+		}
+		recorded, played = e.Time, time.Now()
+	}
+	s.finished()
+
+	// the recording stopped with the connection open, keep it open until the client leaves
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// expect reads the next client frame and compares it with the recorded one e,
+// the event of requests is remembered to rewrite the recorded responses
+func (s *Server) expect(conn *websocket.Conn, e Entry, events map[string]string) bool {
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return false
+	}
+	if e.Payload == nil {
+		return true
+	}
+	var got dvotcWS.Payload
+	if err := json.Unmarshal(data, &got); err != nil {
+		log.Printf("replay: conn %d: %v", e.Conn, err)
+	}
+	if got.Type != e.Payload.Type || got.Topic != e.Payload.Topic {
+		s.mu.Lock()
+		s.mismatches = append(s.mismatches, Mismatch{Conn: e.Conn, Expected: *e.Payload, Got: got})
+		s.mu.Unlock()
+		return true
+	}
+	if got.Type == dvotcWS.MessageTypeRequestResponse || got.Type == dvotcWS.MessageTypePingPong {
+		events[e.Payload.Event] = got.Event
+	}
+	return true
+}
+
+func (s *Server) send(conn *websocket.Conn, e Entry, events map[string]string) error {
+	if e.Payload != nil {
+		if event, ok := events[e.Payload.Event]; ok {
+			p := *e.Payload
+			p.Event = event
+			e.Payload = &p
+		}
+	}
+	data, err := e.frame()
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
@@ -0,0 +1,117 @@
+// Package replay records DVOTC websocket sessions to JSONL files and plays them
+// back to a DVOTCClient, to reproduce production sequences of levels, responses
+// and reconnects locally.
+//
+// Record by pointing the client at a Recorder, which proxies to the real API:
+//
+//	rec, err := replay.NewRecorder("wss://trade.dvchain.co", file)
+//	client := dvotcWS.NewDVOTCClient(rec.URL, apiKey, apiSecret)
+//	...
+//	rec.Close()
+//
+// and replay the file with a Server:
+//
+//	session, err := replay.LoadSession("incident.jsonl")
+//	srv, err := replay.NewServer(session, replay.Options{Speed: 10})
+//	client := dvotcWS.NewDVOTCClient(srv.URL, "any", "any")
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+)
+
+type Direction string
+
+const (
+	// DirOpen marks a connection established by the client
+	DirOpen Direction = "open"
+	// DirOut is a frame sent by the client to the server
+	DirOut Direction = "out"
+	// DirIn is a frame sent by the server to the client
+	DirIn Direction = "in"
+	// DirClose marks the end of a connection, closed by either side
+	DirClose Direction = "close"
+)
+
+// Entry is one line of a session file
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Conn numbers the connections of the session in the order they were opened, from 1
+	Conn      int       `json:"conn"`
+	Direction Direction `json:"dir"`
+	// Payload is set for frames that decode to a Payload
+	Payload *dvotcWS.Payload `json:"payload,omitempty"`
+	// Raw holds the frames that don't, as sent
+	Raw string `json:"raw,omitempty"`
+}
+
+func newFrameEntry(conn int, dir Direction, data []byte) Entry {
+	e := Entry{Time: time.Now().UTC(), Conn: conn, Direction: dir}
+	var p dvotcWS.Payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		e.Raw = string(data)
+	} else {
+		e.Payload = &p
+	}
+	return e
+}
+
+// frame returns the bytes to send for e
+func (e Entry) frame() ([]byte, error) {
+	if e.Payload == nil {
+		return []byte(e.Raw), nil
+	}
+	return json.Marshal(e.Payload)
+}
+
+// ReadSession decodes the entries of a session file, in order
+func ReadSession(r io.Reader) ([]Entry, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	entries := make([]Entry, 0)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// LoadSession reads the session file at path
+func LoadSession(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadSession(f)
+}
+
+// listen serves handler on a local port and returns its websocket URL
+func listen(handler http.Handler) (string, *http.Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+	srv := &http.Server{Handler: handler}
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	return "ws://" + l.Addr().String(), srv, nil
+}
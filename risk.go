@@ -0,0 +1,478 @@
+package dvotcWS
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+)
+
+var ErrRiskCheckFailed = errors.New("order rejected by pre-trade risk check")
+
+type RiskReason string
+
+const (
+	RiskReasonMaxBuy       RiskReason = "max-buy"
+	RiskReasonMaxSell      RiskReason = "max-sell"
+	RiskReasonMaxNotional  RiskReason = "max-notional"
+	RiskReasonMaxOrderSize RiskReason = "max-order-size"
+	RiskReasonUnknownAsset RiskReason = "unknown-asset"
+	// RiskReasonNoPrice rejects orders without a price for symbols with a notional limit
+	RiskReasonNoPrice RiskReason = "no-price"
+	// RiskReasonStaleLimits rejects orders once the cached limits can't be kept current
+	RiskReasonStaleLimits RiskReason = "stale-limits"
+)
+
+// RiskError is returned by PlaceMarketOrder/PlaceLimitOrder when an order is
+// rejected locally, errors.Is(err, ErrRiskCheckFailed) holds for all of them
+type RiskError struct {
+	Reason    RiskReason
+	Symbol    string
+	Side      string
+	Requested float64
+	Limit     float64
+}
+
+func (e *RiskError) Error() string {
+	return fmt.Sprintf("%s: %s %s %s requested %g, limit %g", ErrRiskCheckFailed, e.Reason, e.Side, e.Symbol, e.Requested, e.Limit)
+}
+
+func (e *RiskError) Unwrap() error {
+	return ErrRiskCheckFailed
+}
+
+// SymbolRiskLimits are our own limits on top of the ones returned by the server,
+// a zero value means the limit is not enforced
+type SymbolRiskLimits struct {
+	MaxNotional  float64 `json:"maxNotional" yaml:"maxNotional" toml:"maxNotional"`
+	MaxOrderSize float64 `json:"maxOrderSize" yaml:"maxOrderSize" toml:"maxOrderSize"`
+}
+
+type RiskConfig struct {
+	// Symbols is keyed by "ASSET/COUNTER_ASSET" e.g. "BTC/USD", in any case
+	Symbols map[string]SymbolRiskLimits `json:"symbols" yaml:"symbols" toml:"symbols"`
+	// RejectUnknownAssets rejects orders for assets missing from ListLimitsBalances
+	RejectUnknownAssets bool `json:"rejectUnknownAssets" yaml:"rejectUnknownAssets" toml:"rejectUnknownAssets"`
+	// DisableLiveRefresh skips the LIMIT_CHANGED, ORDER_FILLED and ORDER_CANCELLED
+	// subscriptions, cached limits are then only refreshed after fills of our own
+	// orders seen in placement responses or through TrackOrderUpdates
+	DisableLiveRefresh bool `json:"disableLiveRefresh" yaml:"disableLiveRefresh" toml:"disableLiveRefresh"`
+}
+
+type riskManager struct {
+	cfg RiskConfig
+	// symbols holds cfg.Symbols keyed in upper case
+	symbols map[string]SymbolRiskLimits
+
+	mu     sync.RWMutex
+	limits map[string]Asset
+	// refreshes counts the refreshes started, fills are released by a later one
+	refreshes    uint64
+	reservations map[*reservation]bool
+	// resting holds the reservations of open orders by order ID, early the orders
+	// resolved while placements were in flight, before their reservation was known
+	resting  map[string]*reservation
+	early    map[string]bool
+	inFlight int
+	// stale is set once live refresh was lost, orders are rejected then
+	stale error
+
+	refreshMu sync.Mutex
+	stopFuncs []func() error
+}
+
+// reservation holds the quantity of an order from its risk check until the order
+// resolves, so concurrent orders can't each pass the same cached limit. A filled
+// order keeps it until the limits are refreshed after the fill.
+type reservation struct {
+	rm        *riskManager
+	asset     string
+	side      string
+	qty       float64
+	filled    bool
+	filledGen uint64
+}
+
+func newRiskManager(cfg RiskConfig) *riskManager {
+	symbols := make(map[string]SymbolRiskLimits, len(cfg.Symbols))
+	for symbol, limits := range cfg.Symbols {
+		symbols[strings.ToUpper(symbol)] = limits
+	}
+	return &riskManager{
+		cfg:          cfg,
+		symbols:      symbols,
+		limits:       make(map[string]Asset),
+		reservations: make(map[*reservation]bool),
+		resting:      make(map[string]*reservation),
+		early:        make(map[string]bool),
+	}
+}
+
+// EnableRiskChecks seeds the limits cache from ListLimitsBalances and starts
+// rejecting orders locally which would breach them or the configured limits.
+// The quantity of an order counts against the limits from its check until it
+// is rejected, cancelled, or filled and the limits refreshed. When the live
+// refresh subscriptions can't be opened again after a drop, orders are rejected
+// with RiskReasonStaleLimits until EnableRiskChecks is called again.
+func (dvotc *DVOTCClient) EnableRiskChecks(cfg RiskConfig) error {
+	rm := newRiskManager(cfg)
+	if err := rm.refresh(dvotc); err != nil {
+		return err
+	}
+	if !cfg.DisableLiveRefresh {
+		if err := rm.watch(dvotc); err != nil {
+			rm.stop()
+			return err
+		}
+	}
+
+	if old := dvotc.risk.Swap(rm); old != nil {
+		old.stop()
+	}
+	return nil
+}
+
+func (dvotc *DVOTCClient) DisableRiskChecks() {
+	if old := dvotc.risk.Swap(nil); old != nil {
+		old.stop()
+	}
+}
+
+// RefreshRiskLimits forces a reload of the cached limits
+func (dvotc *DVOTCClient) RefreshRiskLimits() error {
+	rm := dvotc.risk.Load()
+	if rm == nil {
+		return nil
+	}
+	return rm.refresh(dvotc)
+}
+
+// RiskLimits returns a copy of the cached limits keyed by asset
+func (dvotc *DVOTCClient) RiskLimits() map[string]Asset {
+	rm := dvotc.risk.Load()
+	if rm == nil {
+		return nil
+	}
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	limits := make(map[string]Asset, len(rm.limits))
+	for k, v := range rm.limits {
+		limits[k] = v
+	}
+	return limits
+}
+
+func (dvotc *DVOTCClient) checkRisk(asset, counterAsset, side string, qty, price float64) (*reservation, error) {
+	rm := dvotc.risk.Load()
+	if rm == nil {
+		return nil, nil
+	}
+	return rm.check(asset, counterAsset, side, qty, price)
+}
+
+// refreshRiskAfterOrder reloads the limits in the background once an order filled
+func (dvotc *DVOTCClient) refreshRiskAfterOrder(status *OrderStatus) {
+	rm := dvotc.risk.Load()
+	if rm == nil || status == nil || !status.IsFilled() {
+		return
+	}
+	go func() {
+		if err := rm.refresh(dvotc); err != nil {
+			log.Println(err)
+		}
+	}()
+}
+
+// riskOrderResolved releases the reservation of an order no longer open, seen
+// by the order tracker, and refreshes the limits after a fill
+func (dvotc *DVOTCClient) riskOrderResolved(status OrderStatus) {
+	rm := dvotc.risk.Load()
+	if rm == nil || !rm.resolve(status.ID, status.IsFilled()) {
+		return
+	}
+	dvotc.refreshRiskAfterOrder(&status)
+}
+
+// placed resolves the reservation r of an order answered with status, or failed with err
+func (r *reservation) placed(status *OrderStatus, err error) {
+	if r == nil {
+		return
+	}
+	rm := r.rm
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.inFlight--
+	defer func() {
+		if rm.inFlight == 0 {
+			rm.early = make(map[string]bool)
+		}
+	}()
+
+	switch {
+	case err != nil || status == nil:
+		delete(rm.reservations, r)
+	case status.IsFilled():
+		r.filled, r.filledGen = true, rm.refreshes
+	case status.IsOpen():
+		if filled, ok := rm.early[status.ID]; ok {
+			if filled {
+				r.filled, r.filledGen = true, rm.refreshes
+				return
+			}
+			delete(rm.reservations, r)
+			return
+		}
+		rm.resting[status.ID] = r
+	default:
+		delete(rm.reservations, r)
+	}
+}
+
+// resolve releases the reservation of the open order orderID, a filled one is kept
+// until the next refresh, it reports whether the order had a reservation
+func (rm *riskManager) resolve(orderID string, filled bool) bool {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	r, ok := rm.resting[orderID]
+	if !ok {
+		if rm.inFlight > 0 {
+			// the order may be one whose placement is not answered yet
+			rm.early[orderID] = filled
+		}
+		return false
+	}
+	delete(rm.resting, orderID)
+	if filled {
+		r.filled, r.filledGen = true, rm.refreshes
+		return true
+	}
+	delete(rm.reservations, r)
+	return true
+}
+
+func (rm *riskManager) refresh(dvotc *DVOTCClient) error {
+	// only one refresh in flight, notifications can come in bursts
+	rm.refreshMu.Lock()
+	defer rm.refreshMu.Unlock()
+
+	rm.mu.Lock()
+	rm.refreshes++
+	gen := rm.refreshes
+	rm.mu.Unlock()
+
+	balances, err := dvotc.ListLimitsBalances()
+	if err != nil {
+		return err
+	}
+
+	limits := make(map[string]Asset, len(balances.Assets))
+	for _, a := range balances.Assets {
+		limits[strings.ToUpper(a.Asset)] = a
+	}
+
+	rm.mu.Lock()
+	rm.limits = limits
+	for r := range rm.reservations {
+		// the fill happened before this refresh started, the limits include it
+		if r.filled && r.filledGen < gen {
+			delete(rm.reservations, r)
+		}
+	}
+	rm.mu.Unlock()
+	return nil
+}
+
+func (rm *riskManager) watch(dvotc *DVOTCClient) error {
+	limits := &riskWatch[LimitChangedNotification]{subscribe: dvotc.SubscribeLimitChanged}
+	if err := limits.start(rm, dvotc, nil); err != nil {
+		return err
+	}
+	rm.stopFuncs = append(rm.stopFuncs, limits.stop)
+
+	filled := &riskWatch[OrderNotification]{subscribe: dvotc.SubscribeOrderFilled}
+	if err := filled.start(rm, dvotc, func(n OrderNotification) bool {
+		rm.resolve(n.ID, true)
+		return true
+	}); err != nil {
+		return err
+	}
+	rm.stopFuncs = append(rm.stopFuncs, filled.stop)
+
+	cancelled := &riskWatch[OrderNotification]{subscribe: dvotc.SubscribeOrderCancelled}
+	if err := cancelled.start(rm, dvotc, func(n OrderNotification) bool {
+		rm.resolve(n.ID, false)
+		return false
+	}); err != nil {
+		return err
+	}
+	rm.stopFuncs = append(rm.stopFuncs, cancelled.stop)
+	return nil
+}
+
+// riskWatch keeps a notification subscription feeding the risk manager, subscribing
+// again when its connection drops
+type riskWatch[T any] struct {
+	subscribe func() (*Subscription[T], error)
+
+	mu      sync.Mutex
+	sub     *Subscription[T]
+	stopped bool
+}
+
+// start subscribes and handles the notifications in the background, handle reports
+// whether the limits must be refreshed, a nil handle refreshes on every notification
+func (w *riskWatch[T]) start(rm *riskManager, dvotc *DVOTCClient, handle func(T) bool) error {
+	sub, err := w.subscribe()
+	if err != nil {
+		return err
+	}
+	w.sub = sub
+	go w.run(rm, dvotc, sub, handle)
+	return nil
+}
+
+// run drains sub until it ends with its connection, then subscribes again and refreshes
+// the limits, which may have changed in between. When that fails the risk manager is
+// marked stale and rejects orders until risk checks are enabled again.
+func (w *riskWatch[T]) run(rm *riskManager, dvotc *DVOTCClient, sub *Subscription[T], handle func(T) bool) {
+	for {
+		for n := range sub.Data {
+			if handle != nil && !handle(n) {
+				continue
+			}
+			if err := rm.refresh(dvotc); err != nil {
+				log.Println(err)
+			}
+		}
+
+		w.mu.Lock()
+		stopped := w.stopped
+		w.mu.Unlock()
+		if stopped {
+			return
+		}
+
+		var next *Subscription[T]
+		err := retry.Do(func() (err error) {
+			next, err = w.subscribe()
+			return err
+		},
+			retry.Attempts(uint(dvotc.connectAttempts.Load())),
+			retry.Delay(time.Duration(dvotc.connectDelay.Load())))
+
+		w.mu.Lock()
+		if w.stopped {
+			w.mu.Unlock()
+			if next != nil {
+				next.StopConsuming()
+			}
+			return
+		}
+		if err != nil {
+			w.mu.Unlock()
+			log.Println("risk limits refresh lost:", err)
+			rm.setStale(err)
+			return
+		}
+		w.sub = next
+		w.mu.Unlock()
+
+		sub = next
+		if err := rm.refresh(dvotc); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (w *riskWatch[T]) stop() error {
+	w.mu.Lock()
+	w.stopped = true
+	sub := w.sub
+	w.mu.Unlock()
+	return sub.StopConsuming()
+}
+
+func (rm *riskManager) setStale(err error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.stale = err
+}
+
+func (rm *riskManager) stop() {
+	for _, stop := range rm.stopFuncs {
+		if err := stop(); err != nil && !errors.Is(err, ErrSubscriptionAlreadyClosed) {
+			log.Println(err)
+		}
+	}
+	rm.stopFuncs = nil
+}
+
+func (rm *riskManager) check(asset, counterAsset, side string, qty, price float64) (*reservation, error) {
+	symbol := fmt.Sprintf("%s/%s", asset, counterAsset)
+	newErr := func(reason RiskReason, requested, limit float64) error {
+		return &RiskError{
+			Reason:    reason,
+			Symbol:    symbol,
+			Side:      side,
+			Requested: requested,
+			Limit:     limit,
+		}
+	}
+
+	if symbolLimits, ok := rm.symbols[strings.ToUpper(symbol)]; ok {
+		if symbolLimits.MaxOrderSize > 0 && qty > symbolLimits.MaxOrderSize {
+			return nil, newErr(RiskReasonMaxOrderSize, qty, symbolLimits.MaxOrderSize)
+		}
+		// market orders can be sent without price, their notional can't be checked
+		if symbolLimits.MaxNotional > 0 && price <= 0 {
+			return nil, newErr(RiskReasonNoPrice, qty, symbolLimits.MaxNotional)
+		}
+		notional := qty * price
+		if symbolLimits.MaxNotional > 0 && notional > symbolLimits.MaxNotional {
+			return nil, newErr(RiskReasonMaxNotional, notional, symbolLimits.MaxNotional)
+		}
+	}
+
+	// the limits are checked and the quantity reserved at once
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if rm.stale != nil {
+		return nil, newErr(RiskReasonStaleLimits, qty, 0)
+	}
+	asset = strings.ToUpper(asset)
+	side = strings.ToLower(side)
+	limits, ok := rm.limits[asset]
+	if !ok {
+		if rm.cfg.RejectUnknownAssets {
+			return nil, newErr(RiskReasonUnknownAsset, qty, 0)
+		}
+		return nil, nil
+	}
+
+	reserved := 0.0
+	for r := range rm.reservations {
+		if r.asset == asset && r.side == side {
+			reserved += r.qty
+		}
+	}
+	switch side {
+	case "buy":
+		if qty > limits.MaxBuy-reserved {
+			return nil, newErr(RiskReasonMaxBuy, qty, limits.MaxBuy-reserved)
+		}
+	case "sell":
+		if qty > limits.MaxSell-reserved {
+			return nil, newErr(RiskReasonMaxSell, qty, limits.MaxSell-reserved)
+		}
+	default:
+		return nil, nil
+	}
+	r := &reservation{rm: rm, asset: asset, side: side, qty: qty}
+	rm.reservations[r] = true
+	rm.inFlight++
+	return r, nil
+}
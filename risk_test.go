@@ -0,0 +1,310 @@
+package dvotcWS_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/dvotctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRiskClient(t *testing.T, cfg dvotcWS.RiskConfig) *dvotcWS.DVOTCClient {
+	data := dvotcWS.AssetBalance{
+		Assets: []dvotcWS.Asset{
+			{Asset: "BTC", MaxBuy: 10, MaxSell: 5, Position: 2},
+			{Asset: "ETH", MaxBuy: 100, MaxSell: 100},
+		},
+		UsdBalance: 100000,
+	}
+	dataBytes, err := json.Marshal(data)
+	require.NoError(t, err)
+	respBytes, err := json.Marshal(dvotcWS.Payload{
+		Type:  "request-response",
+		Topic: "limits",
+		Event: "10",
+		Data:  dataBytes,
+	})
+	require.NoError(t, err)
+
+	url := setupTestWebsocketServer(
+		&echoWebsocketServer{
+			t:        t,
+			request:  []byte(`{"type": "request-response", "topic": "limits", "event": "10"}`),
+			response: [][]byte{respBytes},
+		},
+	)
+
+	client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+	require.NoError(t, client.EnableRiskChecks(cfg))
+	return client
+}
+
+func TestRiskChecks(t *testing.T) {
+	t.Run("limits_cached", func(t *testing.T) {
+		client := setupRiskClient(t, dvotcWS.RiskConfig{DisableLiveRefresh: true})
+		limits := client.RiskLimits()
+		require.Len(t, limits, 2)
+		assert.Equal(t, 10.0, limits["BTC"].MaxBuy)
+		assert.Equal(t, 5.0, limits["BTC"].MaxSell)
+	})
+
+	t.Run("max_buy_exceeded", func(t *testing.T) {
+		client := setupRiskClient(t, dvotcWS.RiskConfig{DisableLiveRefresh: true})
+		resp, err := client.PlaceMarketOrder(dvotcWS.MarketOrderParams{
+			Asset:        "BTC",
+			CounterAsset: "USD",
+			Qty:          11,
+			Price:        20000,
+			Side:         "Buy",
+		})
+		require.Nil(t, resp)
+		require.ErrorIs(t, err, dvotcWS.ErrRiskCheckFailed)
+
+		var riskErr *dvotcWS.RiskError
+		require.True(t, errors.As(err, &riskErr))
+		assert.Equal(t, dvotcWS.RiskReasonMaxBuy, riskErr.Reason)
+		assert.Equal(t, "BTC/USD", riskErr.Symbol)
+		assert.Equal(t, 11.0, riskErr.Requested)
+		assert.Equal(t, 10.0, riskErr.Limit)
+	})
+
+	t.Run("max_sell_exceeded", func(t *testing.T) {
+		client := setupRiskClient(t, dvotcWS.RiskConfig{DisableLiveRefresh: true})
+		_, err := client.PlaceLimitOrder(dvotcWS.LimitOrderParams{
+			Asset:        "BTC",
+			CounterAsset: "USD",
+			Qty:          6,
+			LimitPrice:   20000,
+			Side:         "Sell",
+		})
+		var riskErr *dvotcWS.RiskError
+		require.True(t, errors.As(err, &riskErr))
+		assert.Equal(t, dvotcWS.RiskReasonMaxSell, riskErr.Reason)
+	})
+
+	t.Run("max_order_size_exceeded", func(t *testing.T) {
+		client := setupRiskClient(t, dvotcWS.RiskConfig{
+			DisableLiveRefresh: true,
+			Symbols: map[string]dvotcWS.SymbolRiskLimits{
+				"ETH/USD": {MaxOrderSize: 20},
+			},
+		})
+		_, err := client.PlaceLimitOrder(dvotcWS.LimitOrderParams{
+			Asset:        "ETH",
+			CounterAsset: "USD",
+			Qty:          21,
+			LimitPrice:   1000,
+			Side:         "Buy",
+		})
+		var riskErr *dvotcWS.RiskError
+		require.True(t, errors.As(err, &riskErr))
+		assert.Equal(t, dvotcWS.RiskReasonMaxOrderSize, riskErr.Reason)
+		assert.Equal(t, 20.0, riskErr.Limit)
+	})
+
+	t.Run("max_notional_exceeded", func(t *testing.T) {
+		client := setupRiskClient(t, dvotcWS.RiskConfig{
+			DisableLiveRefresh: true,
+			Symbols: map[string]dvotcWS.SymbolRiskLimits{
+				"ETH/USD": {MaxNotional: 10000},
+			},
+		})
+		_, err := client.PlaceMarketOrder(dvotcWS.MarketOrderParams{
+			Asset:        "ETH",
+			CounterAsset: "USD",
+			Qty:          11,
+			Price:        1000,
+			Side:         "Buy",
+		})
+		var riskErr *dvotcWS.RiskError
+		require.True(t, errors.As(err, &riskErr))
+		assert.Equal(t, dvotcWS.RiskReasonMaxNotional, riskErr.Reason)
+		assert.Equal(t, 11000.0, riskErr.Requested)
+	})
+
+	t.Run("no_price_with_notional_limit", func(t *testing.T) {
+		client := setupRiskClient(t, dvotcWS.RiskConfig{
+			DisableLiveRefresh: true,
+			Symbols: map[string]dvotcWS.SymbolRiskLimits{
+				"eth/usd": {MaxNotional: 10000},
+			},
+		})
+		_, err := client.PlaceMarketOrder(dvotcWS.MarketOrderParams{
+			Asset:        "ETH",
+			CounterAsset: "USD",
+			Qty:          1000,
+			Side:         "Buy",
+		})
+		var riskErr *dvotcWS.RiskError
+		require.True(t, errors.As(err, &riskErr))
+		assert.Equal(t, dvotcWS.RiskReasonNoPrice, riskErr.Reason)
+		assert.Equal(t, 10000.0, riskErr.Limit)
+	})
+
+	t.Run("symbol_limits_any_case", func(t *testing.T) {
+		client := setupRiskClient(t, dvotcWS.RiskConfig{
+			DisableLiveRefresh: true,
+			Symbols: map[string]dvotcWS.SymbolRiskLimits{
+				"btc/usd": {MaxOrderSize: 1},
+			},
+		})
+		_, err := client.PlaceLimitOrder(dvotcWS.LimitOrderParams{
+			Asset:        "BTC",
+			CounterAsset: "USD",
+			Qty:          2,
+			LimitPrice:   20000,
+			Side:         "Buy",
+		})
+		var riskErr *dvotcWS.RiskError
+		require.True(t, errors.As(err, &riskErr))
+		assert.Equal(t, dvotcWS.RiskReasonMaxOrderSize, riskErr.Reason)
+	})
+
+	t.Run("unknown_asset_rejected", func(t *testing.T) {
+		client := setupRiskClient(t, dvotcWS.RiskConfig{
+			DisableLiveRefresh:  true,
+			RejectUnknownAssets: true,
+		})
+		_, err := client.PlaceMarketOrder(dvotcWS.MarketOrderParams{
+			Asset:        "DOGE",
+			CounterAsset: "USD",
+			Qty:          1,
+			Side:         "Buy",
+		})
+		var riskErr *dvotcWS.RiskError
+		require.True(t, errors.As(err, &riskErr))
+		assert.Equal(t, dvotcWS.RiskReasonUnknownAsset, riskErr.Reason)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		client := setupRiskClient(t, dvotcWS.RiskConfig{DisableLiveRefresh: true})
+		client.DisableRiskChecks()
+		assert.Nil(t, client.RiskLimits())
+		assert.NoError(t, client.RefreshRiskLimits())
+	})
+}
+
+func setupRiskServer(t *testing.T) *dvotctest.Server {
+	srv := dvotctest.NewServer(dvotctest.Config{
+		Balances: dvotcWS.AssetBalance{
+			Assets: []dvotcWS.Asset{{Asset: "BTC", MaxBuy: 10, MaxSell: 5}},
+		},
+	})
+	t.Cleanup(srv.Close)
+	srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 20010, SellPrice: 19990, MaxQuantity: 10})
+	return srv
+}
+
+func bid(qty float64) dvotcWS.LimitOrderParams {
+	return dvotcWS.LimitOrderParams{Asset: "BTC", CounterAsset: "USD", Qty: qty, LimitPrice: 19000, Side: "Buy"}
+}
+
+func TestRiskReservations(t *testing.T) {
+	t.Run("in_flight_and_resting", func(t *testing.T) {
+		srv := setupRiskServer(t)
+		client := srv.Client()
+		require.NoError(t, client.EnableRiskChecks(dvotcWS.RiskConfig{DisableLiveRefresh: true}))
+
+		// the first order is not answered yet, its quantity is already taken
+		srv.InjectFault("createorder", dvotctest.Fault{Delay: 200 * time.Millisecond})
+		placed := make(chan *dvotcWS.OrderStatus, 1)
+		go func() {
+			order, err := client.PlaceLimitOrder(bid(6))
+			assert.NoError(t, err)
+			placed <- order
+		}()
+		require.Eventually(t, func() bool { return len(srv.Requests("createorder")) == 1 }, time.Second, 5*time.Millisecond)
+		_, err := client.PlaceLimitOrder(bid(6))
+		var riskErr *dvotcWS.RiskError
+		require.True(t, errors.As(err, &riskErr))
+		assert.Equal(t, dvotcWS.RiskReasonMaxBuy, riskErr.Reason)
+		assert.Equal(t, 4.0, riskErr.Limit)
+
+		// resting, it keeps its quantity until cancelled
+		first := <-placed
+		require.True(t, first.IsOpen())
+		_, err = client.PlaceLimitOrder(bid(6))
+		require.ErrorIs(t, err, dvotcWS.ErrRiskCheckFailed)
+		_, err = client.PlaceLimitOrder(bid(4))
+		require.NoError(t, err)
+
+		require.NoError(t, client.CancelOrder(first.ID))
+		_, err = client.PlaceLimitOrder(bid(6))
+		require.NoError(t, err)
+	})
+
+	t.Run("released_by_the_fill", func(t *testing.T) {
+		srv := setupRiskServer(t)
+		client := srv.Client()
+		require.NoError(t, client.EnableRiskChecks(dvotcWS.RiskConfig{}))
+		refreshes := len(srv.Requests("limits"))
+
+		order, err := client.PlaceLimitOrder(bid(6))
+		require.NoError(t, err)
+		require.True(t, order.IsOpen())
+
+		// the order fills later, the limits are refreshed and the quantity released
+		srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 18990, SellPrice: 18970, MaxQuantity: 10})
+		require.Eventually(t, func() bool { return len(srv.Requests("limits")) > refreshes }, time.Second, 5*time.Millisecond)
+		require.Eventually(t, func() bool {
+			_, err := client.PlaceLimitOrder(bid(6))
+			return err == nil
+		}, time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("released_by_tracked_fill", func(t *testing.T) {
+		srv := setupRiskServer(t)
+		client := srv.Client()
+		require.NoError(t, client.EnableRiskChecks(dvotcWS.RiskConfig{DisableLiveRefresh: true}))
+		require.NoError(t, client.TrackOrderUpdates())
+		defer client.StopTrackingOrderUpdates()
+		refreshes := len(srv.Requests("limits"))
+
+		_, err := client.PlaceLimitOrder(bid(6))
+		require.NoError(t, err)
+		srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 18990, SellPrice: 18970, MaxQuantity: 10})
+		require.Eventually(t, func() bool { return len(srv.Requests("limits")) > refreshes }, time.Second, 5*time.Millisecond)
+		require.Eventually(t, func() bool {
+			_, err := client.PlaceLimitOrder(bid(6))
+			return err == nil
+		}, time.Second, 20*time.Millisecond)
+	})
+}
+
+func TestRiskLiveRefreshResubscribed(t *testing.T) {
+	srv := setupRiskServer(t)
+	client := srv.Client()
+	require.NoError(t, client.EnableRiskChecks(dvotcWS.RiskConfig{}))
+	defer client.DisableRiskChecks()
+	refreshes := len(srv.Requests("limits"))
+
+	assert.Equal(t, 1, srv.DropSubscribers(dvotcWS.NOTIFICATION_LIMIT_CHANGED, "notifications"))
+	require.Eventually(t, func() bool {
+		return srv.Subscribers(dvotcWS.NOTIFICATION_LIMIT_CHANGED, "notifications") == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	// the limits may have changed while the subscription was down
+	require.Eventually(t, func() bool { return len(srv.Requests("limits")) == refreshes+1 }, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, srv.Notify(dvotcWS.NOTIFICATION_LIMIT_CHANGED, dvotcWS.LimitChangedNotification{}))
+	require.Eventually(t, func() bool { return len(srv.Requests("limits")) == refreshes+2 }, time.Second, 5*time.Millisecond)
+}
+
+func TestRiskLiveRefreshLost(t *testing.T) {
+	srv := setupRiskServer(t)
+	client := srv.Client()
+	client.SetConnectRetries(1, 10*time.Millisecond)
+	require.NoError(t, client.EnableRiskChecks(dvotcWS.RiskConfig{}))
+	defer client.DisableRiskChecks()
+
+	// the limits can't be kept current, orders are rejected rather than checked against them
+	srv.Close()
+	require.Eventually(t, func() bool {
+		_, err := client.PlaceLimitOrder(bid(1))
+		var riskErr *dvotcWS.RiskError
+		return errors.As(err, &riskErr) && riskErr.Reason == dvotcWS.RiskReasonStaleLimits
+	}, 2*time.Second, 20*time.Millisecond)
+}
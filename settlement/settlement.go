@@ -0,0 +1,543 @@
+// Package settlement follows settlement batches of a DVOTC account from creation
+// to settlement and flags the ones that are late or don't settle the expected quantities
+package settlement
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+)
+
+const (
+	defaultSettleWithin = 24 * time.Hour
+	defaultTolerance    = 1e-8
+)
+
+type Stage string
+
+const (
+	// StageTrading is a batch only known from its trades
+	StageTrading         Stage = "trading"
+	StageCreated         Stage = "created"
+	StageSettlementAdded Stage = "settlement-added"
+	StageSettled         Stage = "settled"
+)
+
+// NotificationSource is satisfied by *dvotcWS.DVOTCClient
+type NotificationSource interface {
+	SubscribeBatchCreated() (*dvotcWS.Subscription[dvotcWS.BatchCreatedNotification], error)
+	SubscribeSettlementAdded() (*dvotcWS.Subscription[dvotcWS.SettlementAddedNotification], error)
+	SubscribeBatchSettled() (*dvotcWS.Subscription[dvotcWS.BatchSettledNotification], error)
+}
+
+type Config struct {
+	// SettleWithin is how long a batch may stay unsettled after creation before
+	// it is flagged overdue, defaults to 24 hours
+	SettleWithin time.Duration
+	// Tolerance is the largest difference between expected and received
+	// quantities not flagged as a mismatch, defaults to 1e-8
+	Tolerance float64
+	// Now defaults to time.Now and is mostly useful in tests
+	Now func() time.Time
+}
+
+type Mismatch struct {
+	Asset    string
+	Expected float64
+	Received float64
+}
+
+type BatchStatus struct {
+	ID        string
+	Stage     Stage
+	CreatedAt time.Time
+	SettledAt *time.Time
+	TradeIDs  []string
+	// Expected is the net quantity per asset of the trades of the batch, the net
+	// quantities BATCH_CREATED announces per pair take precedence for their base
+	// assets. Without trades the counter assets are not known and not compared.
+	Expected map[string]float64
+	// Received is the net quantity per asset of the settlements added so far
+	Received   map[string]float64
+	Overdue    bool
+	Mismatches []Mismatch
+}
+
+// Flagged reports if the batch is overdue or settled with mismatched quantities
+func (b BatchStatus) Flagged() bool {
+	return b.Overdue || len(b.Mismatches) > 0
+}
+
+type batch struct {
+	id        string
+	stage     Stage
+	createdAt time.Time
+	settledAt *time.Time
+	trades    map[string]dvotcWS.Trade
+	// announced is the net base quantity per pair of BATCH_CREATED
+	announced map[string]float64
+	received  map[string]float64
+	// notified is set once a notification is seen for the batch, the others are
+	// only known from the batch IDs of their trades
+	notified bool
+	// settlements keeps the settlements already added so redelivered
+	// notifications are not counted twice
+	settlements map[int64]settlementQty
+}
+
+type settlementQty struct {
+	asset string
+	qty   float64
+}
+
+// Tracker groups trades into their settlement batches and follows each batch
+// through BATCH_CREATED, SETTLEMENT_ADDED and BATCH_SETTLED notifications.
+// Trades are tied to the batch of notifications whose announced net quantities
+// they add up to.
+type Tracker struct {
+	cfg Config
+
+	mu      sync.Mutex
+	batches map[string]*batch
+	// aliases maps numeric batch IDs and linked trade batch IDs to the batch UUID
+	aliases map[string]string
+}
+
+func NewTracker(cfg Config) *Tracker {
+	if cfg.SettleWithin <= 0 {
+		cfg.SettleWithin = defaultSettleWithin
+	}
+	if cfg.Tolerance <= 0 {
+		cfg.Tolerance = defaultTolerance
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &Tracker{
+		cfg:     cfg,
+		batches: make(map[string]*batch),
+		aliases: make(map[string]string),
+	}
+}
+
+// AddTrades assigns trades to their batch, usually with the result of ListTrades,
+// trades not in a batch yet are ignored. Trades are grouped under their Batch.ID,
+// which is not the batch UUID of notifications, until their net quantities per
+// pair match the ones announced by a single BATCH_CREATED or the IDs are linked
+// with Link
+func (t *Tracker) AddTrades(trades []dvotcWS.Trade) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	added := make(map[*batch]struct{})
+	defer func() {
+		for b := range added {
+			t.tie(b)
+		}
+	}()
+	for _, trade := range trades {
+		if trade.Batch.ID == "" {
+			continue
+		}
+		b := t.batch(t.resolve(trade.Batch.ID))
+		added[b] = struct{}{}
+		b.trades[trade.ID] = trade
+		if !trade.FilledAt.IsZero() && b.stage == StageTrading && (b.createdAt.IsZero() || trade.FilledAt.Before(b.createdAt)) {
+			b.createdAt = trade.FilledAt
+		}
+		if trade.Batch.Settled && b.stage != StageSettled {
+			b.stage = StageSettled
+		}
+	}
+}
+
+// Link merges the batch known by the trade batch ID tradeBatchID into the batch
+// batchUUID of notifications, trades added later with that batch ID join it too.
+// It ties batches whose trades don't add up to a single announced batch.
+func (t *Tracker) Link(tradeBatchID, batchUUID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.alias(tradeBatchID, batchUUID)
+}
+
+func (t *Tracker) BatchCreated(n dvotcWS.BatchCreatedNotification) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := t.notified(n)
+	if b.stage == StageTrading {
+		b.stage = StageCreated
+		b.createdAt = t.cfg.Now()
+	}
+	if len(n.BatchDetails) > 0 {
+		b.announced = make(map[string]float64)
+		for _, detail := range n.BatchDetails {
+			qty, _ := strconv.ParseFloat(detail.NetQuantity.String(), 64)
+			b.announced[detail.Symbol] += qty
+		}
+		t.tie(b)
+	}
+}
+
+func (t *Tracker) SettlementAdded(n dvotcWS.SettlementAddedNotification) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := t.notified(n)
+	if b.stage == StageTrading || b.stage == StageCreated {
+		if b.createdAt.IsZero() {
+			b.createdAt = t.cfg.Now()
+		}
+		b.stage = StageSettlementAdded
+	}
+	if n.Info == nil {
+		return
+	}
+	if _, ok := b.settlements[n.Info.ID]; ok && n.Info.ID != 0 {
+		return
+	}
+	qty, _ := strconv.ParseFloat(n.Info.NetQuantity.String(), 64)
+	b.settlements[n.Info.ID] = settlementQty{asset: n.Info.Asset, qty: qty}
+	b.received[n.Info.Asset] += qty
+}
+
+func (t *Tracker) BatchSettled(n dvotcWS.BatchSettledNotification) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := t.notified(n)
+	now := t.cfg.Now()
+	if b.createdAt.IsZero() {
+		b.createdAt = now
+	}
+	b.stage = StageSettled
+	b.settledAt = &now
+}
+
+// Watch follows the notification channels until all are closed or ctx is done,
+// any of them may be nil
+func (t *Tracker) Watch(ctx context.Context, created, added, settled <-chan dvotcWS.BatchNotificaiton) error {
+	for created != nil || added != nil || settled != nil {
+		select {
+		case n, ok := <-created:
+			if !ok {
+				created = nil
+				continue
+			}
+			t.BatchCreated(n)
+		case n, ok := <-added:
+			if !ok {
+				added = nil
+				continue
+			}
+			t.SettlementAdded(n)
+		case n, ok := <-settled:
+			if !ok {
+				settled = nil
+				continue
+			}
+			t.BatchSettled(n)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Run subscribes to the three batch notifications of src and watches them until ctx is done
+func (t *Tracker) Run(ctx context.Context, src NotificationSource) error {
+	created, err := src.SubscribeBatchCreated()
+	if err != nil {
+		return err
+	}
+	defer created.StopConsuming()
+
+	added, err := src.SubscribeSettlementAdded()
+	if err != nil {
+		return err
+	}
+	defer added.StopConsuming()
+
+	settled, err := src.SubscribeBatchSettled()
+	if err != nil {
+		return err
+	}
+	defer settled.StopConsuming()
+
+	return t.Watch(ctx, created.Data, added.Data, settled.Data)
+}
+
+// Batch returns the status of the batch id, its UUID or any ID linked to it
+func (t *Tracker) Batch(id string) (BatchStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b, ok := t.batches[t.resolve(id)]
+	if !ok {
+		return BatchStatus{}, false
+	}
+	return t.status(b), true
+}
+
+// Batches returns the status of every batch, oldest first
+func (t *Tracker) Batches() []BatchStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	batches := make([]BatchStatus, 0, len(t.batches))
+	for _, b := range t.batches {
+		batches = append(batches, t.status(b))
+	}
+	sort.Slice(batches, func(i, j int) bool {
+		if batches[i].CreatedAt.Equal(batches[j].CreatedAt) {
+			return batches[i].ID < batches[j].ID
+		}
+		return batches[i].CreatedAt.Before(batches[j].CreatedAt)
+	})
+	return batches
+}
+
+// Flagged returns the batches that are overdue or mismatched
+func (t *Tracker) Flagged() []BatchStatus {
+	flagged := make([]BatchStatus, 0)
+	for _, b := range t.Batches() {
+		if b.Flagged() {
+			flagged = append(flagged, b)
+		}
+	}
+	return flagged
+}
+
+// batch returns the batch id creating it if needed, callers hold t.mu
+func (t *Tracker) batch(id string) *batch {
+	b, ok := t.batches[id]
+	if !ok {
+		b = &batch{
+			id:          id,
+			stage:       StageTrading,
+			trades:      make(map[string]dvotcWS.Trade),
+			received:    make(map[string]float64),
+			settlements: make(map[int64]settlementQty),
+		}
+		t.batches[id] = b
+	}
+	return b
+}
+
+func (t *Tracker) status(b *batch) BatchStatus {
+	status := BatchStatus{
+		ID:        b.id,
+		Stage:     b.stage,
+		CreatedAt: b.createdAt,
+		SettledAt: b.settledAt,
+		TradeIDs:  make([]string, 0, len(b.trades)),
+		Expected:  b.expected(),
+		Received:  make(map[string]float64, len(b.received)),
+	}
+	for id := range b.trades {
+		status.TradeIDs = append(status.TradeIDs, id)
+	}
+	sort.Strings(status.TradeIDs)
+	for asset, qty := range b.received {
+		status.Received[asset] = qty
+	}
+
+	if b.stage != StageSettled {
+		status.Overdue = !b.createdAt.IsZero() && t.cfg.Now().Sub(b.createdAt) > t.cfg.SettleWithin
+		return status
+	}
+	// settled batches known only from trades have no settlements to compare
+	if len(b.settlements) == 0 && b.announced == nil {
+		return status
+	}
+	assets := make(map[string]struct{})
+	for asset := range status.Expected {
+		assets[asset] = struct{}{}
+	}
+	if len(b.trades) > 0 || b.announced == nil {
+		for asset := range status.Received {
+			assets[asset] = struct{}{}
+		}
+	}
+	for asset := range assets {
+		expected, received := status.Expected[asset], status.Received[asset]
+		if math.Abs(expected-received) > t.cfg.Tolerance {
+			status.Mismatches = append(status.Mismatches, Mismatch{Asset: asset, Expected: expected, Received: received})
+		}
+	}
+	sort.Slice(status.Mismatches, func(i, j int) bool {
+		return status.Mismatches[i].Asset < status.Mismatches[j].Asset
+	})
+	return status
+}
+
+// expected returns the net quantities of the trades, with the announced ones for
+// the base assets. Buying an asset receives it and delivers its counter asset.
+func (b *batch) expected() map[string]float64 {
+	expected := make(map[string]float64)
+	for _, trade := range b.trades {
+		qty := tradeQty(trade)
+		expected[trade.Asset] += qty
+		expected[trade.CounterAsset] -= qty * trade.Price
+	}
+	if b.announced == nil {
+		return expected
+	}
+	announced := make(map[string]float64)
+	for pair, qty := range b.announced {
+		announced[baseAsset(pair)] += qty
+	}
+	for asset, qty := range announced {
+		expected[asset] = qty
+	}
+	return expected
+}
+
+// pairs returns the net base quantity of the trades per pair, as announced by BATCH_CREATED
+func (b *batch) pairs() map[string]float64 {
+	pairs := make(map[string]float64)
+	for _, trade := range b.trades {
+		pairs[trade.Asset+"/"+trade.CounterAsset] += tradeQty(trade)
+	}
+	return pairs
+}
+
+func tradeQty(trade dvotcWS.Trade) float64 {
+	qty := float64(trade.Quantity)
+	if strings.EqualFold(trade.Side, "sell") {
+		return -qty
+	}
+	return qty
+}
+
+// baseAsset returns the asset of a pair like BTC/USD, a symbol without counter
+// asset is the asset itself
+func baseAsset(symbol string) string {
+	return strings.SplitN(symbol, "/", 2)[0]
+}
+
+// tie merges b with the only batch on the other side it matches, a batch of trades
+// with a notified batch or the other way around, callers hold t.mu
+func (t *Tracker) tie(b *batch) {
+	if t.batches[b.id] != b {
+		// merged meanwhile
+		return
+	}
+	var match *batch
+	for _, other := range t.batches {
+		if other == b || !t.ties(b, other) && !t.ties(other, b) {
+			continue
+		}
+		if match != nil {
+			// ambiguous, left to Link
+			return
+		}
+		match = other
+	}
+	switch {
+	case match == nil:
+	case b.notified:
+		t.alias(match.id, b.id)
+	default:
+		t.alias(b.id, match.id)
+	}
+}
+
+// ties reports if the trades of trading, a batch only known from its trades, add up
+// to the net quantities announced for notified, a batch without trades yet
+func (t *Tracker) ties(trading, notified *batch) bool {
+	if trading.notified || len(trading.trades) == 0 || !notified.notified || notified.announced == nil || len(notified.trades) > 0 {
+		return false
+	}
+	pairs := trading.pairs()
+	if len(pairs) != len(notified.announced) {
+		return false
+	}
+	for pair, qty := range notified.announced {
+		net, ok := pairs[pair]
+		if !ok || math.Abs(net-qty) > t.cfg.Tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// notified returns the batch of n marked as seen in notifications, callers hold t.mu
+func (t *Tracker) notified(n dvotcWS.BatchNotificaiton) *batch {
+	b := t.batch(t.batchID(n))
+	b.notified = true
+	return b
+}
+
+// batchID returns the batch UUID of n, or its numeric batch ID until a notification
+// carrying both maps one to the other, callers hold t.mu
+func (t *Tracker) batchID(n dvotcWS.BatchNotificaiton) string {
+	numeric := ""
+	if n.Info != nil && n.Info.BatchID != 0 {
+		numeric = strconv.FormatInt(n.Info.BatchID, 10)
+	}
+	if n.BatchUUID == "" {
+		return t.resolve(numeric)
+	}
+	if numeric != "" {
+		t.alias(numeric, n.BatchUUID)
+	}
+	return n.BatchUUID
+}
+
+// resolve returns the batch UUID id is an alias of, or id itself, callers hold t.mu
+func (t *Tracker) resolve(id string) string {
+	if uuid, ok := t.aliases[id]; ok {
+		return uuid
+	}
+	return id
+}
+
+// alias maps id to the batch uuid and merges what was tracked under id into it,
+// callers hold t.mu
+func (t *Tracker) alias(id, uuid string) {
+	uuid = t.resolve(uuid)
+	if id == "" || id == uuid || t.aliases[id] == uuid {
+		return
+	}
+	t.aliases[id] = uuid
+	from, ok := t.batches[id]
+	if !ok {
+		return
+	}
+	delete(t.batches, id)
+	t.batch(uuid).merge(from)
+}
+
+var stageOrder = map[Stage]int{StageTrading: 0, StageCreated: 1, StageSettlementAdded: 2, StageSettled: 3}
+
+// merge adds what is known of from, the same batch tracked under another ID, to b
+func (b *batch) merge(from *batch) {
+	b.notified = b.notified || from.notified
+	if stageOrder[from.stage] > stageOrder[b.stage] {
+		b.stage = from.stage
+	}
+	if !from.createdAt.IsZero() && (b.createdAt.IsZero() || from.createdAt.Before(b.createdAt)) {
+		b.createdAt = from.createdAt
+	}
+	if b.settledAt == nil {
+		b.settledAt = from.settledAt
+	}
+	for id, trade := range from.trades {
+		b.trades[id] = trade
+	}
+	if b.announced == nil {
+		b.announced = from.announced
+	}
+	for asset, qty := range from.received {
+		b.received[asset] += qty
+	}
+	// settlements delivered under both IDs are counted once
+	for id, s := range from.settlements {
+		if _, ok := b.settlements[id]; ok && id != 0 {
+			b.received[s.asset] -= s.qty
+			continue
+		}
+		b.settlements[id] = s
+	}
+}
@@ -0,0 +1,213 @@
+package settlement_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/settlement"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func settlementAdded(batchUUID string, id int64, asset, qty string) dvotcWS.SettlementAddedNotification {
+	return dvotcWS.SettlementAddedNotification{
+		BatchUUID: batchUUID,
+		Info:      &dvotcWS.Info{ID: id, Asset: asset, NetQuantity: json.Number(qty)},
+	}
+}
+
+func TestTracker(t *testing.T) {
+	t.Run("settles_expected_quantities", func(t *testing.T) {
+		c := &clock{now: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+		tracker := settlement.NewTracker(settlement.Config{Now: c.Now})
+
+		tracker.AddTrades([]dvotcWS.Trade{
+			{ID: "t1", Asset: "BTC", CounterAsset: "USD", Side: "Buy", Quantity: 2, Price: 20000, Batch: dvotcWS.Batch{ID: "b1"}},
+			{ID: "t2", Asset: "BTC", CounterAsset: "USD", Side: "Sell", Quantity: 1, Price: 21000, Batch: dvotcWS.Batch{ID: "b1"}},
+			{ID: "t3", Asset: "BTC", CounterAsset: "USD", Side: "Sell", Quantity: 1, Price: 21000},
+		})
+		b, ok := tracker.Batch("b1")
+		require.True(t, ok)
+		assert.Equal(t, settlement.StageTrading, b.Stage)
+		assert.Equal(t, []string{"t1", "t2"}, b.TradeIDs)
+		assert.Equal(t, map[string]float64{"BTC": 1, "USD": -19000}, b.Expected)
+
+		tracker.BatchCreated(dvotcWS.BatchCreatedNotification{BatchUUID: "b1"})
+		tracker.SettlementAdded(settlementAdded("b1", 1, "BTC", "1"))
+		// redelivered settlements are counted once
+		tracker.SettlementAdded(settlementAdded("b1", 1, "BTC", "1"))
+		b, _ = tracker.Batch("b1")
+		assert.Equal(t, settlement.StageSettlementAdded, b.Stage)
+		assert.Equal(t, map[string]float64{"BTC": 1}, b.Received)
+		assert.False(t, b.Flagged())
+
+		tracker.SettlementAdded(settlementAdded("b1", 2, "USD", "-19000"))
+		tracker.BatchSettled(dvotcWS.BatchSettledNotification{BatchUUID: "b1"})
+		b, _ = tracker.Batch("b1")
+		assert.Equal(t, settlement.StageSettled, b.Stage)
+		assert.Equal(t, c.now, *b.SettledAt)
+		assert.Empty(t, b.Mismatches)
+		assert.Empty(t, tracker.Flagged())
+	})
+
+	t.Run("flags_mismatched", func(t *testing.T) {
+		tracker := settlement.NewTracker(settlement.Config{})
+		tracker.BatchCreated(dvotcWS.BatchCreatedNotification{
+			BatchUUID: "b2",
+			BatchDetails: []dvotcWS.BatchDetail{
+				{Symbol: "ETH/USD", NetQuantity: "-5"},
+				{Symbol: "ETH/EUR", NetQuantity: "1"},
+			},
+		})
+		tracker.SettlementAdded(settlementAdded("b2", 1, "ETH", "-3"))
+		// without trades the counter assets are not known
+		tracker.SettlementAdded(settlementAdded("b2", 2, "USD", "5900"))
+		tracker.BatchSettled(dvotcWS.BatchSettledNotification{BatchUUID: "b2"})
+
+		flagged := tracker.Flagged()
+		require.Len(t, flagged, 1)
+		assert.Equal(t, "b2", flagged[0].ID)
+		assert.False(t, flagged[0].Overdue)
+		assert.Equal(t, map[string]float64{"ETH": -4}, flagged[0].Expected)
+		assert.Equal(t, []settlement.Mismatch{{Asset: "ETH", Expected: -4, Received: -3}}, flagged[0].Mismatches)
+	})
+
+	t.Run("flags_overdue", func(t *testing.T) {
+		c := &clock{now: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+		tracker := settlement.NewTracker(settlement.Config{Now: c.Now, SettleWithin: time.Hour})
+		tracker.BatchCreated(dvotcWS.BatchCreatedNotification{BatchUUID: "b3"})
+		tracker.BatchCreated(dvotcWS.BatchCreatedNotification{BatchUUID: "b4"})
+		assert.Empty(t, tracker.Flagged())
+
+		c.now = c.now.Add(30 * time.Minute)
+		tracker.BatchSettled(dvotcWS.BatchSettledNotification{BatchUUID: "b4"})
+		c.now = c.now.Add(time.Hour)
+
+		flagged := tracker.Flagged()
+		require.Len(t, flagged, 1)
+		assert.Equal(t, "b3", flagged[0].ID)
+		assert.True(t, flagged[0].Overdue)
+		assert.Len(t, tracker.Batches(), 2)
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		created := make(chan dvotcWS.BatchNotificaiton, 1)
+		added := make(chan dvotcWS.BatchNotificaiton, 1)
+		settled := make(chan dvotcWS.BatchNotificaiton, 1)
+		created <- dvotcWS.BatchCreatedNotification{BatchUUID: "b5"}
+		added <- dvotcWS.SettlementAddedNotification{Info: &dvotcWS.Info{ID: 1, BatchID: 42, Asset: "BTC", NetQuantity: "1"}}
+		close(created)
+		close(added)
+
+		tracker := settlement.NewTracker(settlement.Config{})
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, tracker.Watch(ctx, created, added, settled), context.DeadlineExceeded)
+
+		_, ok := tracker.Batch("b5")
+		assert.True(t, ok)
+		b, ok := tracker.Batch("42")
+		require.True(t, ok)
+		assert.Equal(t, map[string]float64{"BTC": 1}, b.Received)
+	})
+}
+
+func TestTrackerBatchAliases(t *testing.T) {
+	tracker := settlement.NewTracker(settlement.Config{})
+	tracker.AddTrades([]dvotcWS.Trade{
+		{ID: "t1", Asset: "BTC", CounterAsset: "USD", Side: "Buy", Quantity: 1, Price: 20000, Batch: dvotcWS.Batch{ID: "64b0c0ffee"}},
+	})
+
+	// the first settlement only carries the numeric batch ID
+	tracker.SettlementAdded(dvotcWS.SettlementAddedNotification{Info: &dvotcWS.Info{ID: 1, BatchID: 42, Asset: "BTC", NetQuantity: "1"}})
+	// the next one carries both and maps 42 to the UUID, redelivering settlement 1
+	tracker.SettlementAdded(dvotcWS.SettlementAddedNotification{BatchUUID: "uuid-7", Info: &dvotcWS.Info{ID: 1, BatchID: 42, Asset: "BTC", NetQuantity: "1"}})
+	tracker.SettlementAdded(dvotcWS.SettlementAddedNotification{Info: &dvotcWS.Info{ID: 2, BatchID: 42, Asset: "USD", NetQuantity: "-20000"}})
+	tracker.BatchSettled(dvotcWS.BatchSettledNotification{BatchUUID: "uuid-7"})
+
+	batches := tracker.Batches()
+	require.Len(t, batches, 2)
+	b, ok := tracker.Batch("42")
+	require.True(t, ok)
+	assert.Equal(t, "uuid-7", b.ID)
+	assert.Equal(t, settlement.StageSettled, b.Stage)
+	assert.Equal(t, map[string]float64{"BTC": 1, "USD": -20000}, b.Received)
+	// the trade batch ID is not the UUID, without details to match its batch stays apart until linked
+	assert.Empty(t, b.TradeIDs)
+
+	tracker.Link("64b0c0ffee", "42")
+	require.Len(t, tracker.Batches(), 1)
+	b, _ = tracker.Batch("uuid-7")
+	assert.Equal(t, []string{"t1"}, b.TradeIDs)
+	assert.Empty(t, b.Mismatches)
+}
+
+func TestTrackerTiesTrades(t *testing.T) {
+	trades := []dvotcWS.Trade{
+		{ID: "t1", Asset: "BTC", CounterAsset: "USD", Side: "Buy", Quantity: 3, Price: 20000, Batch: dvotcWS.Batch{ID: "64b0c0ffee"}},
+		{ID: "t2", Asset: "BTC", CounterAsset: "USD", Side: "Sell", Quantity: 1, Price: 21000, Batch: dvotcWS.Batch{ID: "64b0c0ffee"}},
+		{ID: "t3", Asset: "ETH", CounterAsset: "USD", Side: "Sell", Quantity: 5, Price: 1500, Batch: dvotcWS.Batch{ID: "64b0decade"}},
+	}
+	created := dvotcWS.BatchCreatedNotification{
+		BatchUUID:    "uuid-8",
+		BatchDetails: []dvotcWS.BatchDetail{{Symbol: "BTC/USD", NetQuantity: "2"}},
+	}
+
+	t.Run("trades_first", func(t *testing.T) {
+		tracker := settlement.NewTracker(settlement.Config{})
+		tracker.AddTrades(trades)
+		tracker.BatchCreated(created)
+
+		require.Len(t, tracker.Batches(), 2)
+		b, ok := tracker.Batch("64b0c0ffee")
+		require.True(t, ok)
+		assert.Equal(t, "uuid-8", b.ID)
+		assert.Equal(t, []string{"t1", "t2"}, b.TradeIDs)
+		assert.Equal(t, map[string]float64{"BTC": 2, "USD": -39000}, b.Expected)
+
+		tracker.SettlementAdded(settlementAdded("uuid-8", 1, "BTC", "2"))
+		tracker.SettlementAdded(settlementAdded("uuid-8", 2, "USD", "-38000"))
+		tracker.BatchSettled(dvotcWS.BatchSettledNotification{BatchUUID: "uuid-8"})
+		b, _ = tracker.Batch("uuid-8")
+		assert.Equal(t, []settlement.Mismatch{{Asset: "USD", Expected: -39000, Received: -38000}}, b.Mismatches)
+	})
+
+	t.Run("notification_first", func(t *testing.T) {
+		tracker := settlement.NewTracker(settlement.Config{})
+		tracker.BatchCreated(created)
+		tracker.AddTrades(trades[:1])
+		// the trades don't add up to the announced quantity yet
+		require.Len(t, tracker.Batches(), 2)
+
+		tracker.AddTrades(trades[1:])
+		b, ok := tracker.Batch("uuid-8")
+		require.True(t, ok)
+		assert.Equal(t, []string{"t1", "t2"}, b.TradeIDs)
+		_, ok = tracker.Batch("64b0decade")
+		assert.True(t, ok)
+	})
+
+	t.Run("ambiguous_left_apart", func(t *testing.T) {
+		tracker := settlement.NewTracker(settlement.Config{})
+		tracker.AddTrades(trades)
+		tracker.AddTrades([]dvotcWS.Trade{
+			{ID: "t4", Asset: "BTC", CounterAsset: "USD", Side: "Buy", Quantity: 2, Price: 19000, Batch: dvotcWS.Batch{ID: "64b0beef"}},
+		})
+		tracker.BatchCreated(created)
+
+		require.Len(t, tracker.Batches(), 4)
+		b, _ := tracker.Batch("uuid-8")
+		assert.Empty(t, b.TradeIDs)
+	})
+}
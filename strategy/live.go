@@ -0,0 +1,211 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+)
+
+// Client is satisfied by *dvotcWS.DVOTCClient, including the clients of the
+// dvotctest fake server
+type Client interface {
+	Gateway
+	SubscribeLevels(symbol string) (*dvotcWS.SubscribeLevelData, error)
+	SubscribeOrderChanges(status string) (*dvotcWS.Subscription[dvotcWS.OrderStatus], error)
+	SubscribeOrderCreated() (*dvotcWS.Subscription[dvotcWS.OrderNotification], error)
+	SubscribeOrderFilled() (*dvotcWS.Subscription[dvotcWS.OrderNotification], error)
+	SubscribeOrderCancelled() (*dvotcWS.Subscription[dvotcWS.OrderNotification], error)
+	SubscribeBatchCreated() (*dvotcWS.Subscription[dvotcWS.BatchCreatedNotification], error)
+	SubscribeBatchSettled() (*dvotcWS.Subscription[dvotcWS.BatchSettledNotification], error)
+	SubscribeSettlementAdded() (*dvotcWS.Subscription[dvotcWS.SettlementAddedNotification], error)
+	SubscribeLimitChanged() (*dvotcWS.Subscription[dvotcWS.LimitChangedNotification], error)
+}
+
+type Config struct {
+	// Symbols are the level streams delivered to OnLevels
+	Symbols []string
+	// OrderUpdates delivers the updates of every order of the account to OnOrderUpdate
+	OrderUpdates bool
+	// Notifications are the topics delivered to OnNotification, e.g. dvotcWS.NOTIFICATION_ORDER_FILLED
+	Notifications []string
+	// TimerInterval is how often OnTimer is called, zero disables it
+	TimerInterval time.Duration
+}
+
+// LiveFeed delivers the subscriptions of a client as events, it ends once all of
+// them are closed
+type LiveFeed struct {
+	client Client
+	events chan Event
+	done   chan struct{}
+	// ended is closed once every subscription stopped delivering
+	ended  chan struct{}
+	ticker *time.Ticker
+	stops  []func() error
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// NewLiveFeed subscribes client to the streams of cfg, events are buffered from
+// then on until the feed is run
+func NewLiveFeed(client Client, cfg Config) (*LiveFeed, error) {
+	f := &LiveFeed{
+		client: client,
+		events: make(chan Event, 100),
+		done:   make(chan struct{}),
+		ended:  make(chan struct{}),
+	}
+	if err := f.subscribe(cfg); err != nil {
+		f.Close()
+		return nil, err
+	}
+	// a feed of the timer alone never ends
+	if len(f.stops) > 0 {
+		go func() {
+			f.wg.Wait()
+			close(f.ended)
+		}()
+	}
+	if cfg.TimerInterval > 0 {
+		f.ticker = time.NewTicker(cfg.TimerInterval)
+	}
+	return f, nil
+}
+
+func (f *LiveFeed) subscribe(cfg Config) error {
+	for _, symbol := range cfg.Symbols {
+		sub, err := f.client.SubscribeLevels(symbol)
+		if err != nil {
+			return fmt.Errorf("subscribe %s: %w", symbol, err)
+		}
+		symbol := symbol
+		forward(f, sub, func(levels *dvotcWS.LevelData) Event {
+			return Event{Kind: EventLevels, Symbol: symbol, Levels: levels}
+		})
+	}
+
+	if cfg.OrderUpdates {
+		sub, err := f.client.SubscribeOrderChanges("#")
+		if err != nil {
+			return fmt.Errorf("subscribe order updates: %w", err)
+		}
+		forward(f, sub, func(order dvotcWS.OrderStatus) Event {
+			return Event{Kind: EventOrderUpdate, Order: order}
+		})
+	}
+
+	for _, topic := range cfg.Notifications {
+		if err := f.subscribeNotifications(topic); err != nil {
+			return fmt.Errorf("subscribe %s: %w", topic, err)
+		}
+	}
+	return nil
+}
+
+func (f *LiveFeed) subscribeNotifications(topic string) error {
+	switch topic {
+	case dvotcWS.NOTIFICATION_ORDER_CREATED:
+		return forwardNotifications(f, topic, f.client.SubscribeOrderCreated)
+	case dvotcWS.NOTIFICATION_ORDER_FILLED:
+		return forwardNotifications(f, topic, f.client.SubscribeOrderFilled)
+	case dvotcWS.NOTIFICATION_ORDER_CANCELLED:
+		return forwardNotifications(f, topic, f.client.SubscribeOrderCancelled)
+	case dvotcWS.NOTIFICATION_BATCH_CREATED:
+		return forwardNotifications(f, topic, f.client.SubscribeBatchCreated)
+	case dvotcWS.NOTIFICATION_BATCH_SETTLED:
+		return forwardNotifications(f, topic, f.client.SubscribeBatchSettled)
+	case dvotcWS.NOTIFICATION_SETTLEMENT_ADDED:
+		return forwardNotifications(f, topic, f.client.SubscribeSettlementAdded)
+	case dvotcWS.NOTIFICATION_LIMIT_CHANGED:
+		return forwardNotifications(f, topic, f.client.SubscribeLimitChanged)
+	}
+	return ErrUnknownTopic
+}
+
+func forwardNotifications[T any](f *LiveFeed, topic string, subscribe func() (*dvotcWS.Subscription[T], error)) error {
+	sub, err := subscribe()
+	if err != nil {
+		return err
+	}
+	forward(f, sub, func(n T) Event {
+		return Event{Kind: EventNotification, Topic: topic, Notification: n}
+	})
+	return nil
+}
+
+// forward turns the data of sub into events, stamped with the time they are read,
+// until sub or the feed is closed
+func forward[T any](f *LiveFeed, sub *dvotcWS.Subscription[T], event func(T) Event) {
+	f.stops = append(f.stops, sub.StopConsuming)
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		for {
+			select {
+			case data, ok := <-sub.Data:
+				if !ok {
+					return
+				}
+				ev := event(data)
+				ev.Time = time.Now()
+				select {
+				case f.events <- ev:
+				case <-f.done:
+					return
+				}
+			case <-f.done:
+				return
+			}
+		}
+	}()
+}
+
+func (f *LiveFeed) Gateway() Gateway {
+	return f.client
+}
+
+// Next returns the next event, io.EOF once all subscriptions are closed and their
+// events delivered, or ctx.Err()
+func (f *LiveFeed) Next(ctx context.Context) (Event, error) {
+	var tick <-chan time.Time
+	if f.ticker != nil {
+		tick = f.ticker.C
+	}
+	select {
+	case ev := <-f.events:
+		return ev, nil
+	case <-f.ended:
+		select {
+		case ev := <-f.events:
+			return ev, nil
+		default:
+			return Event{}, io.EOF
+		}
+	case now := <-tick:
+		return Event{Kind: EventTimer, Time: now}, nil
+	case <-ctx.Done():
+		return Event{}, ctx.Err()
+	}
+}
+
+// Close stops the subscriptions of the feed
+func (f *LiveFeed) Close() error {
+	var err error
+	f.once.Do(func() {
+		close(f.done)
+		f.wg.Wait()
+		if f.ticker != nil {
+			f.ticker.Stop()
+		}
+		for _, stop := range f.stops {
+			if serr := stop(); serr != nil && err == nil {
+				err = serr
+			}
+		}
+	})
+	return err
+}
@@ -0,0 +1,112 @@
+// Package strategy defines the interface trading bots implement and the runner
+// driving them, so the same strategy code runs against the API, the dvotctest
+// fake server and recorded data:
+//
+//	feed, err := strategy.NewLiveFeed(client, strategy.Config{Symbols: []string{"BTC/USD"}, OrderUpdates: true})
+//	err = strategy.Run(ctx, feed, bot)
+//
+// backtests get their feed from backtest.Backtester.Feed
+package strategy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+)
+
+var ErrUnknownTopic = errors.New("unknown notification topic")
+
+// Gateway places and cancels orders, *dvotcWS.DVOTCClient satisfies it for live
+// trading and backtests simulate it
+type Gateway interface {
+	PlaceMarketOrder(order dvotcWS.MarketOrderParams) (*dvotcWS.OrderStatus, error)
+	PlaceLimitOrder(order dvotcWS.LimitOrderParams) (*dvotcWS.OrderStatus, error)
+	CancelOrder(orderID string) error
+}
+
+// Env is handed to every callback of a strategy
+type Env struct {
+	// Time is when the event happened, the recorded receive time in backtests,
+	// strategies use it instead of time.Now so they behave the same in both
+	Time    time.Time
+	Gateway Gateway
+}
+
+// Strategy receives market data and order updates and trades through Env.Gateway.
+// Callbacks are never called concurrently
+type Strategy interface {
+	// OnLevels is called for every level update of the symbols the strategy trades
+	OnLevels(env Env, symbol string, levels *dvotcWS.LevelData)
+	// OnOrderUpdate is called when an order changes status
+	OnOrderUpdate(env Env, order dvotcWS.OrderStatus)
+	// OnNotification is called with the notifications of topic, a
+	// dvotcWS.OrderNotification for the ORDER_ topics, a dvotcWS.BatchNotificaiton
+	// for the batch and settlement ones and a dvotcWS.LimitChangedNotification
+	// for LIMIT_CHANGED
+	OnNotification(env Env, topic string, notification any)
+	// OnTimer is called every Config.TimerInterval
+	OnTimer(env Env)
+}
+
+type EventKind int
+
+const (
+	EventLevels EventKind = iota
+	EventOrderUpdate
+	EventNotification
+	EventTimer
+)
+
+// Event is one callback to make, the fields set depend on Kind
+type Event struct {
+	Kind EventKind
+	Time time.Time
+	// Symbol and Levels are set for EventLevels
+	Symbol string
+	Levels *dvotcWS.LevelData
+	// Order is set for EventOrderUpdate
+	Order dvotcWS.OrderStatus
+	// Topic and Notification are set for EventNotification
+	Topic        string
+	Notification any
+}
+
+// Feed produces the events of a run and the gateway the strategy trades through
+type Feed interface {
+	Gateway() Gateway
+	// Next blocks until the next event, io.EOF ends the run
+	Next(ctx context.Context) (Event, error)
+	Close() error
+}
+
+// Run calls the callbacks of s for every event of feed until the feed ends or ctx
+// is done, the feed is closed before it returns
+func Run(ctx context.Context, feed Feed, s Strategy) error {
+	defer feed.Close()
+	for {
+		ev, err := feed.Next(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		dispatch(s, Env{Time: ev.Time, Gateway: feed.Gateway()}, ev)
+	}
+}
+
+func dispatch(s Strategy, env Env, ev Event) {
+	switch ev.Kind {
+	case EventLevels:
+		s.OnLevels(env, ev.Symbol, ev.Levels)
+	case EventOrderUpdate:
+		s.OnOrderUpdate(env, ev.Order)
+	case EventNotification:
+		s.OnNotification(env, ev.Topic, ev.Notification)
+	case EventTimer:
+		s.OnTimer(env)
+	}
+}
@@ -0,0 +1,154 @@
+package strategy_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/dv-chain/dvotc-websocket-go/dvotctest"
+	"github.com/dv-chain/dvotc-websocket-go/strategy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buyOnce buys one BTC on the first levels and stops the run once it saw the fill
+// on both streams and a timer
+type buyOnce struct {
+	t      *testing.T
+	stop   context.CancelFunc
+	placed *dvotcWS.OrderStatus
+	timers int
+
+	updates []dvotcWS.OrderStatus
+	filled  []dvotcWS.OrderNotification
+}
+
+func (s *buyOnce) OnLevels(env strategy.Env, symbol string, levels *dvotcWS.LevelData) {
+	assert.Equal(s.t, "BTC/USD", symbol)
+	if s.placed != nil {
+		return
+	}
+	order, err := env.Gateway.PlaceMarketOrder(dvotcWS.MarketOrderParams{
+		QuoteID:      levels.QuoteID,
+		Asset:        "BTC",
+		CounterAsset: "USD",
+		Price:        levels.Levels[0].BuyPrice,
+		Qty:          1,
+		Side:         "Buy",
+		ClientTag:    "bot",
+	})
+	require.NoError(s.t, err)
+	s.placed = order
+}
+
+func (s *buyOnce) OnOrderUpdate(env strategy.Env, order dvotcWS.OrderStatus) {
+	s.updates = append(s.updates, order)
+	s.maybeStop()
+}
+
+func (s *buyOnce) OnNotification(env strategy.Env, topic string, notification any) {
+	assert.Equal(s.t, dvotcWS.NOTIFICATION_ORDER_FILLED, topic)
+	s.filled = append(s.filled, notification.(dvotcWS.OrderNotification))
+	s.maybeStop()
+}
+
+func (s *buyOnce) OnTimer(env strategy.Env) {
+	s.timers++
+	assert.False(s.t, env.Time.IsZero())
+	s.maybeStop()
+}
+
+func (s *buyOnce) maybeStop() {
+	if len(s.updates) > 0 && len(s.filled) > 0 && s.timers > 0 {
+		s.stop()
+	}
+}
+
+func TestRunLive(t *testing.T) {
+	srv := dvotctest.NewServer(dvotctest.Config{})
+	defer srv.Close()
+	srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 20010, SellPrice: 19990, MaxQuantity: 5})
+	client := srv.Client()
+
+	feed, err := strategy.NewLiveFeed(client, strategy.Config{
+		Symbols:       []string{"BTC/USD"},
+		OrderUpdates:  true,
+		Notifications: []string{dvotcWS.NOTIFICATION_ORDER_FILLED},
+		TimerInterval: 20 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, client, feed.Gateway())
+	require.Eventually(t, func() bool {
+		return srv.Subscribers("order/#", "order-updates") == 1 &&
+			srv.Subscribers(dvotcWS.NOTIFICATION_ORDER_FILLED, "notifications") == 1
+	}, time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	bot := &buyOnce{t: t, stop: cancel}
+	require.ErrorIs(t, strategy.Run(ctx, feed, bot), context.Canceled)
+
+	require.NotNil(t, bot.placed)
+	require.NotEmpty(t, bot.updates)
+	assert.Equal(t, bot.placed.ID, bot.updates[0].ID)
+	require.NotEmpty(t, bot.filled)
+	assert.Equal(t, bot.placed.ID, bot.filled[0].ID)
+	assert.Positive(t, bot.timers)
+	require.Len(t, srv.Orders(), 1)
+
+	// the run closed the feed and its subscriptions
+	require.Eventually(t, func() bool {
+		return srv.Subscribers("order/#", "order-updates") == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestLiveFeedEnds(t *testing.T) {
+	srv := dvotctest.NewServer(dvotctest.Config{})
+	defer srv.Close()
+	srv.SetLevels("BTC/USD", dvotcWS.Level{BuyPrice: 20010, SellPrice: 19990, MaxQuantity: 5})
+	client := srv.Client()
+
+	feed, err := strategy.NewLiveFeed(client, strategy.Config{
+		OrderUpdates:  true,
+		Notifications: []string{dvotcWS.NOTIFICATION_ORDER_FILLED},
+	})
+	require.NoError(t, err)
+	defer feed.Close()
+	require.Eventually(t, func() bool {
+		return srv.Subscribers("order/#", "order-updates") == 1 &&
+			srv.Subscribers(dvotcWS.NOTIFICATION_ORDER_FILLED, "notifications") == 1
+	}, time.Second, 10*time.Millisecond)
+
+	levels, _ := srv.Levels("BTC/USD")
+	_, err = client.PlaceMarketOrder(dvotcWS.MarketOrderParams{QuoteID: levels.QuoteID, Asset: "BTC", CounterAsset: "USD", Qty: 1, Side: "Buy"})
+	require.NoError(t, err)
+	// both events are read well before they are asked for
+	time.Sleep(100 * time.Millisecond)
+	asked := time.Now()
+	require.Equal(t, 1, srv.DropSubscribers("order/#", "order-updates"))
+	require.Equal(t, 1, srv.DropSubscribers(dvotcWS.NOTIFICATION_ORDER_FILLED, "notifications"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	kinds := make([]strategy.EventKind, 0)
+	for {
+		ev, err := feed.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		assert.True(t, ev.Time.Before(asked), "event stamped at delivery")
+		kinds = append(kinds, ev.Kind)
+	}
+	assert.ElementsMatch(t, []strategy.EventKind{strategy.EventOrderUpdate, strategy.EventNotification}, kinds)
+}
+
+func TestNewLiveFeedUnknownTopic(t *testing.T) {
+	srv := dvotctest.NewServer(dvotctest.Config{})
+	defer srv.Close()
+
+	_, err := strategy.NewLiveFeed(srv.Client(), strategy.Config{Notifications: []string{"ORDER_TELEPORTED"}})
+	require.ErrorIs(t, err, strategy.ErrUnknownTopic)
+}
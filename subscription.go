@@ -26,10 +26,12 @@ func (s *Subscription[_]) StopConsuming() error {
 	}
 	s.isClosed = true
 	close(s.done)
-	<-s.Data
 
+	// closing first unblocks a reader still waiting on the connection
+	var err error
 	if s.conn != nil {
-		return s.conn.Close()
+		err = s.conn.Close()
 	}
-	return nil
+	<-s.Data
+	return err
 }
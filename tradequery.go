@@ -0,0 +1,310 @@
+package dvotcWS
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+const defaultTradePageSize = 100
+
+// TradeQuery filters the trade history, build it with NewTradeQuery and pass it to Trades.
+// The tradestatus topic is only documented to filter by IDs, trade keys and client tags,
+// the time range, asset, side and status filters are applied by the client to what it returns.
+//
+// Trades are fetched in pages of Limit trades. IDs are requested Limit at a time, a query
+// without IDs can only be paged by a server accepting a cursor, see CursorPaging, any other
+// server lists it in a single response.
+type TradeQuery struct {
+	ids          []string
+	tradeKeys    []string
+	clientTags   []string
+	from         time.Time
+	to           time.Time
+	asset        string
+	counterAsset string
+	side         string
+	status       string
+	limit        int
+	cursorPaging bool
+	after        string
+}
+
+func NewTradeQuery() *TradeQuery {
+	return &TradeQuery{}
+}
+
+func (q *TradeQuery) IDs(ids ...string) *TradeQuery {
+	q.ids = append(q.ids, ids...)
+	return q
+}
+
+func (q *TradeQuery) TradeKeys(tradeKeys ...string) *TradeQuery {
+	q.tradeKeys = append(q.tradeKeys, tradeKeys...)
+	return q
+}
+
+func (q *TradeQuery) ClientTags(clientTags ...string) *TradeQuery {
+	q.clientTags = append(q.clientTags, clientTags...)
+	return q
+}
+
+// Between keeps trades filled (or created when not filled) in [from, to), a zero time leaves that end open
+func (q *TradeQuery) Between(from, to time.Time) *TradeQuery {
+	q.from = from
+	q.to = to
+	return q
+}
+
+func (q *TradeQuery) Asset(asset string) *TradeQuery {
+	q.asset = asset
+	return q
+}
+
+func (q *TradeQuery) CounterAsset(counterAsset string) *TradeQuery {
+	q.counterAsset = counterAsset
+	return q
+}
+
+func (q *TradeQuery) Side(side string) *TradeQuery {
+	q.side = side
+	return q
+}
+
+func (q *TradeQuery) Status(status string) *TradeQuery {
+	q.status = status
+	return q
+}
+
+// Limit sets the number of trades requested at a time, defaults to 100
+func (q *TradeQuery) Limit(limit int) *TradeQuery {
+	q.limit = limit
+	return q
+}
+
+// CursorPaging sends the limit and the cursor along with the request of a query without IDs,
+// for servers paging the tradestatus topic. A server that ignores them is detected by a page
+// longer than the limit, or the same page sent again, and its response is taken as complete
+func (q *TradeQuery) CursorPaging() *TradeQuery {
+	q.cursorPaging = true
+	return q
+}
+
+// After starts the query after the trade with ID cursor, usually the Cursor of a previous
+// iterator. The cursor is sent to servers paging by cursor and skips the IDs up to it,
+// otherwise the trades up to it are skipped as they are listed, and nothing is returned
+// if that trade is not listed
+func (q *TradeQuery) After(cursor string) *TradeQuery {
+	q.after = cursor
+	return q
+}
+
+// Matches reports if trade passes the filters of the query other than IDs, trade keys and client tags
+func (q *TradeQuery) Matches(trade Trade) bool {
+	at := trade.FilledAt
+	if at.IsZero() {
+		at = trade.CreatedAt
+	}
+	switch {
+	case !q.from.IsZero() && at.Before(q.from):
+		return false
+	case !q.to.IsZero() && !at.Before(q.to):
+		return false
+	case q.asset != "" && !strings.EqualFold(q.asset, trade.Asset):
+		return false
+	case q.counterAsset != "" && !strings.EqualFold(q.counterAsset, trade.CounterAsset):
+		return false
+	case q.side != "" && !strings.EqualFold(q.side, trade.Side):
+		return false
+	case q.status != "" && !strings.EqualFold(q.status, trade.Status):
+		return false
+	}
+	return true
+}
+
+func (q *TradeQuery) pageSize() int {
+	if q.limit > 0 {
+		return q.limit
+	}
+	return defaultTradePageSize
+}
+
+func (q *TradeQuery) payload() ListTradesPayload {
+	return ListTradesPayload{
+		IDs:        strings.Join(q.ids, ","),
+		TradeKeys:  strings.Join(q.tradeKeys, ","),
+		ClientTags: strings.Join(q.clientTags, ","),
+	}
+}
+
+// TradeIterator pages through the trades of a TradeQuery, see Trades
+type TradeIterator struct {
+	dvotc *DVOTCClient
+	ctx   context.Context
+	query TradeQuery
+
+	page      []Trade
+	idx       int
+	nextID    int
+	cursor    string
+	pageFirst string
+	skipped   bool
+	current   Trade
+	done      bool
+	err       error
+}
+
+// Trades returns an iterator over the trades matching query, fetching a page at a time
+// as Next needs more trades:
+//
+//	it := client.Trades(ctx, dvotcWS.NewTradeQuery().Asset("BTC").Between(from, to))
+//	for it.Next() {
+//		trade := it.Trade()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+func (dvotc *DVOTCClient) Trades(ctx context.Context, query *TradeQuery) *TradeIterator {
+	if query == nil {
+		query = NewTradeQuery()
+	}
+	it := &TradeIterator{
+		dvotc:   dvotc,
+		ctx:     ctx,
+		query:   *query,
+		skipped: query.after == "",
+	}
+	if query.after == "" {
+		return it
+	}
+	switch {
+	case len(query.ids) > 0:
+		for i, id := range query.ids {
+			if id == query.after {
+				it.nextID = i + 1
+				it.skipped = true
+				break
+			}
+		}
+	case query.cursorPaging:
+		it.cursor = query.after
+		it.skipped = true
+	}
+	return it
+}
+
+// Next advances to the next trade, it returns false when there are no more trades or on error
+func (it *TradeIterator) Next() bool {
+	for {
+		for it.idx < len(it.page) {
+			trade := it.page[it.idx]
+			it.idx++
+			if !it.skipped {
+				it.skipped = trade.ID == it.query.after
+				continue
+			}
+			if !it.query.Matches(trade) {
+				continue
+			}
+			it.current = trade
+			return true
+		}
+		if it.done || it.err != nil {
+			return false
+		}
+		it.fetch()
+	}
+}
+
+func (it *TradeIterator) fetch() {
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		return
+	}
+	size := it.query.pageSize()
+	payload := it.query.payload()
+	switch {
+	case len(it.query.ids) > 0 && it.nextID == len(it.query.ids):
+		// After was the last ID
+		it.done = true
+		return
+	case len(it.query.ids) > 0:
+		end := it.nextID + size
+		if end >= len(it.query.ids) {
+			end = len(it.query.ids)
+			it.done = true
+		}
+		payload.IDs = strings.Join(it.query.ids[it.nextID:end], ",")
+		it.nextID = end
+	case it.query.cursorPaging:
+		payload.Limit = size
+		payload.Cursor = it.cursor
+	default:
+		it.done = true
+	}
+
+	trades, err := it.dvotc.listTrades(it.ctx, payload)
+	if err != nil {
+		it.err = err
+		return
+	}
+	it.page = trades
+	it.idx = 0
+	if len(it.query.ids) > 0 || !it.query.cursorPaging {
+		return
+	}
+	if it.pageFirst == "" && it.cursor != "" && listsTrade(trades, it.cursor) {
+		// the server ignored the cursor of After, the trades up to it are skipped here
+		it.skipped = false
+	}
+	switch {
+	case len(trades) == 0:
+		it.done = true
+		return
+	case trades[0].ID == it.pageFirst:
+		// the server ignored the cursor and sent the same page again
+		it.page = nil
+		it.done = true
+		return
+	case len(trades) != size:
+		// a short page is the last one, a longer one means the server doesn't page
+		it.done = true
+	}
+	it.pageFirst = trades[0].ID
+	it.cursor = trades[len(trades)-1].ID
+}
+
+func listsTrade(trades []Trade, id string) bool {
+	for _, trade := range trades {
+		if trade.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Trade returns the trade Next advanced to
+func (it *TradeIterator) Trade() Trade {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *TradeIterator) Err() error {
+	return it.err
+}
+
+// Cursor returns the ID of the last trade returned, pass it to TradeQuery.After to resume
+func (it *TradeIterator) Cursor() string {
+	if it.current.ID == "" {
+		return it.query.after
+	}
+	return it.current.ID
+}
+
+// All drains the iterator
+func (it *TradeIterator) All() ([]Trade, error) {
+	trades := make([]Trade, 0)
+	for it.Next() {
+		trades = append(trades, it.Trade())
+	}
+	return trades, it.Err()
+}
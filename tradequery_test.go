@@ -0,0 +1,213 @@
+package dvotcWS_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	dvotcWS "github.com/dv-chain/dvotc-websocket-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTradeHistoryServer lists every trade whatever the request, as the tradestatus
+// topic does without IDs, trade keys or client tags
+func setupTradeHistoryServer(t *testing.T, trades []dvotcWS.Trade) *routerWebsocketServer {
+	wsServer := &routerWebsocketServer{t: t}
+	wsServer.handlers = map[string]func(req dvotcWS.Payload) []dvotcWS.Payload{
+		"tradestatus": func(req dvotcWS.Payload) []dvotcWS.Payload {
+			data, err := json.Marshal(trades)
+			require.NoError(t, err)
+			return []dvotcWS.Payload{{Type: req.Type, Event: req.Event, Topic: req.Topic, Data: data}}
+		},
+	}
+	return wsServer
+}
+
+// setupPagingTradeServer lists the trades of the IDs requested, or every trade, paging
+// them with the limit and cursor of the request
+func setupPagingTradeServer(t *testing.T, trades []dvotcWS.Trade) *routerWebsocketServer {
+	wsServer := &routerWebsocketServer{t: t}
+	wsServer.handlers = map[string]func(req dvotcWS.Payload) []dvotcWS.Payload{
+		"tradestatus": func(req dvotcWS.Payload) []dvotcWS.Payload {
+			query := dvotcWS.ListTradesPayload{}
+			require.NoError(t, json.Unmarshal(req.Data, &query))
+			page := make([]dvotcWS.Trade, 0)
+			started := query.Cursor == ""
+			for _, trade := range trades {
+				if !started {
+					started = trade.ID == query.Cursor
+					continue
+				}
+				if query.IDs != "" && !strings.Contains(","+query.IDs+",", ","+trade.ID+",") {
+					continue
+				}
+				page = append(page, trade)
+				if query.Limit > 0 && len(page) == query.Limit {
+					break
+				}
+			}
+			data, err := json.Marshal(page)
+			require.NoError(t, err)
+			return []dvotcWS.Payload{{Type: req.Type, Event: req.Event, Topic: req.Topic, Data: data}}
+		},
+	}
+	return wsServer
+}
+
+func tradeIDs(trades []dvotcWS.Trade) []string {
+	ids := make([]string, 0, len(trades))
+	for _, trade := range trades {
+		ids = append(ids, trade.ID)
+	}
+	return ids
+}
+
+func tradeHistory(n int) []dvotcWS.Trade {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	trades := make([]dvotcWS.Trade, 0, n)
+	for i := 0; i < n; i++ {
+		side := "Buy"
+		if i%2 == 1 {
+			side = "Sell"
+		}
+		trades = append(trades, dvotcWS.Trade{
+			ID:           fmt.Sprintf("trade-%02d", i),
+			Asset:        "BTC",
+			CounterAsset: "USD",
+			Side:         side,
+			Status:       dvotcWS.OrderStatusComplete,
+			Quantity:     1,
+			CreatedAt:    start.Add(time.Duration(i) * time.Hour),
+			FilledAt:     start.Add(time.Duration(i) * time.Hour),
+		})
+	}
+	return trades
+}
+
+func TestTrades(t *testing.T) {
+	t.Run("filters_client_side", func(t *testing.T) {
+		wsServer := setupTradeHistoryServer(t, tradeHistory(25))
+		url := setupRouterWebsocketServer(wsServer)
+		defer wsServer.StopServer()
+		client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+
+		from := time.Date(2023, 1, 1, 2, 0, 0, 0, time.UTC)
+		query := dvotcWS.NewTradeQuery().
+			Asset("BTC").
+			Side("buy").
+			Between(from, from.Add(20*time.Hour))
+		trades, err := client.Trades(context.Background(), query).All()
+		require.NoError(t, err)
+
+		require.Len(t, trades, 10)
+		assert.Equal(t, "trade-02", trades[0].ID)
+		assert.Equal(t, "trade-20", trades[9].ID)
+
+		// only the filters of the tradestatus topic are sent, in a single request
+		requests := wsServer.Requests("tradestatus")
+		require.Len(t, requests, 1)
+		assert.JSONEq(t, `{"ids": "", "tradeKeys": "", "clientTags": ""}`, string(requests[0].Data))
+	})
+
+	t.Run("resume_from_cursor", func(t *testing.T) {
+		wsServer := setupTradeHistoryServer(t, tradeHistory(6))
+		url := setupRouterWebsocketServer(wsServer)
+		defer wsServer.StopServer()
+		client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+
+		it := client.Trades(context.Background(), nil)
+		require.True(t, it.Next())
+		require.True(t, it.Next())
+		assert.Equal(t, "trade-01", it.Cursor())
+
+		rest, err := client.Trades(context.Background(), dvotcWS.NewTradeQuery().After(it.Cursor())).All()
+		require.NoError(t, err)
+		require.Len(t, rest, 4)
+		assert.Equal(t, "trade-02", rest[0].ID)
+
+		gone, err := client.Trades(context.Background(), dvotcWS.NewTradeQuery().After("trade-99")).All()
+		require.NoError(t, err)
+		assert.Empty(t, gone)
+	})
+
+	t.Run("ids_in_windows", func(t *testing.T) {
+		history := tradeHistory(25)
+		wsServer := setupPagingTradeServer(t, history)
+		url := setupRouterWebsocketServer(wsServer)
+		defer wsServer.StopServer()
+		client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+
+		query := dvotcWS.NewTradeQuery().IDs(tradeIDs(history)...).Limit(10).Side("Sell")
+		it := client.Trades(context.Background(), query)
+		require.True(t, it.Next())
+		assert.Equal(t, "trade-01", it.Trade().ID)
+		// a window is only requested once the previous one is used up
+		assert.Len(t, wsServer.Requests("tradestatus"), 1)
+
+		rest, err := it.All()
+		require.NoError(t, err)
+		assert.Len(t, rest, 11)
+		requests := wsServer.Requests("tradestatus")
+		require.Len(t, requests, 3)
+		for i, ids := range [][]string{tradeIDs(history[:10]), tradeIDs(history[10:20]), tradeIDs(history[20:])} {
+			var payload dvotcWS.ListTradesPayload
+			require.NoError(t, json.Unmarshal(requests[i].Data, &payload))
+			assert.Equal(t, strings.Join(ids, ","), payload.IDs)
+			assert.Zero(t, payload.Limit)
+		}
+
+		// resuming skips the IDs up to the cursor
+		resumed, err := client.Trades(context.Background(), dvotcWS.NewTradeQuery().IDs(tradeIDs(history)...).Limit(10).After("trade-22")).All()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"trade-23", "trade-24"}, tradeIDs(resumed))
+		assert.Len(t, wsServer.Requests("tradestatus"), 4)
+	})
+
+	t.Run("cursor_paging", func(t *testing.T) {
+		wsServer := setupPagingTradeServer(t, tradeHistory(25))
+		url := setupRouterWebsocketServer(wsServer)
+		defer wsServer.StopServer()
+		client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+
+		trades, err := client.Trades(context.Background(), dvotcWS.NewTradeQuery().Limit(10).CursorPaging().After("trade-04")).All()
+		require.NoError(t, err)
+		require.Len(t, trades, 20)
+		assert.Equal(t, "trade-05", trades[0].ID)
+
+		// two full pages, the empty third one ends the history
+		requests := wsServer.Requests("tradestatus")
+		require.Len(t, requests, 3)
+		for i, cursor := range []string{"trade-04", "trade-14", "trade-24"} {
+			var payload dvotcWS.ListTradesPayload
+			require.NoError(t, json.Unmarshal(requests[i].Data, &payload))
+			assert.Equal(t, 10, payload.Limit)
+			assert.Equal(t, cursor, payload.Cursor)
+		}
+	})
+
+	t.Run("cursor_ignored", func(t *testing.T) {
+		wsServer := setupTradeHistoryServer(t, tradeHistory(25))
+		url := setupRouterWebsocketServer(wsServer)
+		defer wsServer.StopServer()
+		client := dvotcWS.NewDVOTCClient(url+"/websocket", "123", "321")
+
+		// the whole history comes back, it is the only page and After is applied by the client
+		trades, err := client.Trades(context.Background(), dvotcWS.NewTradeQuery().Limit(10).CursorPaging().After("trade-19")).All()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"trade-20", "trade-21", "trade-22", "trade-23", "trade-24"}, tradeIDs(trades))
+		assert.Len(t, wsServer.Requests("tradestatus"), 1)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		client := dvotcWS.NewDVOTCClient("ws://127.0.0.1:0/websocket", "123", "321")
+		it := client.Trades(ctx, nil)
+		assert.False(t, it.Next())
+		require.ErrorIs(t, it.Err(), context.Canceled)
+	})
+}
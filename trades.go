@@ -1,6 +1,7 @@
 package dvotcWS
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -38,20 +39,38 @@ type ListTradesPayload struct {
 	IDs        string `json:"ids"`
 	TradeKeys  string `json:"tradeKeys"`
 	ClientTags string `json:"clientTags"`
+
+	// Limit and Cursor are only sent with TradeQuery.CursorPaging
+	Limit  int    `json:"limit,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
 }
 
 func (dvotc *DVOTCClient) ListTrades(IDs []string, tradeKeys []string, clientTags []string) ([]Trade, error) {
+	data := ListTradesPayload{
+		IDs:        strings.Join(IDs, ","),
+		TradeKeys:  strings.Join(tradeKeys, ","),
+		ClientTags: strings.Join(clientTags, ","),
+	}
+	return dvotc.listTrades(context.Background(), data)
+}
+
+func (dvotc *DVOTCClient) listTrades(ctx context.Context, data ListTradesPayload) ([]Trade, error) {
 	conn, err := dvotc.getConn()
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
 
-	data := ListTradesPayload{
-		IDs:        strings.Join(IDs, ","),
-		TradeKeys:  strings.Join(tradeKeys, ","),
-		ClientTags: strings.Join(clientTags, ","),
-	}
+	// closing the connection unblocks the read below when ctx is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
 
 	dataBytes, err := json.Marshal(data)
 	if err != nil {
@@ -72,6 +91,9 @@ func (dvotc *DVOTCClient) ListTrades(IDs []string, tradeKeys []string, clientTag
 
 	resp := &Payload{}
 	if err := conn.ReadJSON(&resp); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		return nil, err
 	}
 	if resp.Type == "error" {